@@ -7,6 +7,9 @@ let historyData = {
     cpu: [],
     memory: [],
     zeroWindows: [],
+    psiCpu: [],
+    psiMemory: [],
+    psiIo: [],
     labels: []
 };
 const MAX_HISTORY = 60;
@@ -16,6 +19,8 @@ let lastZeroWindowCount = 0;
 document.addEventListener('DOMContentLoaded', () => {
     initCharts();
     checkStatus();
+    loadProcessPins();
+    loadProcessEvents();
 });
 
 function initCharts() {
@@ -173,6 +178,94 @@ function initCharts() {
         }
     });
 
+    // Pressure Stall Chart (share of each interval tasks spent waiting)
+    const pressureCtx = document.getElementById('pressureChart').getContext('2d');
+    charts.pressure = new Chart(pressureCtx, {
+        type: 'line',
+        data: {
+            labels: [],
+            datasets: [
+                {
+                    label: 'CPU some %',
+                    data: [],
+                    borderColor: '#00b4d8',
+                    tension: 0.4,
+                    fill: false
+                },
+                {
+                    label: 'Memory some %',
+                    data: [],
+                    borderColor: '#00d9a5',
+                    tension: 0.4,
+                    fill: false
+                },
+                {
+                    label: 'IO some %',
+                    data: [],
+                    borderColor: '#ffc107',
+                    tension: 0.4,
+                    fill: false
+                }
+            ]
+        },
+        options: {
+            responsive: true,
+            maintainAspectRatio: false,
+            scales: {
+                y: {
+                    beginAtZero: true,
+                    suggestedMax: 10,
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0' }
+                },
+                x: {
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0' }
+                }
+            },
+            plugins: {
+                legend: { labels: { color: '#e8e8e8' } }
+            }
+        }
+    });
+
+    // Per-Core CPU Chart (stacked breakdown per logical processor)
+    const perCoreCtx = document.getElementById('perCoreChart').getContext('2d');
+    charts.perCore = new Chart(perCoreCtx, {
+        type: 'bar',
+        data: {
+            labels: [],
+            datasets: [
+                { label: 'User %', data: [], backgroundColor: '#00d9a5' },
+                { label: 'Kernel %', data: [], backgroundColor: '#9d4edd' },
+                { label: 'IO Wait %', data: [], backgroundColor: '#ffd166' },
+                { label: 'Steal %', data: [], backgroundColor: '#e63946' }
+            ]
+        },
+        options: {
+            responsive: true,
+            maintainAspectRatio: false,
+            animation: false,
+            scales: {
+                y: {
+                    stacked: true,
+                    beginAtZero: true,
+                    max: 100,
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0' }
+                },
+                x: {
+                    stacked: true,
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0' }
+                }
+            },
+            plugins: {
+                legend: { labels: { color: '#e8e8e8' } }
+            }
+        }
+    });
+
     // Memory Gauge
     const memGaugeCtx = document.getElementById('memoryGauge').getContext('2d');
     charts.memoryGauge = new Chart(memGaugeCtx, {
@@ -217,6 +310,75 @@ function initCharts() {
         }
     });
 
+    // Pinned Process History Chart
+    const processHistoryCtx = document.getElementById('processHistoryChart').getContext('2d');
+    charts.processHistory = new Chart(processHistoryCtx, {
+        type: 'line',
+        data: {
+            labels: [],
+            datasets: [
+                {
+                    label: 'CPU %',
+                    data: [],
+                    borderColor: '#00b4d8',
+                    tension: 0.4,
+                    fill: false,
+                    pointRadius: 0,
+                    yAxisID: 'y'
+                },
+                {
+                    label: 'Private MB',
+                    data: [],
+                    borderColor: '#00d9a5',
+                    tension: 0.4,
+                    fill: false,
+                    pointRadius: 0,
+                    yAxisID: 'mb'
+                },
+                {
+                    label: 'Connections',
+                    data: [],
+                    borderColor: '#ffc107',
+                    tension: 0.4,
+                    fill: false,
+                    pointRadius: 0,
+                    yAxisID: 'count'
+                }
+            ]
+        },
+        options: {
+            responsive: true,
+            maintainAspectRatio: false,
+            scales: {
+                y: {
+                    beginAtZero: true,
+                    position: 'left',
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0' }
+                },
+                mb: {
+                    beginAtZero: true,
+                    position: 'right',
+                    grid: { display: false },
+                    ticks: { color: '#a0a0a0' }
+                },
+                count: {
+                    beginAtZero: true,
+                    position: 'right',
+                    grid: { display: false },
+                    ticks: { color: '#a0a0a0' }
+                },
+                x: {
+                    grid: { color: 'rgba(255,255,255,0.1)' },
+                    ticks: { color: '#a0a0a0', maxTicksLimit: 10 }
+                }
+            },
+            plugins: {
+                legend: { labels: { color: '#e8e8e8' } }
+            }
+        }
+    });
+
     // Zero Window History Chart
     const zeroWindowCtx = document.getElementById('zeroWindowChart').getContext('2d');
     charts.zeroWindow = new Chart(zeroWindowCtx, {
@@ -277,15 +439,36 @@ async function startMonitoring() {
     }
     
     const interval = parseInt(intervalSelect.value);
+    const extendedTcpStats = document.getElementById('extendedTcpStats')?.checked || false;
+    const cgroupTarget = document.getElementById('cgroupTarget')?.value.trim() || '';
+    const cgroupPid = /^\d+$/.test(cgroupTarget) ? parseInt(cgroupTarget) : 0;
+    const cgroupPath = cgroupPid ? '' : cgroupTarget;
+    const diskLatencySla = parseInt(document.getElementById('diskLatencySla')?.value) || 0;
+    const testDuration = (parseInt(document.getElementById('testDuration')?.value) || 0) * 60;
+    const processMemoryLimit = parseInt(document.getElementById('processMemoryLimit')?.value) || 0;
+    networkInventory = null;
+    processEvents = [];
+    updateProcessEvents();
+    startedProcessQuery = {
+        sort: document.getElementById('processSort').value,
+        top: parseInt(document.getElementById('processTop').value) || 50,
+        watch: document.getElementById('processWatch').value.trim()
+    };
+    const processWatch = startedProcessQuery.watch.split(',').map(w => w.trim()).filter(Boolean);
     console.log('Starting monitoring with interval:', interval);
     
     try {
         const response = await fetch('/api/monitoring/start', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ interval: interval / 1000 })
+            body: JSON.stringify({ interval: interval / 1000, extendedTcpStats, cgroupPath, cgroupPid, diskLatencySla, testDuration,
+                processSort: startedProcessQuery.sort, processTop: startedProcessQuery.top, processWatch, processMemoryLimit })
         });
         console.log('Start monitoring response status:', response.status);
+        if (!response.ok) {
+            alert('Failed to start monitoring: ' + await response.text());
+            return;
+        }
         const result = await response.json();
         console.log('Start monitoring result:', result);
         updateStatusUI(true);
@@ -366,6 +549,17 @@ function updateDashboard(metrics) {
         document.getElementById('cpuIdle').textContent = metrics.cpu.idlePercent.toFixed(1) + '%';
         document.getElementById('coreCount').textContent = metrics.cpu.coreCount;
 
+        // Update per-core breakdown
+        if (metrics.cpu.cores) {
+            updatePerCore(metrics.cpu);
+        }
+
+        // Update scheduler panel
+        updateScheduler(metrics.cpu);
+
+        // Update virtualization panel
+        updateVirtualization(metrics.cpu);
+
         // Update CPU gauge
         charts.cpuGauge.data.datasets[0].data = [metrics.cpu.totalPercent, 100 - metrics.cpu.totalPercent];
         charts.cpuGauge.data.datasets[0].backgroundColor[0] = getColorForValue(metrics.cpu.totalPercent);
@@ -388,6 +582,12 @@ function updateDashboard(metrics) {
         pageFileProgress.style.width = pageFilePercent + '%';
         pageFileProgress.className = 'progress-fill ' + getColorClass(pageFilePercent);
 
+        // Update paging and kernel memory
+        updatePaging(metrics.memory);
+
+        // Update container memory
+        updateCgroupMemory(metrics.memory.cgroup);
+
         // Update Memory gauge
         charts.memoryGauge.data.datasets[0].data = [metrics.memory.usedPercent, 100 - metrics.memory.usedPercent];
         charts.memoryGauge.data.datasets[0].backgroundColor[0] = getColorForValue(metrics.memory.usedPercent);
@@ -408,6 +608,29 @@ function updateDashboard(metrics) {
         // Update Zero Window Status Panel
         updateWindowStatus(metrics.tcp);
 
+        // Update Connection Churn Panel
+        if (metrics.tcp.churn) {
+            updateConnectionChurn(metrics.tcp.churn);
+        }
+
+        // Update Listen Queues Panel
+        if (metrics.tcp.listeners) {
+            updateListeners(metrics.tcp.listeners);
+        }
+
+        // Update Ephemeral Port Panel
+        if (metrics.tcp.portExhaustion) {
+            updatePortExhaustion(metrics.tcp.portExhaustion);
+        }
+
+        // Refresh server-side endpoint aggregation
+        refreshEndpoints();
+
+        // Update Top Retransmitting Connections (extended stats only)
+        if (metrics.tcp.topRetransmitting) {
+            updateTopRetransmitting(metrics.tcp.topRetransmitting);
+        }
+
         // Update connection states chart
         const states = metrics.tcp.connectionStates || {};
         charts.connectionStates.data.datasets[0].data = [
@@ -431,12 +654,18 @@ function updateDashboard(metrics) {
     historyData.cpu.push(metrics.cpu?.totalPercent || 0);
     historyData.memory.push(metrics.memory?.usedPercent || 0);
     historyData.zeroWindows.push(metrics.tcp?.zeroWindowEvents || 0);
+    historyData.psiCpu.push(metrics.pressure?.cpu?.someIntervalPercent || 0);
+    historyData.psiMemory.push(metrics.pressure?.memory?.someIntervalPercent || 0);
+    historyData.psiIo.push(metrics.pressure?.io?.someIntervalPercent || 0);
 
     if (historyData.labels.length > MAX_HISTORY) {
         historyData.labels.shift();
         historyData.cpu.shift();
         historyData.memory.shift();
         historyData.zeroWindows.shift();
+        historyData.psiCpu.shift();
+        historyData.psiMemory.shift();
+        historyData.psiIo.shift();
     }
 
     // Update zero window chart
@@ -462,20 +691,49 @@ function updateDashboard(metrics) {
     charts.cpu.data.datasets[0].data = historyData.cpu;
     charts.cpu.update();
 
+    // Update pressure stall chart
+    charts.pressure.data.labels = historyData.labels;
+    charts.pressure.data.datasets[0].data = historyData.psiCpu;
+    charts.pressure.data.datasets[1].data = historyData.psiMemory;
+    charts.pressure.data.datasets[2].data = historyData.psiIo;
+    charts.pressure.update();
+    updatePressureSummary(metrics.pressure);
+
     // Update disks
     if (metrics.disk && metrics.disk.disks) {
         updateDiskGrid(metrics.disk.disks);
     }
+    if (metrics.disk && metrics.disk.devices) {
+        updateDiskDevices(metrics.disk.devices);
+    }
 
     // Update network interfaces
     if (metrics.network && metrics.network.interfaces) {
         updateNetworkGrid(metrics.network.interfaces);
     }
 
-    // Update processes
-    if (metrics.processes) {
+    // Reload the adapter inventory on the first sample and whenever it changes
+    if (metrics.network && (!networkInventory || metrics.network.adapterChanges?.length)) {
+        loadNetworkInventory();
+    }
+
+    // Update processes; a filtered, tree or grouped view is fetched with its own query
+    if (document.getElementById('processView').value !== 'list' || processQueryParams()) {
+        refreshProcesses();
+    } else if (metrics.processes) {
         updateProcessesTable(metrics.processes);
     }
+    updateProcessLeaks(metrics.processLeaks || []);
+    if (metrics.processEvents?.length) {
+        processEvents = processEvents.concat(metrics.processEvents).slice(-MAX_PROCESS_EVENTS);
+        updateProcessEvents();
+    }
+
+    // Pinned process series are refreshed every 5 seconds
+    if (Date.now() - pinnedRefreshedAt > 5000) {
+        pinnedRefreshedAt = Date.now();
+        loadPinnedProcesses();
+    }
 
     // Check for alerts
     checkAlerts(metrics);
@@ -525,6 +783,9 @@ function renderConnectionsTable(connections) {
             <td>${conn.remoteAddress}:${conn.remotePort}</td>
             <td><span class="state-badge ${getStateBadgeClass(conn.state)}">${conn.state}</span></td>
             <td>${conn.pid}</td>
+            <td>${conn.ageSeconds ? formatDuration(conn.ageSeconds) : '--'}</td>
+            <td>${conn.stats ? conn.stats.smoothedRtt.toFixed(1) + ' ms' : '--'}</td>
+            <td>${conn.stats ? conn.stats.segmentsRetransmitted : '--'}</td>
         `;
         tbody.appendChild(row);
     });
@@ -532,11 +793,36 @@ function renderConnectionsTable(connections) {
     // Show count info
     if (connections.length > 200) {
         const row = document.createElement('tr');
-        row.innerHTML = `<td colspan="4" style="text-align: center; color: var(--text-secondary);">... and ${connections.length - 200} more connections</td>`;
+        row.innerHTML = `<td colspan="7" style="text-align: center; color: var(--text-secondary);">... and ${connections.length - 200} more connections</td>`;
         tbody.appendChild(row);
     }
 }
 
+function formatLatencyPercentiles(p50, p95, p99) {
+    if (p50 === undefined) return '-';
+    return `${p50.toFixed(1)} / ${p95.toFixed(1)} / ${p99.toFixed(1)} ms`;
+}
+
+function updateDiskDevices(devices) {
+    const tbody = document.querySelector('#diskDevicesTable tbody');
+    tbody.innerHTML = devices.map(d => `
+        <tr>
+            <td>${d.name}</td>
+            <td>${formatBytes(d.readBytesPerSec)}/s</td>
+            <td>${formatBytes(d.writeBytesPerSec)}/s</td>
+            <td>${d.readsPerSec.toFixed(0)} / ${d.writesPerSec.toFixed(0)}</td>
+            <td>${d.avgReadLatency.toFixed(1)} / ${d.avgWriteLatency.toFixed(1)} ms</td>
+            <td>${d.avgQueueLength.toFixed(2)} (${d.queueLength} now)</td>
+            <td>${d.busyPercent.toFixed(1)}%</td>
+            <td>${formatLatencyPercentiles(d.latency?.readP50, d.latency?.readP95, d.latency?.readP99)}</td>
+            <td>${formatLatencyPercentiles(d.latency?.writeP50, d.latency?.writeP95, d.latency?.writeP99)}</td>
+            <td style="color: ${d.latency?.intervalsOverSla ? '#e63946' : 'inherit'}">
+                ${d.latency ? `${d.latency.intervalsOverSla} / ${d.latency.intervals}` : '-'}
+            </td>
+        </tr>
+    `).join('');
+}
+
 function updateDiskGrid(disks) {
     const grid = document.getElementById('diskGrid');
     grid.innerHTML = '';
@@ -546,7 +832,11 @@ function updateDiskGrid(disks) {
         card.className = 'card';
         card.innerHTML = `
             <div class="card-header">
-                <span class="card-title">💾 ${disk.name}</span>
+                <span class="card-title">💾 ${disk.name}${disk.device ? ` <span style="font-size: 12px; color: var(--text-secondary);">(${disk.device})</span>` : ''}</span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">Filesystem</span>
+                <span class="metric-value">${disk.fileSystem || '-'}${disk.readOnly ? ' (read-only)' : ''}</span>
             </div>
             <div class="metric-row">
                 <span class="metric-label">Total</span>
@@ -556,6 +846,39 @@ function updateDiskGrid(disks) {
                 <span class="metric-label">Free</span>
                 <span class="metric-value">${formatBytes(disk.freeBytes)}</span>
             </div>
+            <div class="metric-row">
+                <span class="metric-label">Read / Write</span>
+                <span class="metric-value">${formatBytes(disk.readBytesPerSec)}/s / ${formatBytes(disk.writeBytesPerSec)}/s</span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">IOPS (R/W)</span>
+                <span class="metric-value">${disk.readsPerSec.toFixed(0)} / ${disk.writesPerSec.toFixed(0)}</span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">Latency (R/W)</span>
+                <span class="metric-value" style="color: ${Math.max(disk.avgReadLatency, disk.avgWriteLatency) > 20 ? '#e63946' : 'inherit'}">
+                    ${disk.avgReadLatency.toFixed(1)} ms / ${disk.avgWriteLatency.toFixed(1)} ms
+                </span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">Queue / Busy</span>
+                <span class="metric-value">${disk.avgQueueLength.toFixed(2)} / ${disk.busyPercent.toFixed(1)}%</span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">p99 Latency (R/W)</span>
+                <span class="metric-value">${disk.latency ? `${disk.latency.readP99.toFixed(1)} ms / ${disk.latency.writeP99.toFixed(1)} ms` : '-'}</span>
+            </div>
+            ${disk.totalInodes ? `
+            <div class="metric-row">
+                <span class="metric-label">Inodes Used</span>
+                <span class="metric-value">${formatNumber(disk.totalInodes - disk.freeInodes)} / ${formatNumber(disk.totalInodes)} (${disk.inodesUsedPercent.toFixed(1)}%)</span>
+            </div>` : ''}
+            <div class="metric-row">
+                <span class="metric-label">Growth / Time to Full</span>
+                <span class="metric-value" style="color: ${disk.fillsBeforeTestEnd ? '#e63946' : 'inherit'}">
+                    ${formatBytes(Math.max(disk.growthBytesPerSec, 0))}/s / ${disk.secondsToFull >= 0 ? formatDuration(disk.secondsToFull) : 'n/a'}
+                </span>
+            </div>
             <div>
                 <span class="metric-label">Usage: ${disk.usedPercent.toFixed(1)}%</span>
                 <div class="progress-bar">
@@ -591,14 +914,37 @@ function updateNetworkGrid(interfaces) {
                 <span class="metric-label">Received</span>
                 <span class="metric-value">${formatBytes(iface.bytesRecvPerSec)}/s</span>
             </div>
+            <div class="metric-row">
+                <span class="metric-label">Packets In/Out</span>
+                <span class="metric-value">${formatNumber(iface.packetsRecvPerSec)}/${formatNumber(iface.packetsSentPerSec)} /s</span>
+            </div>
             <div class="metric-row">
                 <span class="metric-label">Errors In/Out</span>
-                <span class="metric-value">${iface.inErrors}/${iface.outErrors}</span>
+                <span class="metric-value" style="color: ${(iface.inErrorsPerSec + iface.outErrorsPerSec) > 0 ? '#e63946' : 'inherit'}">${iface.inErrors}/${iface.outErrors} (${(iface.inErrorsPerSec || 0).toFixed(1)}/${(iface.outErrorsPerSec || 0).toFixed(1)} /s)</span>
             </div>
             <div class="metric-row">
                 <span class="metric-label">Discards In/Out</span>
-                <span class="metric-value" style="color: ${(iface.inDiscards + iface.outDiscards) > 0 ? '#e63946' : 'inherit'}">${iface.inDiscards}/${iface.outDiscards}</span>
+                <span class="metric-value" style="color: ${(iface.inDiscards + iface.outDiscards) > 0 ? '#e63946' : 'inherit'}">${iface.inDiscards}/${iface.outDiscards} (${(iface.inDiscardsPerSec || 0).toFixed(1)}/${(iface.outDiscardsPerSec || 0).toFixed(1)} /s)</span>
             </div>
+            ${iface.bufferExhaustion || iface.linkErrors ? `
+            <div class="metric-row">
+                <span class="metric-label">Loss In/Out</span>
+                <span class="metric-value" style="color: #e63946">${(iface.inErrorPercent + iface.inDiscardPercent).toFixed(2)}% / ${(iface.outErrorPercent + iface.outDiscardPercent).toFixed(2)}%</span>
+            </div>
+            <div class="metric-row">
+                <span class="metric-label">Diagnosis</span>
+                <span class="metric-value" style="color: #e63946">${[iface.bufferExhaustion ? 'Buffer exhaustion' : '', iface.linkErrors ? 'Link errors' : ''].filter(Boolean).join(' + ')}</span>
+            </div>` : ''}
+            ${(iface.dropReasons || []).filter(r => r.perSec > 0).map(r => `
+            <div class="metric-row">
+                <span class="metric-label" style="padding-left: 12px;">${r.reason} (${r.category})</span>
+                <span class="metric-value">${r.perSec.toFixed(1)}/s</span>
+            </div>`).join('')}
+            ${iface.counterBits === 32 || iface.counterResets > 0 ? `
+            <div class="metric-row">
+                <span class="metric-label">Counters</span>
+                <span class="metric-value" style="color: #f4a261">${iface.counterBits}-bit, ${iface.counterWraps} wraps, ${iface.counterResets} resets</span>
+            </div>` : ''}
             <div>
                 <span class="metric-label">Utilization: ${(iface.utilization || 0).toFixed(1)}%</span>
                 <div class="progress-bar">
@@ -610,26 +956,451 @@ function updateNetworkGrid(interfaces) {
     });
 }
 
+let networkInventory = null;
+
+async function loadNetworkInventory() {
+    try {
+        const response = await fetch('/api/metrics/network/adapters');
+        if (!response.ok) return;
+        networkInventory = await response.json();
+        updateNetworkInventory(networkInventory);
+    } catch (error) {
+        console.error('Failed to load adapter inventory:', error);
+    }
+}
+
+function updateNetworkInventory(inventory) {
+    document.getElementById('adapterBaseline').textContent =
+        inventory.baselineAt ? `(baseline ${new Date(inventory.baselineAt).toLocaleTimeString()})` : '';
+
+    document.querySelector('#adaptersTable tbody').innerHTML = (inventory.current || []).map(a => `
+        <tr>
+            <td title="${a.description}">${a.name}</td>
+            <td style="color: ${a.isUp ? '#00d9a5' : '#e63946'}">${a.isUp ? 'UP' : 'DOWN'} ${a.isUp ? formatSpeed(a.speed) : ''}</td>
+            <td>${a.mac || '-'}</td>
+            <td>${a.mtu}</td>
+            <td>${(a.addresses || []).join('<br>') || '-'}</td>
+            <td>${a.duplex}</td>
+            <td>${a.driver || '-'}${a.driverVersion ? ` ${a.driverVersion}` : ''}</td>
+            <td>${Object.entries(a.offloads || {}).filter(([, on]) => on).map(([name]) => name).join(', ') || '-'}</td>
+            <td>${a.rxQueues} / ${a.txQueues}</td>
+        </tr>
+    `).join('');
+
+    const changes = (inventory.changes || []).slice().reverse();
+    document.querySelector('#adapterChangesTable tbody').innerHTML = changes.length === 0
+        ? '<tr><td colspan="5" class="no-data">No changes since the baseline</td></tr>'
+        : changes.map(c => `
+        <tr>
+            <td>${new Date(c.timestamp).toLocaleTimeString()}</td>
+            <td>${c.adapter}</td>
+            <td>${c.field}</td>
+            <td>${c.old || '-'}</td>
+            <td>${c.new || '-'}</td>
+        </tr>
+    `).join('');
+}
+
+let processesRequest = null;
+let startedProcessQuery = { sort: 'memory', top: 50, watch: '' };
+
+// processQueryParams returns the query for /api/metrics/processes, or null
+// when the streamed list (ranked as requested at start) matches the controls
+function processQueryParams() {
+    const params = new URLSearchParams();
+    const sort = document.getElementById('processSort').value;
+    const top = parseInt(document.getElementById('processTop').value) || 50;
+    const regex = document.getElementById('processFilter').value.trim();
+    const user = document.getElementById('processUser').value.trim();
+    const watch = document.getElementById('processWatch').value.trim();
+    if (regex) params.set('regex', regex);
+    if (user) params.set('user', user);
+    if (!isRunning || regex || user || sort !== startedProcessQuery.sort || top !== startedProcessQuery.top || watch !== startedProcessQuery.watch) {
+        params.set('sort', sort);
+        params.set('top', top);
+        if (watch) params.set('watch', watch);
+        return params;
+    }
+    return null;
+}
+
+async function refreshProcesses() {
+    if (processesRequest) return;
+
+    const view = document.getElementById('processView').value;
+    let url;
+    if (view === 'tree') {
+        url = '/api/metrics/processes/tree';
+    } else if (view !== 'list') {
+        const params = new URLSearchParams({ by: view, sort: document.getElementById('processSort').value });
+        const pattern = document.getElementById('processPattern').value.trim();
+        if (view === 'pattern') {
+            if (!pattern) return;
+            params.set('pattern', pattern);
+        }
+        url = '/api/metrics/processes/groups?' + params;
+    } else {
+        const params = processQueryParams() || new URLSearchParams({
+            sort: startedProcessQuery.sort, top: startedProcessQuery.top, watch: startedProcessQuery.watch
+        });
+        url = '/api/metrics/processes?' + params;
+    }
+    processesRequest = fetch(url);
+    try {
+        const response = await processesRequest;
+        const result = await response.json();
+        if (!response.ok) {
+            console.error('Process query failed:', result.error);
+            return;
+        }
+        if (view === 'tree') {
+            updateProcessesTable(flattenProcessTree(result || [], 0, []));
+        } else if (view !== 'list') {
+            updateProcessGroups(result || []);
+        } else {
+            updateProcessesTable(result || []);
+        }
+    } catch (error) {
+        console.error('Failed to fetch processes:', error);
+    } finally {
+        processesRequest = null;
+    }
+}
+
+// flattenProcessTree lists tree nodes depth first, marking their depth
+function flattenProcessTree(nodes, depth, list) {
+    nodes.forEach(node => {
+        list.push({ ...node, depth });
+        flattenProcessTree(node.children || [], depth + 1, list);
+    });
+    return list;
+}
+
+// escapeAttr makes text safe inside a double-quoted HTML attribute
+function escapeAttr(text) {
+    return String(text).replace(/&/g, '&amp;').replace(/"/g, '&quot;').replace(/</g, '&lt;');
+}
+
+// processTooltip describes who started a process, how and where
+function processTooltip(proc) {
+    return escapeAttr([
+        proc.user && `User: ${proc.user}`,
+        proc.startTime && !proc.startTime.startsWith('0001') && `Started: ${new Date(proc.startTime).toLocaleString()}`,
+        proc.exePath && `Executable: ${proc.exePath}`,
+        proc.workingDir && `Directory: ${proc.workingDir}`,
+        proc.commandLine && `Command line: ${proc.commandLine}`
+    ].filter(Boolean).join('\n'));
+}
+
+// processConnections shows the TCP connection count of a process or group,
+// with its CLOSE_WAIT sockets and the count by state on hover
+function processConnections(item) {
+    const states = item.connectionStates || {};
+    const title = Object.entries(states).sort((a, b) => b[1] - a[1]).map(([state, n]) => `${state}: ${n}`).join('\n');
+    const closeWait = states['CLOSE_WAIT'] ? ` <span style="color: #e63946;">(${states['CLOSE_WAIT']} CW)</span>` : '';
+    return `<td title="${escapeAttr(title)}">${item.connections}${closeWait}</td>`;
+}
+
+// processRemotes shows the distinct remote endpoints of a process, the busiest on hover
+function processRemotes(proc) {
+    if (!proc.remoteEndpoints) return '<td>-</td>';
+    const title = (proc.topRemoteEndpoints || []).map(e => `${e.inbound ? `client ${e.address}` : `${e.address}:${e.port}`} (${e.connections})`).join('\n');
+    return `<td title="${escapeAttr(title)}">${proc.remoteEndpoints} (${proc.remoteAddresses} hosts)</td>`;
+}
+
+function showProcessTable(id) {
+    document.getElementById('processesTable').style.display = id === 'processesTable' ? '' : 'none';
+    document.getElementById('processGroupsTable').style.display = id === 'processGroupsTable' ? '' : 'none';
+}
+
+// updateProcessGroups fills the groups table; pattern keys come from command
+// lines, so they are escaped
+function updateProcessGroups(groups) {
+    showProcessTable('processGroupsTable');
+    document.getElementById('processCount').textContent = `(${groups.length} groups)`;
+    document.querySelector('#processGroupsTable tbody').innerHTML = groups.map(g => `
+        <tr>
+            <td title="${escapeAttr(`PIDs: ${g.pids.join(', ')}`)}">${escapeAttr(g.key)}</td>
+            <td>${g.processes}</td>
+            <td>${g.cpuPercent.toFixed(1)}%</td>
+            <td>${formatBytes(g.memoryBytes)}</td>
+            <td>${formatBytes(g.privateBytes)}</td>
+            <td>${formatBytes(Math.round(g.readBytesPerSec))}/s</td>
+            <td>${formatBytes(Math.round(g.writeBytesPerSec))}/s</td>
+            <td>${g.threadCount}</td>
+            <td>${g.handleCount}</td>
+            ${processConnections(g)}
+        </tr>
+    `).join('');
+}
+
 function updateProcessesTable(processes) {
+    showProcessTable('processesTable');
+    document.getElementById('processCount').textContent = `(${processes.length})`;
     const tbody = document.querySelector('#processesTable tbody');
     tbody.innerHTML = '';
 
-    processes.slice(0, 30).forEach(proc => {
+    processes.forEach(proc => {
         const row = document.createElement('tr');
+        if (proc.watched) row.style.background = 'rgba(69, 123, 157, 0.2)';
+        const indent = proc.depth ? `<span style="padding-left: ${proc.depth * 16}px;">└ </span>` : '';
         row.innerHTML = `
-            <td>${proc.pid}</td>
-            <td>${proc.name}</td>
+            <td style="cursor: pointer; text-decoration: underline dotted;" title="Sample threads" onclick="sampleThreads(${proc.pid})">${proc.pid}</td>
+            <td>${proc.parentPid}</td>
+            <td title="${processTooltip(proc)}">${indent}${proc.watched ? '👁 ' : ''}${proc.name}</td>
+            <td>${proc.cpuPercent.toFixed(1)}% (${proc.userPercent.toFixed(1)}/${proc.kernelPercent.toFixed(1)})</td>
             <td>${formatBytes(proc.memoryBytes)}</td>
             <td>${proc.memoryPercent.toFixed(2)}%</td>
+            <td>${formatBytes(proc.privateBytes)}</td>
+            <td>${formatBytes(Math.round(proc.readBytesPerSec))}/s</td>
+            <td>${formatBytes(Math.round(proc.writeBytesPerSec))}/s</td>
+            <td>${formatNumber(Math.round(proc.pageFaultsPerSec))}</td>
+            <td>${proc.threadCount}</td>
+            <td>${proc.handleCount}</td>
+            ${processConnections(proc)}
+            <td>${(proc.listeningPorts || []).join(', ') || '-'}</td>
+            ${processRemotes(proc)}
         `;
         tbody.appendChild(row);
     });
 }
 
+let pinnedRefreshedAt = 0;
+let selectedPinnedPid = null;
+
+async function loadProcessPins() {
+    try {
+        const response = await fetch('/api/processes/pins');
+        if (!response.ok) return;
+        const result = await response.json();
+        document.getElementById('processPins').value = (result.pins || []).join(', ');
+        updatePinnedProcesses(result.processes || []);
+    } catch (error) {
+        console.error('Failed to load pinned processes:', error);
+    }
+}
+
+async function saveProcessPins() {
+    const pins = document.getElementById('processPins').value.split(',').map(p => p.trim()).filter(Boolean);
+    try {
+        const response = await fetch('/api/processes/pins', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ pins })
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            alert('Failed to pin processes: ' + result.error);
+            return;
+        }
+        updatePinnedProcesses(result.processes || []);
+    } catch (error) {
+        console.error('Failed to pin processes:', error);
+    }
+}
+
+async function loadPinnedProcesses() {
+    try {
+        const response = await fetch('/api/processes/pins');
+        if (!response.ok) return;
+        const result = await response.json();
+        updatePinnedProcesses(result.processes || []);
+    } catch (error) {
+        console.error('Failed to load pinned processes:', error);
+    }
+}
+
+function updatePinnedProcesses(processes) {
+    const tbody = document.querySelector('#pinnedProcessesTable tbody');
+    if (processes.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="5" class="no-data">No pinned processes</td></tr>';
+        return;
+    }
+    tbody.innerHTML = processes.map(p => {
+        const pid = p.pids[p.pids.length - 1];
+        const selected = pid === selectedPinnedPid ? 'background: rgba(69, 123, 157, 0.2);' : '';
+        return `
+        <tr style="cursor: pointer; ${selected}" onclick="loadProcessHistory(${pid})" title="${escapeAttr(p.commandLine || '')}">
+            <td>${escapeAttr(p.pin)}</td>
+            <td>${escapeAttr(p.name)}</td>
+            <td>${p.pids.join(' → ')}</td>
+            <td style="color: ${p.running ? '#00d9a5' : '#e63946'}">${p.running ? 'Running' : 'Exited'}${p.pids.length > 1 ? ` (${p.pids.length - 1} restarts)` : ''}</td>
+            <td>${p.sampleCount}</td>
+        </tr>`;
+    }).join('');
+    if (selectedPinnedPid !== null) {
+        loadProcessHistory(selectedPinnedPid);
+    }
+}
+
+async function loadProcessHistory(pid) {
+    try {
+        const response = await fetch(`/api/processes/${pid}/history`);
+        if (!response.ok) return;
+        const history = await response.json();
+        selectedPinnedPid = history.pids[history.pids.length - 1];
+        const samples = history.samples || [];
+        const chart = charts.processHistory;
+        chart.data.labels = samples.map(s => new Date(s.timestamp).toLocaleTimeString());
+        chart.data.datasets[0].data = samples.map(s => s.cpuPercent);
+        chart.data.datasets[1].data = samples.map(s => s.privateBytes / 1048576);
+        chart.data.datasets[2].data = samples.map(s => s.connections);
+        chart.update('none');
+    } catch (error) {
+        console.error('Failed to load process history:', error);
+    }
+}
+
+// Process lifecycle events of the session, oldest first
+const MAX_PROCESS_EVENTS = 1000;
+let processEvents = [];
+
+async function loadProcessEvents() {
+    try {
+        const response = await fetch('/api/processes/events');
+        if (!response.ok) return;
+        const result = await response.json();
+        processEvents = result.events || [];
+        updateProcessEvents();
+    } catch (error) {
+        console.error('Failed to load process events:', error);
+    }
+}
+
+// formatExitCode shows Windows NTSTATUS codes in hex and others in decimal
+function formatExitCode(code) {
+    if (code === undefined || code === null) return '-';
+    return code >= 0xC0000000 ? '0x' + code.toString(16).toUpperCase() : String(code);
+}
+
+function updateProcessEvents() {
+    const tbody = document.querySelector('#processEventsTable tbody');
+    if (processEvents.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="7" class="no-data">No process events</td></tr>';
+        return;
+    }
+    const colors = { start: '#00d9a5', exit: 'inherit', crash: '#e63946', restartLoop: '#f4a261' };
+    tbody.innerHTML = processEvents.slice().reverse().map(e => {
+        let details = e.reason || '';
+        if (e.type === 'restartLoop') {
+            details = `${e.restarts} restarts within 5 min`;
+        } else if (e.lifetimeSeconds) {
+            details += `${details ? ', ' : ''}ran ${formatDuration(e.lifetimeSeconds)}`;
+        }
+        return `
+        <tr style="color: ${colors[e.type] || 'inherit'}" title="${escapeAttr(e.commandLine || '')}">
+            <td>${new Date(e.timestamp).toLocaleTimeString()}</td>
+            <td>${e.type}</td>
+            <td>${e.pid}</td>
+            <td>${e.parentPid || '-'}</td>
+            <td>${escapeAttr(e.name)}</td>
+            <td>${formatExitCode(e.exitCode)}</td>
+            <td>${details}</td>
+        </tr>`;
+    }).join('');
+}
+
+async function sampleThreads(pid) {
+    const input = document.getElementById('threadPid');
+    if (pid) input.value = pid;
+    pid = parseInt(input.value);
+    if (!pid) return;
+    const windowMs = document.getElementById('threadWindow').value;
+    const summary = document.getElementById('threadSummary');
+    summary.textContent = `(sampling PID ${pid} for ${windowMs / 1000} s...)`;
+    try {
+        const response = await fetch(`/api/processes/${pid}/threads?window=${windowMs}`);
+        if (!response.ok) {
+            summary.textContent = `(${(await response.json()).error || response.statusText})`;
+            return;
+        }
+        updateThreads(await response.json());
+    } catch (error) {
+        console.error('Failed to sample threads:', error);
+    }
+}
+
+function updateThreads(result) {
+    // Thread names are set by the sampled process, so they are escaped;
+    // the summary is set as text
+    document.getElementById('threadSummary').textContent =
+        `(${result.name} PID ${result.pid}: ${result.threadCount} threads, ${result.cpuPercent.toFixed(1)}% CPU, ` +
+        `${result.hotThreads} hot, ${result.threadsStarted} started / ${result.threadsExited} exited in ${result.windowSeconds.toFixed(1)} s)`;
+    const tbody = document.querySelector('#threadsTable tbody');
+    if (result.threads.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="7" class="no-data">No threads</td></tr>';
+        return;
+    }
+    tbody.innerHTML = result.threads.map(t => {
+        const switches = t.voluntarySwitchesPerSec || t.involuntarySwitchesPerSec
+            ? ` title="Voluntary ${formatNumber(Math.round(t.voluntarySwitchesPerSec || 0))}/s, involuntary ${formatNumber(Math.round(t.involuntarySwitchesPerSec || 0))}/s"` : '';
+        return `
+        <tr style="${t.hot ? 'background: rgba(230, 57, 70, 0.2); font-weight: 600;' : ''}">
+            <td title="nid=0x${t.tid.toString(16)}">${t.hot ? '🔥 ' : ''}${t.tid}</td>
+            <td>${t.name ? escapeAttr(t.name) : '-'}</td>
+            <td>${escapeAttr(t.state)}${t.waitReason ? ` (${escapeAttr(t.waitReason)})` : ''}</td>
+            <td>${t.cpuPercent.toFixed(1)}% (${t.userPercent.toFixed(1)}/${t.kernelPercent.toFixed(1)})</td>
+            <td>${t.cpuSeconds < 60 ? t.cpuSeconds.toFixed(2) + 's' : formatDuration(t.cpuSeconds)}</td>
+            <td${switches}>${formatNumber(Math.round(t.contextSwitchesPerSec))}</td>
+            <td>${t.priority}</td>
+        </tr>`;
+    }).join('');
+}
+
+// formatLeakValue formats a leaking resource amount in its unit
+function formatLeakValue(resource, value) {
+    if (resource === 'privateBytes' || resource === 'workingSet') {
+        return formatBytes(Math.round(value));
+    }
+    return formatNumber(Math.round(value));
+}
+
+function updateProcessLeaks(leaks) {
+    const tbody = document.querySelector('#processLeaksTable tbody');
+    if (leaks.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="9" class="no-data">No leaks detected</td></tr>';
+        return;
+    }
+    tbody.innerHTML = leaks.map(l => `
+        <tr style="color: ${l.severity === 'critical' ? '#e63946' : 'inherit'}">
+            <td>${l.pid}</td>
+            <td>${l.name}</td>
+            <td>${l.resource}</td>
+            <td>${formatLeakValue(l.resource, l.current)}</td>
+            <td>${formatLeakValue(l.resource, l.growthPerSec * 3600)}</td>
+            <td>${l.growthPercent ? l.growthPercent.toFixed(1) + '%' : '-'}</td>
+            <td>${formatDuration(l.windowSeconds)}</td>
+            <td>${(l.confidence * 100).toFixed(2)}%</td>
+            <td>${l.secondsToLimit >= 0 ? `${formatDuration(l.secondsToLimit)} (${formatLeakValue(l.resource, l.limit)})` : '-'}</td>
+        </tr>
+    `).join('');
+}
+
 function checkAlerts(metrics) {
     const container = document.getElementById('alertsContainer');
     const alerts = [];
 
+    // Check for a saturated core hidden by the average
+    const hotCores = metrics.cpu?.hotCores || [];
+    if (hotCores.length > 0) {
+        alerts.push({ level: 'warning', message: `Hot core: CPU ${hotCores.join(', ')} saturated while average is ${metrics.cpu.totalPercent.toFixed(1)}% (single-threaded bottleneck?)` });
+    }
+
+    // Check run queue (sustained > 2 waiting threads per core = CPU contention)
+    if (metrics.cpu && metrics.cpu.processorQueueLength > 2 * metrics.cpu.coreCount) {
+        alerts.push({ level: 'warning', message: `Processor queue length ${metrics.cpu.processorQueueLength} exceeds 2 per core (scheduler contention)` });
+    }
+
+    // Check steal time (hypervisor withholding CPU from this guest)
+    if (metrics.cpu && metrics.cpu.stealPercent > 10) {
+        alerts.push({ level: 'warning', message: `CPU steal ${metrics.cpu.stealPercent.toFixed(1)}%: the hypervisor is overcommitted` });
+    }
+
+    // Check cgroup CPU throttling (container quota exhausted)
+    if (metrics.cpu?.cgroup?.throttledPeriods > 0) {
+        alerts.push({ level: 'warning', message: `Cgroup CPU throttled in ${metrics.cpu.cgroup.throttledPeriods} of ${metrics.cpu.cgroup.periods} periods (quota ${metrics.cpu.cgroup.quotaCores.toFixed(2)} cores)` });
+    }
+
     // Check CPU
     if (metrics.cpu && metrics.cpu.totalPercent > 90) {
         alerts.push({ level: 'critical', message: `CPU usage critical: ${metrics.cpu.totalPercent.toFixed(1)}%` });
@@ -637,6 +1408,104 @@ function checkAlerts(metrics) {
         alerts.push({ level: 'warning', message: `CPU usage high: ${metrics.cpu.totalPercent.toFixed(1)}%` });
     }
 
+    // Check disk latency and saturation on physical devices
+    (metrics.disk?.devices || []).forEach(d => {
+        const latency = Math.max(d.avgReadLatency, d.avgWriteLatency);
+        if (latency > 50) {
+            alerts.push({ level: 'critical', message: `Disk ${d.name} latency ${latency.toFixed(1)} ms` });
+        } else if (latency > 20) {
+            alerts.push({ level: 'warning', message: `Disk ${d.name} latency ${latency.toFixed(1)} ms` });
+        }
+        const lat = d.latency;
+        if (lat && Math.max(lat.readP99, lat.writeP99) > lat.slaMs) {
+            alerts.push({ level: 'warning', message: `Disk ${d.name} p99 latency ${Math.max(lat.readP99, lat.writeP99).toFixed(1)} ms exceeds the ${lat.slaMs} ms SLA (${lat.intervalsOverSla} of ${lat.intervals} intervals over)` });
+        }
+        if (d.busyPercent > 90 && d.avgQueueLength > 2) {
+            alerts.push({ level: 'warning', message: `Disk ${d.name} saturated: ${d.busyPercent.toFixed(0)}% busy, queue ${d.avgQueueLength.toFixed(1)}` });
+        }
+    });
+
+    // Check interface errors, discards and counter resets
+    (metrics.network?.interfaces || []).forEach(i => {
+        const discards = (i.inDiscardsPerSec || 0) + (i.outDiscardsPerSec || 0);
+        const errors = (i.inErrorsPerSec || 0) + (i.outErrorsPerSec || 0);
+        const lossPercent = Math.max(i.inErrorPercent + i.inDiscardPercent, i.outErrorPercent + i.outDiscardPercent);
+        const level = lossPercent > 1 ? 'critical' : 'warning';
+        if (i.bufferExhaustion) {
+            alerts.push({ level, message: `Interface ${i.name}: NIC buffer exhaustion, ${discards.toFixed(1)} discards/s (${lossPercent.toFixed(2)}% of packets) - check ring sizes and interrupt load` });
+        }
+        if (i.linkErrors) {
+            alerts.push({ level, message: `Interface ${i.name}: link errors, ${errors.toFixed(1)} errors/s - check cabling, optics and duplex` });
+        }
+        if (i.counterResets > 0) {
+            alerts.push({ level: 'warning', message: `Interface ${i.name} counters were reset ${i.counterResets} time(s) - driver reload or adapter reset during the test?` });
+        }
+    });
+
+    // Check adapter link and configuration changes of the last 5 minutes
+    const recentChanges = (networkInventory?.changes || []).filter(c => Date.now() - new Date(c.timestamp) < 5 * 60 * 1000);
+    recentChanges.forEach(c => {
+        const level = (c.field === 'link' && c.new === 'down') || c.field === 'removed' ? 'critical' : 'warning';
+        alerts.push({ level, message: `Adapter ${c.adapter} ${c.field} changed${c.old ? ` from ${c.old}` : ''}${c.new ? ` to ${c.new}` : ''} at ${new Date(c.timestamp).toLocaleTimeString()}` });
+    });
+
+    // Check volume capacity forecast and inode exhaustion
+    (metrics.disk?.disks || []).forEach(d => {
+        if (d.fillsBeforeTestEnd) {
+            alerts.push({ level: 'critical', message: `Volume ${d.name} will be full in ${formatDuration(d.secondsToFull)}, before the test ends (growing ${formatBytes(d.growthBytesPerSec)}/s)` });
+        }
+        if (d.inodesUsedPercent > 90) {
+            alerts.push({ level: 'warning', message: `Volume ${d.name} has used ${d.inodesUsedPercent.toFixed(1)}% of its inodes` });
+        }
+    });
+
+    // Check processes with leaking memory, handles or threads
+    (metrics.processLeaks || []).forEach(l => {
+        const limit = l.secondsToLimit >= 0 ? `, limit ${formatLeakValue(l.resource, l.limit)} in ${formatDuration(l.secondsToLimit)}` : '';
+        alerts.push({ level: l.severity, message: `Possible ${l.resource} leak in ${l.name} (PID ${l.pid}): growing ${formatLeakValue(l.resource, l.growthPerSec * 3600)}/h for ${formatDuration(l.windowSeconds)}${limit}` });
+    });
+
+    // Check processes that crashed or keep restarting in the last 5 minutes
+    const recentEvents = processEvents.filter(e => Date.now() - new Date(e.timestamp) < 300000);
+    recentEvents.filter(e => e.type === 'restartLoop').forEach(e => {
+        alerts.push({ level: 'critical', message: `${e.name} is in a restart loop: ${e.restarts} restarts within 5 minutes (now PID ${e.pid})` });
+    });
+    const crashes = recentEvents.filter(e => e.type === 'crash');
+    if (crashes.length > 0) {
+        const last = crashes[crashes.length - 1];
+        const more = crashes.length > 1 ? ` (${crashes.length} crashes in 5 min)` : '';
+        alerts.push({ level: 'warning', message: `${last.name} (PID ${last.pid}) crashed: ${last.reason || 'exit code ' + formatExitCode(last.exitCode)}${more}` });
+    }
+
+    // Check paging (pages moved to and from disk = memory pressure)
+    const pagingRate = (metrics.memory?.pagesInputPerSec || 0) + (metrics.memory?.pagesOutputPerSec || 0);
+    if (pagingRate > 1000) {
+        alerts.push({ level: 'critical', message: `Heavy paging: ${formatNumber(pagingRate)} pages/sec to and from disk` });
+    } else if (pagingRate > 100) {
+        alerts.push({ level: 'warning', message: `Paging to disk: ${formatNumber(pagingRate)} pages/sec (memory pressure)` });
+    }
+
+    // Check pressure stalls (tasks waiting for CPU, memory or IO)
+    for (const [name, p] of Object.entries(metrics.pressure || {})) {
+        if (p?.fullIntervalPercent > 10) {
+            alerts.push({ level: 'critical', message: `All tasks stalled on ${name} ${p.fullIntervalPercent.toFixed(1)}% of the interval (PSI full)` });
+        } else if (p?.someIntervalPercent > 20) {
+            alerts.push({ level: 'warning', message: `Tasks stalled on ${name} ${p.someIntervalPercent.toFixed(1)}% of the interval (PSI some)` });
+        }
+    }
+
+    // Check container memory (limit, OOM kills and stalls)
+    const cgMem = metrics.memory?.cgroup;
+    if (cgMem?.oomKillsInInterval > 0) {
+        alerts.push({ level: 'critical', message: `OOM killer fired ${cgMem.oomKillsInInterval} time(s) in cgroup ${cgMem.path}` });
+    }
+    if (cgMem?.usagePercentOfLimit > 90) {
+        alerts.push({ level: 'warning', message: `Cgroup ${cgMem.path} working set at ${cgMem.usagePercentOfLimit.toFixed(1)}% of its memory limit` });
+    }
+    if (cgMem?.pressure?.fullAvg10 > 10) {
+        alerts.push({ level: 'warning', message: `Cgroup ${cgMem.path} stalled on memory ${cgMem.pressure.fullAvg10.toFixed(1)}% of the last 10s` });
+    }
+
     // Check Memory
     if (metrics.memory && metrics.memory.usedPercent > 90) {
         alerts.push({ level: 'critical', message: `Memory usage critical: ${metrics.memory.usedPercent.toFixed(1)}%` });
@@ -645,10 +1514,13 @@ function checkAlerts(metrics) {
     }
 
     // Check CLOSE_WAIT
+    const closeWaitHolder = (metrics.processes || []).reduce((top, p) =>
+        (p.connectionStates?.CLOSE_WAIT || 0) > (top?.connectionStates?.CLOSE_WAIT || 0) ? p : top, null);
+    const heldBy = closeWaitHolder ? `, ${closeWaitHolder.connectionStates.CLOSE_WAIT} held by ${closeWaitHolder.name} (PID ${closeWaitHolder.pid})` : '';
     if (metrics.tcp && metrics.tcp.closeWaitCount > 50) {
-        alerts.push({ level: 'critical', message: `High CLOSE_WAIT connections: ${metrics.tcp.closeWaitCount} (potential connection leak)` });
+        alerts.push({ level: 'critical', message: `High CLOSE_WAIT connections: ${metrics.tcp.closeWaitCount} (potential connection leak${heldBy})` });
     } else if (metrics.tcp && metrics.tcp.closeWaitCount > 10) {
-        alerts.push({ level: 'warning', message: `Elevated CLOSE_WAIT connections: ${metrics.tcp.closeWaitCount}` });
+        alerts.push({ level: 'warning', message: `Elevated CLOSE_WAIT connections: ${metrics.tcp.closeWaitCount}${heldBy}` });
     }
 
     // Check TIME_WAIT
@@ -656,6 +1528,24 @@ function checkAlerts(metrics) {
         alerts.push({ level: 'warning', message: `High TIME_WAIT connections: ${metrics.tcp.timeWaitCount} (connection churn)` });
     }
 
+    // Check long-lived CLOSE_WAIT
+    const longCloseWait = metrics.tcp?.churn?.longCloseWait || [];
+    if (longCloseWait.length > 0) {
+        alerts.push({ level: 'warning', message: `${longCloseWait.length} connections in CLOSE_WAIT longer than ${metrics.tcp.churn.closeWaitThreshold}s (oldest ${formatDuration(longCloseWait[0].stateSeconds)}, PID ${longCloseWait[0].pid})` });
+    }
+
+    // Check ephemeral port exhaustion
+    const ports = metrics.tcp?.portExhaustion;
+    if (ports && ports.status !== 'ok') {
+        alerts.push({ level: ports.status, message: ports.message });
+    }
+
+    // Check listen queue overflows
+    const listeners = metrics.tcp?.listeners;
+    if (listeners && listeners.status !== 'ok') {
+        alerts.push({ level: listeners.status, message: listeners.message });
+    }
+
     // Check retransmission rate
     if (metrics.tcp && metrics.tcp.retransmissionRate > 5) {
         alerts.push({ level: 'critical', message: `High retransmission rate: ${metrics.tcp.retransmissionRate.toFixed(2)}%` });
@@ -792,6 +1682,218 @@ function updateWindowStatus(tcp) {
     bufferStatus.style.color = status === 'free' ? '#00d9a5' : (status === 'warning' ? '#ffc107' : '#e63946');
 }
 
+// Update Ephemeral Port Exhaustion Panel
+function updatePortExhaustion(ports) {
+    const lastPort = ports.dynamicPortStart + ports.dynamicPortCount - 1;
+    document.getElementById('portRange').textContent = `${ports.dynamicPortStart}-${lastPort}`;
+    document.getElementById('portsInUse').textContent = formatNumber(ports.portsInUse);
+    document.getElementById('portGrowthRate').textContent = (ports.growthRate || 0).toFixed(1) + ' ports/sec';
+    document.getElementById('portExhaustionEta').textContent = ports.secondsToExhaustion >= 0
+        ? formatDuration(ports.secondsToExhaustion)
+        : 'Not growing';
+    document.getElementById('portBottleneck').textContent = ports.bottleneck || '--';
+    document.getElementById('portUsedPercent').textContent = `Usage: ${ports.usedPercent.toFixed(1)}%`;
+
+    const progress = document.getElementById('portUsageProgress');
+    progress.style.width = Math.min(ports.usedPercent, 100) + '%';
+    progress.className = 'progress-fill ' + (ports.status === 'critical' ? 'red' : ports.status === 'warning' ? 'yellow' : 'green');
+}
+
+// Update Connection Churn Panel
+function updateConnectionChurn(churn) {
+    document.getElementById('churnOpened').textContent = `${churn.opened} (${churn.openedPerSec.toFixed(1)}/sec)`;
+    document.getElementById('churnClosed').textContent = `${churn.closed} (${churn.closedPerSec.toFixed(1)}/sec)` +
+        (churn.shortLived ? `, ${churn.shortLived} short-lived` : '');
+
+    const top = (churn.endpointChurn || [])[0];
+    document.getElementById('churnTopEndpoint').textContent = top ? `${top.endpoint} (+${top.opened}/-${top.closed})` : '--';
+
+    const longCloseWait = document.getElementById('churnLongCloseWait');
+    const stuck = (churn.longCloseWait || []).length;
+    longCloseWait.textContent = stuck;
+    longCloseWait.style.color = stuck > 0 ? '#e63946' : 'inherit';
+
+    document.getElementById('connectionAgeHistogram').innerHTML = (churn.ageHistogram || []).map(bucket => `
+        <div class="metric-row">
+            <span class="metric-label">Age ${bucket.label}</span>
+            <span class="metric-value">${bucket.count}</span>
+        </div>
+    `).join('');
+}
+
+function updateTopRetransmitting(connections) {
+    document.getElementById('topRetransmitting').innerHTML = connections.map(conn => `
+        <div class="metric-row">
+            <span class="metric-label">${conn.remoteAddress}:${conn.remotePort} (PID ${conn.pid})</span>
+            <span class="metric-value">${conn.stats.segmentsRetransmitted} retrans · ${conn.stats.smoothedRtt.toFixed(1)} ms</span>
+        </div>
+    `).join('');
+}
+
+function updatePerCore(cpu) {
+    const hot = new Set(cpu.hotCores || []);
+    charts.perCore.data.labels = cpu.cores.map(core => (hot.has(core.id) ? '🔥 ' : '') + 'CPU ' + core.id);
+    charts.perCore.data.datasets[0].data = cpu.cores.map(core => core.userPercent);
+    charts.perCore.data.datasets[1].data = cpu.cores.map(core => core.kernelPercent);
+    charts.perCore.data.datasets[2].data = cpu.cores.map(core => core.iowaitPercent);
+    charts.perCore.data.datasets[3].data = cpu.cores.map(core => core.stealPercent);
+    charts.perCore.update('none');
+
+    const busiest = cpu.cores.reduce((max, core) => core.totalPercent > max.totalPercent ? core : max, cpu.cores[0]);
+    document.getElementById('hotCore').textContent = busiest
+        ? `CPU ${busiest.id} at ${busiest.totalPercent.toFixed(1)}%${hot.size > 0 ? ' 🔥' : ''}` : '--';
+    document.getElementById('hotCore').style.color = hot.size > 0 ? '#e63946' : 'inherit';
+
+    document.getElementById('numaNodes').innerHTML = (cpu.numaNodes || []).map(node => `
+        <div class="metric-row">
+            <span class="metric-label">NUMA Node ${node.node} (${node.cores.length} cores)</span>
+            <span class="metric-value">avg ${node.totalPercent.toFixed(1)}% · max ${node.maxPercent.toFixed(1)}%</span>
+        </div>
+    `).join('');
+}
+
+function updateScheduler(cpu) {
+    document.getElementById('contextSwitches').textContent = formatNumber(cpu.contextSwitchesPerSec) + '/sec';
+    document.getElementById('interrupts').textContent = formatNumber(cpu.interruptsPerSec) + '/sec';
+    document.getElementById('processorQueueLength').textContent = cpu.runnableTasks
+        ? `${cpu.processorQueueLength} waiting (${cpu.runnableTasks} runnable, ${cpu.blockedTasks || 0} blocked)`
+        : cpu.processorQueueLength;
+    const load = cpu.loadAverage;
+    document.getElementById('loadAverage').textContent = load
+        ? `${load.load1.toFixed(2)} / ${load.load5.toFixed(2)} / ${load.load15.toFixed(2)}` : 'n/a';
+
+    document.getElementById('softirqBreakdown').innerHTML = Object.entries(cpu.softirqPerSec || {})
+        .filter(([, rate]) => rate > 0)
+        .sort((a, b) => b[1] - a[1])
+        .map(([name, rate]) => `
+            <div class="metric-row">
+                <span class="metric-label">${name}</span>
+                <span class="metric-value">${rate.toFixed(0)}/sec</span>
+            </div>
+        `).join('');
+}
+
+function updatePaging(memory) {
+    document.getElementById('pageFaults').textContent = formatNumber(memory.pageFaultsPerSec) + '/sec';
+    document.getElementById('majorFaults').textContent = formatNumber(memory.majorFaultsPerSec) + '/sec';
+    document.getElementById('pagesInOut').textContent =
+        `${formatNumber(memory.pagesInputPerSec)} / ${formatNumber(memory.pagesOutputPerSec)} pages/sec`;
+    document.getElementById('commitPeak').textContent = formatBytes(memory.commitPeak);
+    document.getElementById('kernelPools').textContent = memory.kernelPagedBytes || memory.kernelNonpagedBytes
+        ? `${formatBytes(memory.kernelPagedBytes)} / ${formatBytes(memory.kernelNonpagedBytes)}` : 'n/a';
+    document.getElementById('slabMemory').textContent = memory.slabReclaimableBytes || memory.slabUnreclaimableBytes
+        ? `${formatBytes(memory.slabReclaimableBytes)} / ${formatBytes(memory.slabUnreclaimableBytes)}` : 'n/a';
+}
+
+function updatePressureSummary(pressure) {
+    const summary = document.getElementById('pressureSummary');
+    if (!pressure) {
+        summary.textContent = '(not available on this system)';
+        return;
+    }
+    const full = (name, p) => p ? `${name} full ${p.fullIntervalPercent.toFixed(1)}%` : null;
+    summary.textContent = '(' + [full('memory', pressure.memory), full('io', pressure.io)]
+        .filter(Boolean).join(' · ') + ')';
+}
+
+function updateCgroupMemory(cg) {
+    const set = (id, text) => document.getElementById(id).textContent = text;
+    if (!cg) {
+        ['cgroupMemPath', 'cgroupMemUsage', 'cgroupMemTotal', 'cgroupMemCache', 'cgroupMemSwap', 'cgroupOomKills', 'cgroupMemPressure']
+            .forEach(id => set(id, 'n/a'));
+        return;
+    }
+    set('cgroupMemPath', `${cg.path} (v${cg.version}${cg.pid ? `, PID ${cg.pid}` : ''})`);
+    set('cgroupMemUsage', cg.limitBytes
+        ? `${formatBytes(cg.workingSetBytes)} / ${formatBytes(cg.limitBytes)} (${cg.usagePercentOfLimit.toFixed(1)}%)`
+        : `${formatBytes(cg.workingSetBytes)} (unlimited)`);
+    set('cgroupMemTotal', formatBytes(cg.usageBytes));
+    set('cgroupMemCache', formatBytes(cg.cacheBytes));
+    set('cgroupMemSwap', formatBytes(cg.swapBytes));
+    set('cgroupOomKills', `${cg.oomKills} (+${cg.oomKillsInInterval})`);
+    set('cgroupMemPressure', cg.pressure
+        ? `${cg.pressure.someAvg10.toFixed(2)}% / ${cg.pressure.fullAvg10.toFixed(2)}%` : 'n/a');
+}
+
+function updateVirtualization(cpu) {
+    const env = cpu.environment;
+    document.getElementById('cpuEnvironment').textContent = !env ? 'n/a'
+        : [env.hypervisor, env.container].filter(Boolean).join(' / ') || 'Bare metal';
+    document.getElementById('cpuSteal').textContent = (cpu.stealPercent || 0).toFixed(1) + '%';
+    document.getElementById('cpuGuest').textContent = (cpu.guestPercent || 0).toFixed(1) + '%';
+
+    const cg = cpu.cgroup;
+    document.getElementById('cgroupQuota').textContent = !cg ? 'n/a'
+        : cg.quotaCores > 0
+            ? `${cg.usageCores.toFixed(2)} of ${cg.quotaCores.toFixed(2)} cores (${cg.usagePercentOfQuota.toFixed(0)}%)`
+            : `${cg.usageCores.toFixed(2)} cores (unlimited)`;
+    document.getElementById('cgroupThrottled').textContent = !cg ? 'n/a'
+        : `${cg.throttledPeriods}/${cg.periods} (${cg.throttledPercent.toFixed(1)}%, ${cg.throttledSeconds.toFixed(2)}s)`;
+}
+
+function updateListeners(listeners) {
+    document.getElementById('listenOverflows').textContent = listeners.queueStatsAvailable
+        ? `${listeners.listenOverflows} (${listeners.overflowsPerSec.toFixed(1)}/sec)` : 'n/a';
+    document.getElementById('listenDrops').textContent = listeners.queueStatsAvailable
+        ? `${listeners.listenDrops} (${listeners.dropsPerSec.toFixed(1)}/sec)` : 'n/a';
+
+    document.getElementById('listenerList').innerHTML = listeners.listeners.slice(0, 8).map(l => `
+        <div class="metric-row">
+            <span class="metric-label">:${l.localPort} (PID ${l.pid})</span>
+            <span class="metric-value" style="color: ${l.queuePercent >= 80 ? '#e63946' : 'inherit'}">
+                ${listeners.queueStatsAvailable ? `${l.acceptQueue}/${l.backlog} queued · ` : ''}${l.synReceived} SYN_RCVD
+            </span>
+        </div>
+    `).join('');
+}
+
+let endpointsRequest = null;
+
+async function refreshEndpoints() {
+    // Skip while the previous request (e.g. hostname lookups) is still running
+    if (endpointsRequest) return;
+
+    const params = new URLSearchParams({ groupBy: document.getElementById('endpointGroupBy').value });
+    const cidrs = document.getElementById('endpointCidrs').value.trim();
+    if (cidrs) params.set('cidr', cidrs);
+
+    endpointsRequest = fetch('/api/metrics/tcp/endpoints?' + params);
+    try {
+        const response = await endpointsRequest;
+        const result = await response.json();
+        if (!response.ok) {
+            console.error('Endpoint aggregation failed:', result.error);
+            return;
+        }
+        renderEndpointsTable(result.endpoints || []);
+    } catch (error) {
+        console.error('Failed to fetch endpoints:', error);
+    } finally {
+        endpointsRequest = null;
+    }
+}
+
+function renderEndpointsTable(endpoints) {
+    document.getElementById('endpointCount').textContent = `(${endpoints.length})`;
+    const tbody = document.querySelector('#endpointsTable tbody');
+    tbody.innerHTML = endpoints.slice(0, 100).map(ep => `
+        <tr>
+            <td title="${(ep.remoteAddresses || []).join(', ')}">${ep.endpoint}</td>
+            <td>${ep.connections}</td>
+            <td>${Object.entries(ep.states).map(([state, count]) => `${state}: ${count}`).join(', ')}</td>
+            <td>${ep.openedPerSec.toFixed(1)}</td>
+            <td>${ep.closedPerSec.toFixed(1)}</td>
+            <td>${(ep.processes || []).map(p => p.pid).join(', ') || '--'}</td>
+        </tr>
+    `).join('');
+}
+
+function formatDuration(seconds) {
+    if (seconds < 60) return Math.round(seconds) + 's';
+    if (seconds < 3600) return Math.floor(seconds / 60) + 'm ' + Math.round(seconds % 60) + 's';
+    return Math.floor(seconds / 3600) + 'h ' + Math.floor((seconds % 3600) / 60) + 'm';
+}
+
 // ==================== TRACEROUTE FUNCTIONS ====================
 
 async function runTraceroute() {
@@ -0,0 +1,100 @@
+// Package analyzers provides analysis helpers over collected metric history
+package analyzers
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Histogram sub-buckets per power of two. Values below histogramSubBuckets
+// are counted exactly; above that each bucket spans 1/64 of its power of two,
+// keeping relative error under 1.6% (HDR histogram with two significant digits).
+const (
+	histogramSubBuckets = 128
+	histogramHalf       = histogramSubBuckets / 2
+)
+
+// Histogram is a log-linear (HDR-style) histogram of non-negative integer
+// values with constant relative precision and no fixed upper bound
+type Histogram struct {
+	counts []uint64
+	total  uint64
+	max    uint64
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds count occurrences of value
+func (h *Histogram) Record(value, count uint64) {
+	if count == 0 {
+		return
+	}
+	idx := histogramIndex(value)
+	if idx >= len(h.counts) {
+		grown := make([]uint64, idx+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[idx] += count
+	h.total += count
+	if value > h.max {
+		h.max = value
+	}
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Max returns the largest recorded value
+func (h *Histogram) Max() uint64 {
+	return h.max
+}
+
+// Percentile returns the value at or below which p percent of the recorded
+// values fall, as the upper bound of its bucket (never above Max)
+func (h *Histogram) Percentile(p float64) uint64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for idx, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if upper := histogramUpper(idx); upper < h.max {
+				return upper
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// histogramIndex returns the bucket of a value
+func histogramIndex(value uint64) int {
+	if value < histogramSubBuckets {
+		return int(value)
+	}
+	// Shift so the value lands in [histogramHalf, histogramSubBuckets)
+	shift := bits.Len64(value) - bits.Len64(histogramSubBuckets-1)
+	return histogramSubBuckets + (shift-1)*histogramHalf + int(value>>uint(shift)) - histogramHalf
+}
+
+// histogramUpper returns the largest value counted in a bucket
+func histogramUpper(idx int) uint64 {
+	if idx < histogramSubBuckets {
+		return uint64(idx)
+	}
+	shift := (idx-histogramSubBuckets)/histogramHalf + 1
+	sub := uint64((idx-histogramSubBuckets)%histogramHalf + histogramHalf)
+	return (sub+1)<<uint(shift) - 1
+}
@@ -0,0 +1,131 @@
+// Package analyzers provides analysis helpers over collected metric history
+package analyzers
+
+import (
+	"math"
+	"sort"
+)
+
+// LinearFit fits y = slope*x + intercept by ordinary least squares.
+// ok is false when there are fewer than two points or all x values are equal.
+func LinearFit(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := len(xs)
+	if n < 2 || n != len(ys) {
+		return 0, 0, false
+	}
+
+	var sumX, sumY float64
+	for i := 0; i < n; i++ {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX := sumX / float64(n)
+	meanY := sumY / float64(n)
+
+	var sxx, sxy float64
+	for i := 0; i < n; i++ {
+		dx := xs[i] - meanX
+		sxx += dx * dx
+		sxy += dx * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	return slope, intercept, true
+}
+
+// TheilSenFit fits y = slope*x + intercept robustly: the slope is the median
+// of the slopes between all pairs of points and the intercept the median of
+// y - slope*x. Up to ~29% of the points can be outliers (e.g. a log rotated
+// away or a temp file deleted) without pulling the fit.
+// ok is false when there are fewer than two points or all x values are equal.
+func TheilSenFit(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := len(xs)
+	if n < 2 || n != len(ys) {
+		return 0, 0, false
+	}
+
+	slopes := make([]float64, 0, n*(n-1)/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if dx := xs[j] - xs[i]; dx != 0 {
+				slopes = append(slopes, (ys[j]-ys[i])/dx)
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return 0, 0, false
+	}
+	slope = median(slopes)
+
+	residuals := make([]float64, n)
+	for i := 0; i < n; i++ {
+		residuals[i] = ys[i] - slope*xs[i]
+	}
+	return slope, median(residuals), true
+}
+
+// MannKendall tests ys (in time order) for a monotonic trend without
+// assuming a distribution or a linear shape. z is the normal approximation of
+// the Kendall S statistic, corrected for ties: positive for an upward trend,
+// and above 2.33 when it is significant at the 1% level (one-sided).
+// ok is false when there are fewer than three points or all values are equal.
+func MannKendall(ys []float64) (z float64, ok bool) {
+	n := len(ys)
+	if n < 3 {
+		return 0, false
+	}
+
+	var s float64
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case ys[j] > ys[i]:
+				s++
+			case ys[j] < ys[i]:
+				s--
+			}
+		}
+	}
+
+	// Each group of t tied values removes t(t-1)(2t+5) from the variance
+	sorted := append([]float64(nil), ys...)
+	sort.Float64s(sorted)
+	nf := float64(n)
+	variance := nf * (nf - 1) * (2*nf + 5)
+	for i := 0; i < n; {
+		j := i + 1
+		for j < n && sorted[j] == sorted[i] {
+			j++
+		}
+		t := float64(j - i)
+		variance -= t * (t - 1) * (2*t + 5)
+		i = j
+	}
+	variance /= 18
+	if variance <= 0 {
+		return 0, false
+	}
+
+	// Continuity correction
+	switch {
+	case s > 0:
+		s--
+	case s < 0:
+		s++
+	}
+	return s / math.Sqrt(variance), true
+}
+
+// median returns the median of values, reordering them
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
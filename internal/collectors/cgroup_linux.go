@@ -0,0 +1,216 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides cgroup v1/v2 discovery and file helpers
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroup locates the controller directories of one cgroup
+type cgroup struct {
+	v1      map[string]cgroupController // per v1 controller
+	unified *cgroupController           // nil when no unified (v2) hierarchy is mounted
+}
+
+// cgroupController is where one controller's files live
+type cgroupController struct {
+	path    string // cgroup path as listed in /proc/<pid>/cgroup
+	dir     string // directory in the mounted hierarchy
+	version int    // 1 or 2
+}
+
+// cgroupMount is a cgroup filesystem from mountinfo
+type cgroupMount struct {
+	root        string // cgroup path mounted at mountPoint
+	mountPoint  string
+	unified     bool
+	controllers map[string]bool // v1 only
+}
+
+// findCgroup returns the cgroup of a process (0 = this process)
+func findCgroup(pid int) (*cgroup, error) {
+	proc := "self"
+	if pid > 0 {
+		proc = strconv.Itoa(pid)
+	}
+
+	f, err := os.Open(filepath.Join(procRoot, proc, "cgroup"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup of %s: %w", proc, err)
+	}
+	defer f.Close()
+
+	mounts, err := readCgroupMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &cgroup{v1: make(map[string]cgroupController)}
+
+	// Lines are "hierarchy-ID:controller-list:path"; v2 has an empty controller list
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		cg.add(mounts, parts[1], parts[2])
+	}
+
+	return cg, scanner.Err()
+}
+
+// cgroupFromPath locates a cgroup by its path relative to the hierarchy root
+// (as listed in /proc/<pid>/cgroup) in every mounted hierarchy
+func cgroupFromPath(path string) (*cgroup, error) {
+	mounts, err := readCgroupMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	path = "/" + strings.Trim(path, "/")
+	cg := &cgroup{v1: make(map[string]cgroupController)}
+	for _, m := range mounts {
+		if m.unified {
+			cg.add(mounts, "", path)
+			continue
+		}
+		for controller := range m.controllers {
+			cg.add(mounts, controller, path)
+		}
+	}
+
+	// Keep only the hierarchies where the cgroup exists
+	for name, c := range cg.v1 {
+		if _, err := os.Stat(c.dir); err != nil {
+			delete(cg.v1, name)
+		}
+	}
+	if cg.unified != nil {
+		if _, err := os.Stat(cg.unified.dir); err != nil {
+			cg.unified = nil
+		}
+	}
+	if len(cg.v1) == 0 && cg.unified == nil {
+		return nil, fmt.Errorf("cgroup %s not found", path)
+	}
+	return cg, nil
+}
+
+// add records where the given comma-separated v1 controllers (empty for the
+// unified hierarchy) of a cgroup path live
+func (cg *cgroup) add(mounts []cgroupMount, controllers, path string) {
+	if controllers == "" {
+		for _, m := range mounts {
+			if m.unified {
+				cg.unified = &cgroupController{path: path, dir: m.dir(path), version: 2}
+				return
+			}
+		}
+		return
+	}
+
+	for _, controller := range strings.Split(controllers, ",") {
+		for _, m := range mounts {
+			if !m.unified && m.controllers[controller] {
+				cg.v1[controller] = cgroupController{path: path, dir: m.dir(path), version: 1}
+				break
+			}
+		}
+	}
+}
+
+// dir returns the directory of a cgroup path within this mount
+func (m cgroupMount) dir(path string) string {
+	rel := path
+	if m.root != "/" {
+		rel = strings.TrimPrefix(path, m.root)
+	}
+	return filepath.Join(m.mountPoint, rel)
+}
+
+// readCgroupMounts lists cgroup filesystems mounted in this mount namespace
+func readCgroupMounts() ([]cgroupMount, error) {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var cgroups []cgroupMount
+	for _, m := range mounts {
+		switch m.fsType {
+		case "cgroup2":
+			cgroups = append(cgroups, cgroupMount{root: m.root, mountPoint: m.mountPoint, unified: true})
+		case "cgroup":
+			c := cgroupMount{root: m.root, mountPoint: m.mountPoint, controllers: make(map[string]bool)}
+			for _, opt := range strings.Split(m.superOptions, ",") {
+				c.controllers[opt] = true
+			}
+			cgroups = append(cgroups, c)
+		}
+	}
+	return cgroups, nil
+}
+
+// controller returns where a controller's files live, preferring a v1
+// hierarchy on hybrid systems
+func (cg *cgroup) controller(name string) (cgroupController, bool) {
+	if c, ok := cg.v1[name]; ok {
+		return c, true
+	}
+	if cg.unified != nil {
+		return *cg.unified, true
+	}
+	return cgroupController{}, false
+}
+
+// readCgroupValue reads a single-line cgroup file
+func readCgroupValue(dir, file string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readCgroupUint reads a cgroup file holding one unsigned number. "max" reads as ok=false.
+func readCgroupUint(dir, file string) (uint64, bool) {
+	value, err := readCgroupValue(dir, file)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// readCgroupStat reads a flat keyed cgroup file such as cpu.stat or memory.stat
+func readCgroupStat(dir, file string) (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stats[fields[0]] = v
+		}
+	}
+	return stats, scanner.Err()
+}
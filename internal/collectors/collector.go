@@ -3,11 +3,19 @@ package collectors
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net"
+	"regexp"
+	"sync"
+	"time"
 
 	"loadrunner-diagnosis/internal/models"
 )
 
+// ErrNotAvailable is returned by collectors whose metrics this platform or kernel does not provide
+var ErrNotAvailable = errors.New("not available on this system")
+
 // Collector interface for all metric collectors
 type Collector interface {
 	Name() string
@@ -16,12 +24,18 @@ type Collector interface {
 
 // Manager manages all collectors and provides unified access
 type Manager struct {
-	tcp     *TCPCollector
-	memory  *MemoryCollector
-	cpu     *CPUCollector
-	disk    *DiskCollector
-	network *NetworkCollector
-	process *ProcessCollector
+	tcp      *TCPCollector
+	memory   *MemoryCollector
+	cpu      *CPUCollector
+	disk     *DiskCollector
+	network  *NetworkCollector
+	process  *ProcessCollector
+	pressure *PressureCollector
+
+	mu           sync.RWMutex
+	processQuery ProcessQuery         // ranking of the streamed process list
+	processes    []models.ProcessInfo // every process at the last CollectAll
+	history      *processHistory
 }
 
 // NewManager creates a new collector manager
@@ -56,18 +70,38 @@ func NewManager() (*Manager, error) {
 		return nil, err
 	}
 
+	pressure, err := NewPressureCollector()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Manager{
-		tcp:     tcp,
-		memory:  memory,
-		cpu:     cpu,
-		disk:    disk,
-		network: network,
-		process: process,
+		tcp:      tcp,
+		memory:   memory,
+		cpu:      cpu,
+		disk:     disk,
+		network:  network,
+		process:  process,
+		pressure: pressure,
+		history:  newProcessHistory(),
 	}, nil
 }
 
-// CollectAll collects all system metrics
+// CollectAll collects all system metrics for a monitoring tick and records
+// them into the state of the run (disk latency distributions, process leak
+// detection, pinned process history). Only the monitoring loop calls it.
 func (m *Manager) CollectAll(ctx context.Context) (*models.SystemMetrics, error) {
+	return m.collectAll(ctx, true)
+}
+
+// CollectCurrent collects all system metrics for a one-off request, without
+// recording them into the state of the run
+func (m *Manager) CollectCurrent(ctx context.Context) (*models.SystemMetrics, error) {
+	return m.collectAll(ctx, false)
+}
+
+// collectAll collects all system metrics, recording them when tick is set
+func (m *Manager) collectAll(ctx context.Context, tick bool) (*models.SystemMetrics, error) {
 	metrics := &models.SystemMetrics{}
 
 	// Collect TCP metrics with panic recovery
@@ -119,7 +153,11 @@ func (m *Manager) CollectAll(ctx context.Context) (*models.SystemMetrics, error)
 				log.Printf("Disk collector panic: %v", r)
 			}
 		}()
-		if disk, err := m.disk.Collect(ctx); err == nil {
+		collect := m.disk.Collect
+		if tick {
+			collect = m.disk.CollectTick
+		}
+		if disk, err := collect(ctx); err == nil {
 			metrics.Disk = disk
 		} else {
 			log.Printf("Disk collect error: %v", err)
@@ -147,21 +185,96 @@ func (m *Manager) CollectAll(ctx context.Context) (*models.SystemMetrics, error)
 				log.Printf("Process collector panic: %v", r)
 			}
 		}()
-		if procs, err := m.process.Collect(ctx); err == nil {
-			metrics.Processes = procs
+		collect := m.process.Collect
+		if tick {
+			collect = m.process.CollectTick
+		}
+		if procs, err := collect(ctx); err == nil {
+			if metrics.TCP != nil {
+				fillConnections(procs, metrics.TCP.Connections)
+			}
+			if tick {
+				m.history.Record(procs, time.Now())
+			}
+			metrics.ProcessLeaks = m.process.Leaks()
+			m.mu.Lock()
+			m.processes = procs
+			query := m.processQuery
+			m.mu.Unlock()
+			if procs, err = query.Apply(procs); err == nil {
+				metrics.Processes = procs
+			} else {
+				log.Printf("Process query error: %v", err)
+			}
 		} else {
 			log.Printf("Process collect error: %v", err)
 		}
 	}()
 
+	// Collect pressure stall information with panic recovery
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Pressure collector panic: %v", r)
+			}
+		}()
+		if pressure, err := m.pressure.Collect(ctx); err == nil {
+			metrics.Pressure = pressure
+		} else if !errors.Is(err, ErrNotAvailable) {
+			log.Printf("Pressure collect error: %v", err)
+		}
+	}()
+
 	return metrics, nil
 }
 
+// SetCloseWaitThreshold sets how long a connection may stay in CLOSE_WAIT before it is reported
+func (m *Manager) SetCloseWaitThreshold(d time.Duration) {
+	m.tcp.SetCloseWaitThreshold(d)
+}
+
+// SetExtendedStats enables or disables per-connection extended TCP statistics
+func (m *Manager) SetExtendedStats(enabled bool) {
+	m.tcp.SetExtendedStats(enabled)
+}
+
+// SetCgroupTarget selects the cgroup reported in memory metrics, by cgroup path or by PID
+func (m *Manager) SetCgroupTarget(path string, pid int) error {
+	return m.memory.SetCgroupTarget(path, pid)
+}
+
+// ResetCommitPeak starts tracking the memory commit peak anew where the
+// platform does not report it since boot
+func (m *Manager) ResetCommitPeak() {
+	m.memory.ResetCommitPeak()
+}
+
+// ResetDiskLatency starts new disk latency distributions with the given SLA (0 = default)
+func (m *Manager) ResetDiskLatency(sla time.Duration) {
+	m.disk.ResetLatency(sla)
+}
+
+// SetTestDuration sets the expected load test length from now, used to alert
+// on volumes that fill before the test ends (0 = use the default horizon)
+func (m *Manager) SetTestDuration(d time.Duration) {
+	var end time.Time
+	if d > 0 {
+		end = time.Now().Add(d)
+	}
+	m.disk.SetTestEnd(end)
+}
+
 // GetTCP returns TCP metrics
 func (m *Manager) GetTCP(ctx context.Context) (*models.TCPMetrics, error) {
 	return m.tcp.Collect(ctx)
 }
 
+// GetTCPEndpoints returns TCP connections aggregated by remote endpoint,
+// collecting a new table when the latest is older than maxAge
+func (m *Manager) GetTCPEndpoints(ctx context.Context, groupBy string, cidrs []*net.IPNet, maxAge time.Duration) (*models.TCPEndpointMetrics, error) {
+	return m.tcp.GetEndpoints(ctx, groupBy, cidrs, maxAge)
+}
+
 // GetMemory returns Memory metrics
 func (m *Manager) GetMemory(ctx context.Context) (*models.MemoryMetrics, error) {
 	return m.memory.Collect(ctx)
@@ -182,7 +295,151 @@ func (m *Manager) GetNetwork(ctx context.Context) (*models.NetworkMetrics, error
 	return m.network.Collect(ctx)
 }
 
-// GetProcesses returns Process metrics
-func (m *Manager) GetProcesses(ctx context.Context) ([]models.ProcessInfo, error) {
-	return m.process.Collect(ctx)
+// ResetNetworkInventory makes the adapter configuration at the next
+// collection the session baseline and clears the change log
+func (m *Manager) ResetNetworkInventory() {
+	m.network.ResetInventory()
+}
+
+// GetNetworkInventory returns the adapter configuration at session start, now,
+// and every change in between. Adapters are listed first if nothing was
+// collected yet.
+func (m *Manager) GetNetworkInventory(ctx context.Context) (*models.NetworkInventory, error) {
+	inventory := m.network.Inventory()
+	if inventory.BaselineAt.IsZero() {
+		if _, err := m.network.Collect(ctx); err != nil {
+			return nil, err
+		}
+		inventory = m.network.Inventory()
+	}
+	return inventory, nil
+}
+
+// GetPressure returns pressure stall information
+func (m *Manager) GetPressure(ctx context.Context) (*models.PressureStallMetrics, error) {
+	return m.pressure.Collect(ctx)
+}
+
+// SetProcessQuery sets the ranking, top-N and watch list of the process list
+// in collected metrics
+func (m *Manager) SetProcessQuery(query ProcessQuery) error {
+	if _, err := query.Apply(nil); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processQuery = query
+	return nil
+}
+
+// GetProcesses returns Process metrics selected and ranked by query
+func (m *Manager) GetProcesses(ctx context.Context, query ProcessQuery) ([]models.ProcessInfo, error) {
+	procs, err := m.collectProcesses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(procs)
+}
+
+// GetProcessTree returns every process arranged under its parent
+func (m *Manager) GetProcessTree(ctx context.Context) ([]models.ProcessNode, error) {
+	procs, err := m.collectProcesses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return BuildProcessTree(procs), nil
+}
+
+// GetProcessGroups returns process metrics aggregated by name, application or
+// command-line pattern (see GroupProcesses)
+func (m *Manager) GetProcessGroups(ctx context.Context, by string, pattern *regexp.Regexp, sortKey string) ([]models.ProcessGroup, error) {
+	procs, err := m.collectProcesses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return GroupProcesses(procs, by, pattern, sortKey)
+}
+
+// SetProcessPins replaces the pinned processes: PIDs, or regular expressions
+// matched against process names and command lines
+func (m *Manager) SetProcessPins(pins []string) error {
+	return m.history.SetPins(pins)
+}
+
+// ProcessPins returns the pinned processes
+func (m *Manager) ProcessPins() []string {
+	return m.history.Pins()
+}
+
+// ResetProcessHistory drops the recorded series of pinned processes; the
+// pins are kept
+func (m *Manager) ResetProcessHistory() {
+	m.history.Reset()
+}
+
+// GetPinnedProcesses returns the series of pinned processes without samples
+func (m *Manager) GetPinnedProcesses() []models.ProcessHistory {
+	return m.history.List()
+}
+
+// GetProcessHistory returns the series of a pinned process by any PID it had
+func (m *Manager) GetProcessHistory(pid uint32) (*models.ProcessHistory, bool) {
+	return m.history.Get(pid)
+}
+
+// collectProcesses returns every process with its TCP connections from the
+// last CollectAll. The process collector keeps rate, leak and lifecycle state
+// between samples, so it is only called here when nothing was collected yet.
+func (m *Manager) collectProcesses(ctx context.Context) ([]models.ProcessInfo, error) {
+	m.mu.RLock()
+	procs := m.processes
+	m.mu.RUnlock()
+	if procs != nil {
+		return procs, nil
+	}
+
+	procs, err := m.process.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if connections, err := m.tcp.getTcpTable(); err == nil {
+		fillConnections(procs, connections)
+	}
+	return procs, nil
+}
+
+// ResetProcessLeaks clears the leak history and sets the memory (bytes) and
+// handle limits leak growth is projected against (0 = platform default)
+func (m *Manager) ResetProcessLeaks(memoryLimit, handleLimit uint64) {
+	m.process.ResetLeaks(memoryLimit, handleLimit)
+}
+
+// GetProcessLeaks returns the processes whose memory, handles or threads grew
+// steadily over the monitoring run
+func (m *Manager) GetProcessLeaks() *models.ProcessLeakReport {
+	return m.process.LeakReport()
+}
+
+// ResetProcessEvents clears the process lifecycle event log
+func (m *Manager) ResetProcessEvents() {
+	m.process.ResetEvents()
+}
+
+// GetProcessEvents returns the process starts, exits, crashes and restart
+// loops of the session
+func (m *Manager) GetProcessEvents() []models.ProcessEvent {
+	return m.process.Events()
+}
+
+// PendingProcessEvents returns the process lifecycle events since the previous
+// call. Only the monitoring loop calls it, so a CollectAll served to a REST
+// client does not take events away from the stream.
+func (m *Manager) PendingProcessEvents() []models.ProcessEvent {
+	return m.process.PendingEvents()
+}
+
+// GetProcessThreads samples the threads of a process over window and returns
+// their CPU use, busiest first
+func (m *Manager) GetProcessThreads(ctx context.Context, pid uint32, window time.Duration) (*models.ProcessThreads, error) {
+	return SampleThreads(ctx, pid, window)
 }
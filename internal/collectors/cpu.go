@@ -15,13 +15,50 @@ import (
 	"loadrunner-diagnosis/internal/models"
 
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 )
 
 var (
-	cpuKernel32        = windows.NewLazySystemDLL("kernel32.dll")
-	procGetSystemTimes = cpuKernel32.NewProc("GetSystemTimes")
+	cpuKernel32                    = windows.NewLazySystemDLL("kernel32.dll")
+	cpuNtdll                       = windows.NewLazySystemDLL("ntdll.dll")
+	procGetSystemTimes             = cpuKernel32.NewProc("GetSystemTimes")
+	procGetNumaHighestNodeNumber   = cpuKernel32.NewProc("GetNumaHighestNodeNumber")
+	procGetNumaNodeProcessorMaskEx = cpuKernel32.NewProc("GetNumaNodeProcessorMaskEx")
+	procNtQuerySystemInformation   = cpuNtdll.NewProc("NtQuerySystemInformation")
 )
 
+// Scheduler PDH counters
+const (
+	pdhContextSwitches = `\System\Context Switches/sec`
+	pdhInterrupts      = `\Processor(_Total)\Interrupts/sec`
+	pdhDPCs            = `\Processor(_Total)\DPCs Queued/sec`
+	pdhQueueLength     = `\System\Processor Queue Length`
+)
+
+// SYSTEM_INFORMATION_CLASS values
+const (
+	SystemProcessInformation              = 5
+	SystemProcessorPerformanceInformation = 8
+)
+
+// SYSTEM_PROCESSOR_PERFORMANCE_INFORMATION structure (times in 100ns units)
+type SYSTEM_PROCESSOR_PERFORMANCE_INFORMATION struct {
+	IdleTime       int64
+	KernelTime     int64 // includes idle time
+	UserTime       int64
+	DpcTime        int64
+	InterruptTime  int64
+	InterruptCount uint32
+	_              uint32
+}
+
+// GROUP_AFFINITY structure
+type GROUP_AFFINITY struct {
+	Mask     uintptr
+	Group    uint16
+	Reserved [3]uint16
+}
+
 // CPUCollector collects CPU metrics
 type CPUCollector struct {
 	mu             sync.RWMutex
@@ -30,6 +67,10 @@ type CPUCollector struct {
 	lastUser       uint64
 	lastCollect    time.Time
 	coreCount      int
+	lastCores      []cpuTimes
+	coreNodes      map[int]int // logical processor -> NUMA node
+	counters       *pdhQuery   // nil when PDH is unavailable
+	environment    *models.EnvironmentInfo
 }
 
 // NewCPUCollector creates a new CPU collector
@@ -43,10 +84,14 @@ func NewCPUCollector() (*CPUCollector, error) {
 	}
 
 	c := &CPUCollector{
-		coreCount: runtime.NumCPU(),
+		coreCount:   runtime.NumCPU(),
+		coreNodes:   getCoreNodes(),
+		environment: detectEnvironment(),
 	}
 	// Initialize baseline
 	c.getSystemTimes()
+	c.lastCores, _ = c.getProcessorTimes()
+	c.counters, _ = newPdhQuery([]string{pdhContextSwitches, pdhInterrupts, pdhDPCs, pdhQueueLength})
 	c.lastCollect = time.Now()
 	time.Sleep(100 * time.Millisecond) // Brief pause for initial reading
 	return c, nil
@@ -63,7 +108,8 @@ func (c *CPUCollector) Collect(ctx context.Context) (*models.CPUMetrics, error)
 	defer c.mu.Unlock()
 
 	metrics := &models.CPUMetrics{
-		CoreCount: c.coreCount,
+		CoreCount:   c.coreCount,
+		Environment: c.environment,
 	}
 
 	// Get current system times
@@ -101,16 +147,115 @@ func (c *CPUCollector) Collect(ctx context.Context) (*models.CPUMetrics, error)
 	c.lastUser = user
 	c.lastCollect = now
 
-	// Per-core metrics would require additional API calls
-	// For now, estimate based on total (simplified)
-	metrics.PerCorePercent = make([]float64, c.coreCount)
-	for i := 0; i < c.coreCount; i++ {
-		metrics.PerCorePercent[i] = metrics.TotalPercent // Simplified
+	// Per-core breakdown, NUMA grouping and hot cores
+	if cores, err := c.getProcessorTimes(); err == nil {
+		usage := make([]models.CoreUsage, 0, len(cores))
+		for i, cur := range cores {
+			if i < len(c.lastCores) {
+				usage = append(usage, coreUsage(i, c.lastCores[i], cur))
+			}
+		}
+		c.lastCores = cores
+		summarizeCores(metrics, usage, c.coreNodes)
+	}
+
+	// Context switches, interrupts, DPCs and run queue
+	if c.counters != nil && c.counters.Collect() == nil {
+		if v, ok := c.counters.Value(pdhContextSwitches); ok {
+			metrics.ContextSwitchesPerSec = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhInterrupts); ok {
+			metrics.InterruptsPerSec = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhQueueLength); ok {
+			metrics.ProcessorQueueLength = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhDPCs); ok {
+			metrics.SoftIRQPerSec = map[string]float64{"DPC": v}
+		}
 	}
 
 	return metrics, nil
 }
 
+// detectEnvironment identifies the hypervisor from the BIOS identification in
+// the registry and whether we run inside a Windows container. Steal time is not
+// exposed to Windows guests.
+func detectEnvironment() *models.EnvironmentInfo {
+	env := &models.EnvironmentInfo{}
+
+	if key, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DESCRIPTION\System\BIOS`, registry.QUERY_VALUE); err == nil {
+		vendor, _, _ := key.GetStringValue("SystemManufacturer")
+		product, _, _ := key.GetStringValue("SystemProductName")
+		key.Close()
+		env.Hypervisor = matchHypervisor(vendor, product)
+	}
+
+	// The container execution agent service only exists inside Windows containers
+	if key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\cexecsvc`, registry.QUERY_VALUE); err == nil {
+		key.Close()
+		env.Container = "windows-container"
+	}
+
+	env.Virtualized = env.Hypervisor != "" || env.Container != ""
+	return env
+}
+
+// getProcessorTimes retrieves cumulative times for each logical processor.
+// Only the calling thread's processor group (up to 64 processors) is reported.
+func (c *CPUCollector) getProcessorTimes() ([]cpuTimes, error) {
+	entrySize := unsafe.Sizeof(SYSTEM_PROCESSOR_PERFORMANCE_INFORMATION{})
+	buf := make([]SYSTEM_PROCESSOR_PERFORMANCE_INFORMATION, c.coreCount)
+	var returned uint32
+
+	ret, _, _ := procNtQuerySystemInformation.Call(
+		SystemProcessorPerformanceInformation,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf))*entrySize,
+		uintptr(unsafe.Pointer(&returned)),
+	)
+	if ret != 0 {
+		return nil, fmt.Errorf("NtQuerySystemInformation failed: 0x%x", ret)
+	}
+
+	count := int(uintptr(returned) / entrySize)
+	times := make([]cpuTimes, count)
+	for i := 0; i < count; i++ {
+		info := buf[i]
+		times[i] = cpuTimes{
+			user:   uint64(info.UserTime),
+			kernel: uint64(info.KernelTime - info.IdleTime),
+			idle:   uint64(info.IdleTime),
+		}
+	}
+	return times, nil
+}
+
+// getCoreNodes maps logical processors in group 0 to their NUMA node
+func getCoreNodes() map[int]int {
+	nodes := make(map[int]int)
+
+	var highest uint32
+	ret, _, _ := procGetNumaHighestNodeNumber.Call(uintptr(unsafe.Pointer(&highest)))
+	if ret == 0 {
+		return nodes
+	}
+
+	for node := uint32(0); node <= highest; node++ {
+		var affinity GROUP_AFFINITY
+		ret, _, _ := procGetNumaNodeProcessorMaskEx.Call(uintptr(node), uintptr(unsafe.Pointer(&affinity)))
+		if ret == 0 || affinity.Group != 0 {
+			continue
+		}
+		for cpu := 0; cpu < int(unsafe.Sizeof(affinity.Mask))*8; cpu++ {
+			if affinity.Mask&(1<<uint(cpu)) != 0 {
+				nodes[cpu] = int(node)
+			}
+		}
+	}
+	return nodes
+}
+
 // getSystemTimes retrieves system CPU times
 func (c *CPUCollector) getSystemTimes() (idle, kernel, user uint64, err error) {
 	var idleTime, kernelTime, userTime windows.Filetime
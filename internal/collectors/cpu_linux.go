@@ -0,0 +1,497 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides CPU metrics collection for Linux
+package collectors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// sysNodeRoot lists NUMA nodes and their CPUs
+const sysNodeRoot = "/sys/devices/system/node"
+
+// sysDMIRoot holds firmware system identification
+const sysDMIRoot = "/sys/class/dmi/id"
+
+// CPUCollector collects CPU metrics
+type CPUCollector struct {
+	mu          sync.RWMutex
+	lastStat    *procStat
+	lastSoftIRQ map[string]uint64
+	lastCollect time.Time
+	coreCount   int
+	coreNodes   map[int]int // logical processor -> NUMA node
+	environment *models.EnvironmentInfo
+	cgroup      *cgroup // nil when not discoverable
+	lastCgroup  *cgroupCPUSample
+}
+
+// cgroupCPUSample holds cumulative cgroup CPU counters
+type cgroupCPUSample struct {
+	path          string
+	version       int
+	quotaCores    float64
+	usage         time.Duration
+	periods       uint64
+	throttled     uint64
+	throttledTime time.Duration
+	at            time.Time
+}
+
+// procStat holds the counters read from /proc/stat
+type procStat struct {
+	total           cpuTimes
+	cores           map[int]cpuTimes
+	contextSwitches uint64
+	interrupts      uint64
+	procsRunning    uint64
+	procsBlocked    uint64
+}
+
+// NewCPUCollector creates a new CPU collector
+func NewCPUCollector() (*CPUCollector, error) {
+	stat, err := readProcStat()
+	if err != nil {
+		return nil, err
+	}
+
+	softirqs, _ := readSoftIRQs()
+
+	c := &CPUCollector{
+		lastStat:    stat,
+		lastSoftIRQ: softirqs,
+		lastCollect: time.Now(),
+		coreCount:   runtime.NumCPU(),
+		coreNodes:   readCoreNodes(),
+		environment: detectEnvironment(),
+	}
+	if cg, err := findCgroup(0); err == nil {
+		c.cgroup = cg
+		c.lastCgroup, _ = readCgroupCPU(cg)
+	}
+	time.Sleep(100 * time.Millisecond) // Brief pause for initial reading
+	return c, nil
+}
+
+// Name returns the collector name
+func (c *CPUCollector) Name() string {
+	return "cpu"
+}
+
+// Collect gathers CPU metrics
+func (c *CPUCollector) Collect(ctx context.Context) (*models.CPUMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := &models.CPUMetrics{
+		CoreCount:   c.coreCount,
+		Environment: c.environment,
+	}
+
+	stat, err := readProcStat()
+	if err != nil {
+		return metrics, err
+	}
+
+	total := coreUsage(-1, c.lastStat.total, stat.total)
+	metrics.TotalPercent = total.TotalPercent
+	metrics.UserPercent = total.UserPercent
+	metrics.KernelPercent = total.KernelPercent
+	metrics.IdlePercent = total.IdlePercent + total.IOWaitPercent
+	metrics.StealPercent = total.StealPercent
+	if stat.total.total() > c.lastStat.total.total() && stat.total.guest >= c.lastStat.total.guest {
+		metrics.GuestPercent = float64(stat.total.guest-c.lastStat.total.guest) /
+			float64(stat.total.total()-c.lastStat.total.total()) * 100
+	}
+
+	// Per-core breakdown, NUMA grouping and hot cores
+	cores := make([]models.CoreUsage, 0, len(stat.cores))
+	for id, cur := range stat.cores {
+		if prev, ok := c.lastStat.cores[id]; ok {
+			cores = append(cores, coreUsage(id, prev, cur))
+		}
+	}
+	summarizeCores(metrics, cores, c.coreNodes)
+
+	// Context switches, interrupts and run queue
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+	metrics.ContextSwitchesPerSec = uint64(counterRate(stat.contextSwitches, c.lastStat.contextSwitches, elapsed))
+	metrics.InterruptsPerSec = uint64(counterRate(stat.interrupts, c.lastStat.interrupts, elapsed))
+	metrics.RunnableTasks = stat.procsRunning
+	metrics.BlockedTasks = stat.procsBlocked
+	if stat.procsRunning > uint64(c.coreCount) {
+		metrics.ProcessorQueueLength = stat.procsRunning - uint64(c.coreCount)
+	}
+
+	if softirqs, err := readSoftIRQs(); err == nil {
+		metrics.SoftIRQPerSec = make(map[string]float64, len(softirqs))
+		for name, count := range softirqs {
+			metrics.SoftIRQPerSec[name] = counterRate(count, c.lastSoftIRQ[name], elapsed)
+		}
+		c.lastSoftIRQ = softirqs
+	}
+
+	if load, err := readLoadAverage(); err == nil {
+		metrics.LoadAverage = load
+	}
+
+	// Usage against the cgroup quota and throttling in the interval
+	if c.cgroup != nil {
+		if sample, err := readCgroupCPU(c.cgroup); err == nil {
+			metrics.Cgroup = cgroupCPUDelta(c.lastCgroup, sample)
+			c.lastCgroup = sample
+		}
+	}
+
+	c.lastStat = stat
+	c.lastCollect = now
+
+	return metrics, nil
+}
+
+// readProcStat parses the cpu, ctxt, intr and procs lines of /proc/stat
+func readProcStat() (*procStat, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/stat: %w", err)
+	}
+	defer f.Close()
+
+	stat := &procStat{cores: make(map[int]cpuTimes)}
+
+	// intr lists every interrupt source; the first value is the total
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "ctxt":
+			stat.contextSwitches, _ = strconv.ParseUint(fields[1], 10, 64)
+			continue
+		case "intr":
+			stat.interrupts, _ = strconv.ParseUint(fields[1], 10, 64)
+			continue
+		case "procs_running":
+			stat.procsRunning, _ = strconv.ParseUint(fields[1], 10, 64)
+			continue
+		case "procs_blocked":
+			stat.procsBlocked, _ = strconv.ParseUint(fields[1], 10, 64)
+			continue
+		}
+		if !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		times := parseCPUTimes(fields[1:])
+		if fields[0] == "cpu" {
+			stat.total = times
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu")); err == nil {
+			stat.cores[id] = times
+		}
+	}
+
+	return stat, scanner.Err()
+}
+
+// readSoftIRQs returns /proc/softirqs counts per type, summed over all CPUs
+func readSoftIRQs() (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(procRoot, "softirqs"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// The header line lists CPU names and has no "TYPE:" column
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		var total uint64
+		for _, field := range fields[1:] {
+			v, _ := strconv.ParseUint(field, 10, 64)
+			total += v
+		}
+		counts[strings.TrimSuffix(fields[0], ":")] = total
+	}
+	return counts, scanner.Err()
+}
+
+// readLoadAverage reads the 1, 5 and 15 minute load averages
+func readLoadAverage() (*models.LoadAverage, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "loadavg"))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected loadavg: %q", data)
+	}
+	load := &models.LoadAverage{}
+	for i, dst := range []*float64{&load.Load1, &load.Load5, &load.Load15} {
+		if *dst, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, err
+		}
+	}
+	return load, nil
+}
+
+// parseCPUTimes maps the jiffy columns of a /proc/stat cpu line:
+// user nice system idle iowait irq softirq steal guest guest_nice.
+// Guest time is already included in user and nice.
+func parseCPUTimes(fields []string) cpuTimes {
+	values := make([]uint64, 8)
+	for i := 0; i < len(values) && i < len(fields); i++ {
+		values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+	}
+
+	var guest uint64
+	for i := 8; i < 10 && i < len(fields); i++ {
+		v, _ := strconv.ParseUint(fields[i], 10, 64)
+		guest += v
+	}
+
+	return cpuTimes{
+		user:   values[0] + values[1],
+		kernel: values[2] + values[5] + values[6],
+		idle:   values[3],
+		iowait: values[4],
+		steal:  values[7],
+		guest:  guest,
+	}
+}
+
+// readCgroupCPU reads the quota and cumulative usage and throttling counters
+// of a cgroup's cpu controller
+func readCgroupCPU(cg *cgroup) (*cgroupCPUSample, error) {
+	ctrl, ok := cg.controller("cpu")
+	if !ok {
+		return nil, fmt.Errorf("cpu controller not mounted")
+	}
+
+	sample := &cgroupCPUSample{path: ctrl.path, version: ctrl.version, at: time.Now()}
+
+	stat, err := readCgroupStat(ctrl.dir, "cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	sample.periods = stat["nr_periods"]
+	sample.throttled = stat["nr_throttled"]
+
+	if ctrl.version == 2 {
+		// cpu.max is "$MAX $PERIOD" with "max" meaning unlimited
+		max, err := readCgroupValue(ctrl.dir, "cpu.max")
+		if err != nil {
+			return nil, err
+		}
+		if fields := strings.Fields(max); len(fields) == 2 && fields[0] != "max" {
+			quota, _ := strconv.ParseFloat(fields[0], 64)
+			period, _ := strconv.ParseFloat(fields[1], 64)
+			if period > 0 {
+				sample.quotaCores = quota / period
+			}
+		}
+		sample.usage = time.Duration(stat["usage_usec"]) * time.Microsecond
+		sample.throttledTime = time.Duration(stat["throttled_usec"]) * time.Microsecond
+		return sample, nil
+	}
+
+	// v1: quota of -1 means unlimited, usage lives in the cpuacct controller
+	quota, err := readCgroupValue(ctrl.dir, "cpu.cfs_quota_us")
+	if err != nil {
+		return nil, err
+	}
+	if q, err := strconv.ParseFloat(quota, 64); err == nil && q > 0 {
+		if period, ok := readCgroupUint(ctrl.dir, "cpu.cfs_period_us"); ok && period > 0 {
+			sample.quotaCores = q / float64(period)
+		}
+	}
+	sample.throttledTime = time.Duration(stat["throttled_time"])
+	if acct, ok := cg.controller("cpuacct"); ok {
+		if usage, ok := readCgroupUint(acct.dir, "cpuacct.usage"); ok {
+			sample.usage = time.Duration(usage)
+		}
+	}
+	return sample, nil
+}
+
+// cgroupCPUDelta converts two cgroup CPU samples into interval metrics
+func cgroupCPUDelta(prev, cur *cgroupCPUSample) *models.CgroupCPUMetrics {
+	metrics := &models.CgroupCPUMetrics{
+		Path:       cur.path,
+		Version:    cur.version,
+		QuotaCores: cur.quotaCores,
+	}
+	if prev == nil {
+		return metrics
+	}
+
+	elapsed := cur.at.Sub(prev.at).Seconds()
+	if elapsed > 0 && cur.usage >= prev.usage {
+		metrics.UsageCores = (cur.usage - prev.usage).Seconds() / elapsed
+	}
+	if cur.quotaCores > 0 {
+		metrics.UsagePercentOfQuota = metrics.UsageCores / cur.quotaCores * 100
+	}
+	if cur.periods >= prev.periods && cur.throttled >= prev.throttled {
+		metrics.Periods = cur.periods - prev.periods
+		metrics.ThrottledPeriods = cur.throttled - prev.throttled
+	}
+	if metrics.Periods > 0 {
+		metrics.ThrottledPercent = float64(metrics.ThrottledPeriods) / float64(metrics.Periods) * 100
+	}
+	if cur.throttledTime >= prev.throttledTime {
+		metrics.ThrottledSeconds = (cur.throttledTime - prev.throttledTime).Seconds()
+	}
+	return metrics
+}
+
+// detectEnvironment identifies the hypervisor and container runtime
+func detectEnvironment() *models.EnvironmentInfo {
+	env := &models.EnvironmentInfo{}
+
+	// Xen exposes its type directly; otherwise identify from DMI vendor strings
+	if data, err := os.ReadFile("/sys/hypervisor/type"); err == nil && strings.TrimSpace(string(data)) != "" {
+		env.Hypervisor = matchHypervisor(strings.TrimSpace(string(data)), "")
+	}
+	if env.Hypervisor == "" {
+		vendor, _ := os.ReadFile(filepath.Join(sysDMIRoot, "sys_vendor"))
+		product, _ := os.ReadFile(filepath.Join(sysDMIRoot, "product_name"))
+		env.Hypervisor = matchHypervisor(string(vendor), string(product))
+	}
+	// The CPU "hypervisor" flag is set under any hypervisor, even when DMI is hidden
+	if env.Hypervisor == "" && cpuHasHypervisorFlag() {
+		env.Hypervisor = "unknown"
+	}
+
+	env.Container = detectContainer()
+	env.Virtualized = env.Hypervisor != "" || env.Container != ""
+	return env
+}
+
+// cpuHasHypervisorFlag checks /proc/cpuinfo for the hypervisor CPU flag
+func cpuHasHypervisorFlag() bool {
+	f, err := os.Open(filepath.Join(procRoot, "cpuinfo"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "flags") {
+			for _, flag := range strings.Fields(line) {
+				if flag == "hypervisor" {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// detectContainer identifies the container runtime from marker files,
+// environment and the cgroup of PID 1
+func detectContainer() string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	if runtime := os.Getenv("container"); runtime != "" {
+		return runtime
+	}
+
+	data, err := os.ReadFile(filepath.Join(procRoot, "1", "cgroup"))
+	if err != nil {
+		return ""
+	}
+	cgroups := string(data)
+	for _, marker := range []string{"kubepods", "docker", "containerd", "lxc"} {
+		if strings.Contains(cgroups, marker) {
+			if marker == "kubepods" {
+				return "kubernetes"
+			}
+			return marker
+		}
+	}
+	return ""
+}
+
+// readCoreNodes maps logical processors to NUMA nodes from sysfs
+func readCoreNodes() map[int]int {
+	nodes := make(map[int]int)
+
+	entries, err := os.ReadDir(sysNodeRoot)
+	if err != nil {
+		return nodes
+	}
+
+	for _, entry := range entries {
+		node, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "node"))
+		if err != nil || !strings.HasPrefix(entry.Name(), "node") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(sysNodeRoot, entry.Name(), "cpulist"))
+		if err != nil {
+			continue
+		}
+		for _, cpu := range parseCPUList(strings.TrimSpace(string(data))) {
+			nodes[cpu] = node
+		}
+	}
+	return nodes
+}
+
+// parseCPUList expands a sysfs CPU list such as "0-3,8,10-11"
+func parseCPUList(list string) []int {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus
+}
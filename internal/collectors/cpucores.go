@@ -0,0 +1,102 @@
+// Package collectors provides per-core CPU utilization and hot core detection
+package collectors
+
+import (
+	"sort"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Hot core thresholds: one core saturated while the average still looks healthy
+const (
+	hotCorePercent    = 90.0
+	hotCoreAverageMax = 70.0
+)
+
+// cpuTimes holds cumulative CPU time of one processor. Kernel excludes idle
+// time; units only need to be consistent between samples.
+type cpuTimes struct {
+	user   uint64
+	kernel uint64
+	idle   uint64
+	iowait uint64
+	steal  uint64
+	guest  uint64 // already included in user, not counted in total
+}
+
+// total returns the sum of all accounted time
+func (t cpuTimes) total() uint64 {
+	return t.user + t.kernel + t.idle + t.iowait + t.steal
+}
+
+// coreUsage computes the utilization breakdown between two samples
+func coreUsage(id int, prev, cur cpuTimes) models.CoreUsage {
+	usage := models.CoreUsage{ID: id}
+
+	// A processor that went offline and back restarts its counters
+	if cur.total() <= prev.total() {
+		return usage
+	}
+	delta := float64(cur.total() - prev.total())
+
+	percent := func(now, before uint64) float64 {
+		if now < before {
+			return 0
+		}
+		return float64(now-before) / delta * 100
+	}
+
+	usage.UserPercent = percent(cur.user, prev.user)
+	usage.KernelPercent = percent(cur.kernel, prev.kernel)
+	usage.IdlePercent = percent(cur.idle, prev.idle)
+	usage.IOWaitPercent = percent(cur.iowait, prev.iowait)
+	usage.StealPercent = percent(cur.steal, prev.steal)
+	usage.TotalPercent = usage.UserPercent + usage.KernelPercent + usage.StealPercent
+	return usage
+}
+
+// summarizeCores stores per-core usage on the metrics and derives NUMA node
+// averages and hot cores. coreNodes maps core IDs to NUMA nodes.
+func summarizeCores(metrics *models.CPUMetrics, cores []models.CoreUsage, coreNodes map[int]int) {
+	sort.Slice(cores, func(i, j int) bool { return cores[i].ID < cores[j].ID })
+
+	metrics.Cores = cores
+	metrics.PerCorePercent = make([]float64, len(cores))
+
+	nodes := make(map[int]*models.NUMANodeUsage)
+	for i := range cores {
+		core := &cores[i]
+		core.NUMANode = coreNodes[core.ID]
+		metrics.PerCorePercent[i] = core.TotalPercent
+
+		node, ok := nodes[core.NUMANode]
+		if !ok {
+			node = &models.NUMANodeUsage{Node: core.NUMANode}
+			nodes[core.NUMANode] = node
+		}
+		node.Cores = append(node.Cores, core.ID)
+		node.TotalPercent += core.TotalPercent
+		if core.TotalPercent > node.MaxPercent {
+			node.MaxPercent = core.TotalPercent
+		}
+	}
+
+	if len(nodes) > 1 {
+		for _, node := range nodes {
+			node.TotalPercent /= float64(len(node.Cores))
+			metrics.NUMANodes = append(metrics.NUMANodes, *node)
+		}
+		sort.Slice(metrics.NUMANodes, func(i, j int) bool {
+			return metrics.NUMANodes[i].Node < metrics.NUMANodes[j].Node
+		})
+	}
+
+	// A single saturated core is only interesting when the average hides it
+	if len(cores) > 1 && metrics.TotalPercent < hotCoreAverageMax {
+		for _, core := range cores {
+			if core.TotalPercent >= hotCorePercent {
+				metrics.HotCores = append(metrics.HotCores, core.ID)
+			}
+		}
+	}
+}
@@ -7,6 +7,10 @@ package collectors
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
 	"loadrunner-diagnosis/internal/models"
@@ -20,8 +24,53 @@ var (
 	procGetLogicalDrives    = diskKernel32.NewProc("GetLogicalDrives")
 )
 
+// Disk IOCTL control codes
+const (
+	IOCTL_DISK_PERFORMANCE               = 0x00070020
+	IOCTL_DISK_GET_LENGTH_INFO           = 0x0007405C
+	IOCTL_VOLUME_GET_VOLUME_DISK_EXTENTS = 0x00560000
+)
+
+// DISK_PERFORMANCE structure; times are in 100ns units
+type DISK_PERFORMANCE struct {
+	BytesRead           int64
+	BytesWritten        int64
+	ReadTime            int64
+	WriteTime           int64
+	IdleTime            int64
+	ReadCount           uint32
+	WriteCount          uint32
+	QueueDepth          uint32
+	SplitCount          uint32
+	QueryTime           int64
+	StorageDeviceNumber uint32
+	StorageManagerName  [8]uint16
+}
+
+// DISK_EXTENT structure
+type DISK_EXTENT struct {
+	DiskNumber     uint32
+	_              uint32
+	StartingOffset int64
+	ExtentLength   int64
+}
+
+// VOLUME_DISK_EXTENTS structure with room for volumes spanning several disks
+type VOLUME_DISK_EXTENTS struct {
+	NumberOfDiskExtents uint32
+	_                   uint32
+	Extents             [8]DISK_EXTENT
+}
+
 // DiskCollector collects disk I/O metrics
-type DiskCollector struct{}
+type DiskCollector struct {
+	mu          sync.Mutex
+	last        map[string]diskCounters // by device path
+	lastCollect time.Time
+	latency     *diskLatencyTracker
+	skipLatency bool // the next tick spans the time before the run, so it is not recorded
+	forecast    *capacityForecaster
+}
 
 // NewDiskCollector creates a new disk collector
 func NewDiskCollector() (*DiskCollector, error) {
@@ -35,7 +84,29 @@ func NewDiskCollector() (*DiskCollector, error) {
 	if err := procGetLogicalDrives.Find(); err != nil {
 		return nil, fmt.Errorf("GetLogicalDrives not found: %w", err)
 	}
-	return &DiskCollector{}, nil
+	return &DiskCollector{
+		last:        make(map[string]diskCounters),
+		lastCollect: time.Now(),
+		latency:     newDiskLatencyTracker(defaultDiskLatencySLA),
+		forecast:    newCapacityForecaster(),
+	}, nil
+}
+
+// SetTestEnd sets when the load test is expected to end, for the
+// volume-full forecast (zero = use the default horizon)
+func (c *DiskCollector) SetTestEnd(end time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forecast.testEnd = end
+}
+
+// ResetLatency starts new latency distributions, counting intervals whose
+// average latency exceeds sla (0 = default)
+func (c *DiskCollector) ResetLatency(sla time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = newDiskLatencyTracker(sla)
+	c.skipLatency = true
 }
 
 // Name returns the collector name
@@ -43,10 +114,23 @@ func (c *DiskCollector) Name() string {
 	return "disk"
 }
 
-// Collect gathers disk metrics
+// Collect gathers disk metrics. The latency distributions of the run are
+// reported but not added to; only monitoring ticks add to them.
 func (c *DiskCollector) Collect(ctx context.Context) (*models.DiskMetrics, error) {
+	return c.collect(ctx, false)
+}
+
+// CollectTick gathers disk metrics for a monitoring tick and records the
+// interval latencies into the distributions of the run
+func (c *DiskCollector) CollectTick(ctx context.Context) (*models.DiskMetrics, error) {
+	return c.collect(ctx, true)
+}
+
+// collect gathers disk metrics, recording latencies when tick is set
+func (c *DiskCollector) collect(ctx context.Context, tick bool) (*models.DiskMetrics, error) {
 	metrics := &models.DiskMetrics{
-		Disks: []models.DiskInfo{},
+		Disks:   []models.DiskInfo{},
+		Devices: []models.DiskInfo{},
 	}
 
 	// Safety check
@@ -54,25 +138,246 @@ func (c *DiskCollector) Collect(ctx context.Context) (*models.DiskMetrics, error
 		return metrics, fmt.Errorf("disk collector is nil")
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// Get logical drives
 	drives, err := c.getLogicalDrives()
 	if err != nil {
 		return metrics, err
 	}
 
-	for _, drive := range drives {
-		info, err := c.getDriveInfo(drive)
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect)
+	counters := make(map[string]diskCounters)
+	record := tick && !c.skipLatency
+	if tick {
+		c.skipLatency = false
+	}
+
+	// stats fills I/O rates from the performance counters of a volume or disk
+	stats := func(info *models.DiskInfo, path string) {
+		perf, err := queryDiskPerformance(path)
 		if err != nil {
+			return
+		}
+		cur := diskPerformanceCounters(perf)
+		if prev, ok := c.last[path]; ok {
+			diskRates(info, prev, cur, elapsed)
+			if record {
+				c.latency.Record(info, elapsed)
+			} else {
+				c.latency.Fill(info)
+			}
+		} else {
+			info.QueueLength = cur.inFlight
+		}
+		counters[path] = cur
+	}
+
+	// Mounted volumes, by their first mount path (drive letter or folder)
+	disks := make(map[uint32]bool)
+	mounted := make(map[string]bool)
+	for _, vol := range listVolumes() {
+		info, err := c.getDriveInfo(vol.paths[0])
+		if err != nil || info == nil {
 			continue
 		}
-		if info != nil {
-			metrics.Disks = append(metrics.Disks, *info)
+		for _, path := range vol.paths {
+			mounted[strings.ToUpper(path)] = true
+		}
+		info.FileSystem, info.ReadOnly = volumeFileSystem(vol.paths[0])
+		if disk, ok := volumeDiskNumber(vol.device); ok {
+			info.Device = fmt.Sprintf("PhysicalDrive%d", disk)
+			disks[disk] = true
 		}
+		stats(info, vol.device)
+		c.forecast.Forecast(info, now)
+		metrics.Disks = append(metrics.Disks, *info)
 	}
 
+	// Drive letters that are not local volumes (network shares, subst)
+	for _, drive := range drives {
+		if mounted[drive] {
+			continue
+		}
+		info, err := c.getDriveInfo(drive)
+		if err != nil || info == nil {
+			continue
+		}
+		info.FileSystem, info.ReadOnly = volumeFileSystem(drive)
+		c.forecast.Forecast(info, now)
+		metrics.Disks = append(metrics.Disks, *info)
+	}
+
+	// Physical disks holding the volumes above
+	numbers := make([]uint32, 0, len(disks))
+	for disk := range disks {
+		numbers = append(numbers, disk)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, disk := range numbers {
+		name := fmt.Sprintf("PhysicalDrive%d", disk)
+		path := `\\.\` + name
+		info := models.DiskInfo{Name: name, Device: name}
+		info.TotalBytes, _ = diskLength(path)
+		stats(&info, path)
+		metrics.Devices = append(metrics.Devices, info)
+	}
+
+	c.last = counters
+	c.lastCollect = now
 	return metrics, nil
 }
 
+// volume is a mounted volume and the paths it is mounted at
+type volume struct {
+	device string   // \\?\Volume{GUID} without the trailing backslash
+	paths  []string // drive letters and mounted folders, with trailing backslash
+}
+
+// listVolumes enumerates volumes mounted at a drive letter or folder
+func listVolumes() []volume {
+	buf := make([]uint16, windows.MAX_PATH)
+	h, err := windows.FindFirstVolume(&buf[0], uint32(len(buf)))
+	if err != nil {
+		return nil
+	}
+	defer windows.FindVolumeClose(h)
+
+	var volumes []volume
+	for {
+		name := windows.UTF16ToString(buf)
+		if paths := volumePaths(name); len(paths) > 0 {
+			volumes = append(volumes, volume{device: strings.TrimSuffix(name, `\`), paths: paths})
+		}
+		if err := windows.FindNextVolume(h, &buf[0], uint32(len(buf))); err != nil {
+			break
+		}
+	}
+	return volumes
+}
+
+// volumePaths returns the drive letters and folders a volume is mounted at
+func volumePaths(name string) []string {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil
+	}
+
+	buf := make([]uint16, 1024)
+	var length uint32
+	if err := windows.GetVolumePathNamesForVolumeName(namePtr, &buf[0], uint32(len(buf)), &length); err != nil {
+		return nil
+	}
+
+	// The result is a list of NUL-terminated strings ending with an empty string
+	var paths []string
+	for start := 0; start < len(buf) && buf[start] != 0; {
+		end := start
+		for end < len(buf) && buf[end] != 0 {
+			end++
+		}
+		paths = append(paths, windows.UTF16ToString(buf[start:end]))
+		start = end + 1
+	}
+	return paths
+}
+
+// volumeFileSystem returns the filesystem name of a volume and whether it is read-only
+func volumeFileSystem(root string) (string, bool) {
+	rootPtr, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return "", false
+	}
+
+	var flags uint32
+	fsName := make([]uint16, windows.MAX_PATH)
+	if err := windows.GetVolumeInformation(rootPtr, nil, 0, nil, nil, &flags, &fsName[0], uint32(len(fsName))); err != nil {
+		return "", false
+	}
+	return windows.UTF16ToString(fsName), flags&windows.FILE_READ_ONLY_VOLUME != 0
+}
+
+// diskPerformanceCounters converts DISK_PERFORMANCE into cumulative counters.
+// The average queue length follows Little's law from the time spent on IOs.
+func diskPerformanceCounters(p DISK_PERFORMANCE) diskCounters {
+	return diskCounters{
+		readBytes:  uint64(p.BytesRead),
+		writeBytes: uint64(p.BytesWritten),
+		reads:      uint64(p.ReadCount),
+		writes:     uint64(p.WriteCount),
+		readTime:   time.Duration(p.ReadTime) * 100,
+		writeTime:  time.Duration(p.WriteTime) * 100,
+		busyTime:   time.Duration(p.QueryTime-p.IdleTime) * 100,
+		queueTime:  time.Duration(p.ReadTime+p.WriteTime) * 100,
+		inFlight:   uint64(p.QueueDepth),
+	}
+}
+
+// openDevice opens a volume or physical disk for IOCTLs that need no access rights
+func openDevice(path string) (windows.Handle, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	return windows.CreateFile(pathPtr, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+}
+
+// queryDiskPerformance reads the cumulative I/O counters of a volume or disk
+func queryDiskPerformance(path string) (DISK_PERFORMANCE, error) {
+	var perf DISK_PERFORMANCE
+	h, err := openDevice(path)
+	if err != nil {
+		return perf, err
+	}
+	defer windows.CloseHandle(h)
+
+	var returned uint32
+	err = windows.DeviceIoControl(h, IOCTL_DISK_PERFORMANCE, nil, 0,
+		(*byte)(unsafe.Pointer(&perf)), uint32(unsafe.Sizeof(perf)), &returned, nil)
+	if err != nil {
+		return perf, fmt.Errorf("IOCTL_DISK_PERFORMANCE failed on %s: %w", path, err)
+	}
+	return perf, nil
+}
+
+// volumeDiskNumber returns the physical disk holding the first extent of a volume
+func volumeDiskNumber(volume string) (uint32, bool) {
+	h, err := openDevice(volume)
+	if err != nil {
+		return 0, false
+	}
+	defer windows.CloseHandle(h)
+
+	var extents VOLUME_DISK_EXTENTS
+	var returned uint32
+	err = windows.DeviceIoControl(h, IOCTL_VOLUME_GET_VOLUME_DISK_EXTENTS, nil, 0,
+		(*byte)(unsafe.Pointer(&extents)), uint32(unsafe.Sizeof(extents)), &returned, nil)
+	if err != nil || extents.NumberOfDiskExtents == 0 {
+		return 0, false
+	}
+	return extents.Extents[0].DiskNumber, true
+}
+
+// diskLength returns the size of a physical disk in bytes
+func diskLength(path string) (uint64, error) {
+	h, err := openDevice(path)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var length int64
+	var returned uint32
+	err = windows.DeviceIoControl(h, IOCTL_DISK_GET_LENGTH_INFO, nil, 0,
+		(*byte)(unsafe.Pointer(&length)), uint32(unsafe.Sizeof(length)), &returned, nil)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(length), nil
+}
+
 // getLogicalDrives returns a list of available drive letters
 func (c *DiskCollector) getLogicalDrives() ([]string, error) {
 	ret, _, _ := procGetLogicalDrives.Call()
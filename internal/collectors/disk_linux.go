@@ -0,0 +1,343 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides disk I/O metrics collection
+package collectors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysBlockRoot lists block devices and their attributes
+const sysBlockRoot = "/sys/class/block"
+
+// diskSectorSize is the unit of the sector counters in /proc/diskstats,
+// independent of the device's real sector size
+const diskSectorSize = 512
+
+// DiskCollector collects disk I/O metrics from /proc/diskstats
+type DiskCollector struct {
+	mu          sync.Mutex
+	last        map[string]diskCounters // by kernel device name
+	lastCollect time.Time
+	latency     *diskLatencyTracker
+	skipLatency bool // the next tick spans the time before the run, so it is not recorded
+	forecast    *capacityForecaster
+}
+
+// NewDiskCollector creates a new disk collector
+func NewDiskCollector() (*DiskCollector, error) {
+	counters, err := readDiskStats()
+	if err != nil {
+		return nil, err
+	}
+	return &DiskCollector{
+		last:        counters,
+		lastCollect: time.Now(),
+		latency:     newDiskLatencyTracker(defaultDiskLatencySLA),
+		forecast:    newCapacityForecaster(),
+	}, nil
+}
+
+// SetTestEnd sets when the load test is expected to end, for the
+// volume-full forecast (zero = use the default horizon)
+func (c *DiskCollector) SetTestEnd(end time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forecast.testEnd = end
+}
+
+// ResetLatency starts new latency distributions, counting intervals whose
+// average latency exceeds sla (0 = default)
+func (c *DiskCollector) ResetLatency(sla time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = newDiskLatencyTracker(sla)
+	c.skipLatency = true
+}
+
+// Name returns the collector name
+func (c *DiskCollector) Name() string {
+	return "disk"
+}
+
+// Collect gathers disk metrics. The latency distributions of the run are
+// reported but not added to; only monitoring ticks add to them.
+func (c *DiskCollector) Collect(ctx context.Context) (*models.DiskMetrics, error) {
+	return c.collect(ctx, false)
+}
+
+// CollectTick gathers disk metrics for a monitoring tick and records the
+// interval latencies into the distributions of the run
+func (c *DiskCollector) CollectTick(ctx context.Context) (*models.DiskMetrics, error) {
+	return c.collect(ctx, true)
+}
+
+// collect gathers disk metrics, recording latencies when tick is set
+func (c *DiskCollector) collect(ctx context.Context, tick bool) (*models.DiskMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := &models.DiskMetrics{
+		Disks:   []models.DiskInfo{},
+		Devices: []models.DiskInfo{},
+	}
+
+	counters, err := readDiskStats()
+	if err != nil {
+		return metrics, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect)
+	record := tick && !c.skipLatency
+	if tick {
+		c.skipLatency = false
+	}
+
+	// A device without a previous sample (attached since) only gets rates
+	// from the next collection; its totals are not one interval's I/O
+	stats := func(info *models.DiskInfo, device string) {
+		cur, ok := counters[device]
+		if !ok {
+			return
+		}
+		prev, ok := c.last[device]
+		if !ok {
+			info.QueueLength = cur.inFlight
+			return
+		}
+		diskRates(info, prev, cur, elapsed)
+		if record {
+			c.latency.Record(info, elapsed)
+		} else {
+			c.latency.Fill(info)
+		}
+	}
+
+	// Physical devices: whole disks that are not virtual (loop, ram, dm, md)
+	entries, _ := os.ReadDir(sysBlockRoot)
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := counters[name]; !ok || isPartition(name) || isVirtualBlockDevice(name) {
+			continue
+		}
+		info := models.DiskInfo{Name: name, Device: name}
+		if sectors, err := readSysUint(filepath.Join(sysBlockRoot, name, "size")); err == nil {
+			info.TotalBytes = sectors * diskSectorSize
+		}
+		stats(&info, name)
+		metrics.Devices = append(metrics.Devices, info)
+	}
+
+	// Mounted filesystems, one per filesystem (bind mounts share it)
+	for _, mount := range volumeMounts() {
+		var st unix.Statfs_t
+		if err := unix.Statfs(mount.mountPoint, &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+
+		// Block counts are in fragment-size units. Free space is what
+		// unprivileged processes can still use, without the blocks reserved
+		// for root (5% of ext4 by default).
+		info := models.DiskInfo{
+			Name:        mount.mountPoint,
+			FileSystem:  mount.fsType,
+			ReadOnly:    hasMountOption(mount.options, "ro"),
+			TotalBytes:  st.Blocks * uint64(st.Frsize),
+			FreeBytes:   st.Bavail * uint64(st.Frsize),
+			TotalInodes: st.Files,
+			FreeInodes:  st.Ffree,
+		}
+		// Used share of the space non-root processes can have, as df reports it
+		if used := (st.Blocks - st.Bfree) * uint64(st.Frsize); used+info.FreeBytes > 0 {
+			info.UsedPercent = float64(used) / float64(used+info.FreeBytes) * 100
+		}
+		if info.TotalInodes > 0 {
+			info.InodesUsedPercent = float64(info.TotalInodes-info.FreeInodes) / float64(info.TotalInodes) * 100
+		}
+		if mount.blockDevice != "" {
+			info.Device = parentBlockDevice(mount.blockDevice)
+			stats(&info, mount.blockDevice)
+		}
+		c.forecast.Forecast(&info, now)
+		metrics.Disks = append(metrics.Disks, info)
+	}
+
+	c.last = counters
+	c.lastCollect = now
+	return metrics, nil
+}
+
+// readDiskStats parses /proc/diskstats:
+// "major minor name reads merged sectors ms_reading writes merged sectors
+// ms_writing in_flight ms_io weighted_ms [discard and flush fields]"
+func readDiskStats() (map[string]diskCounters, error) {
+	f, err := os.Open(filepath.Join(procRoot, "diskstats"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/diskstats: %w", err)
+	}
+	defer f.Close()
+
+	counters := make(map[string]diskCounters)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		v := make([]uint64, 11)
+		for i := range v {
+			v[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+		counters[fields[2]] = diskCounters{
+			reads:      v[0],
+			readBytes:  v[2] * diskSectorSize,
+			readTime:   time.Duration(v[3]) * time.Millisecond,
+			writes:     v[4],
+			writeBytes: v[6] * diskSectorSize,
+			writeTime:  time.Duration(v[7]) * time.Millisecond,
+			inFlight:   v[8],
+			busyTime:   time.Duration(v[9]) * time.Millisecond,
+			queueTime:  time.Duration(v[10]) * time.Millisecond,
+		}
+	}
+	return counters, scanner.Err()
+}
+
+// pseudoFileSystems hold no user data and are not reported as volumes
+var pseudoFileSystems = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "cgroup": true, "cgroup2": true,
+	"mqueue": true, "debugfs": true, "tracefs": true, "securityfs": true, "pstore": true,
+	"bpf": true, "configfs": true, "fusectl": true, "hugetlbfs": true, "autofs": true,
+	"binfmt_misc": true, "rpc_pipefs": true, "nsfs": true, "selinuxfs": true, "efivarfs": true,
+	"squashfs": true,
+}
+
+// volumeMount is a mounted filesystem reported as a volume
+type volumeMount struct {
+	mountInfo
+	blockDevice string // kernel device name, e.g. sda1 or dm-0; empty for tmpfs, overlay or NFS
+}
+
+// volumeMounts lists visible mounted filesystems that can hold data. When a
+// filesystem is mounted more than once (bind mounts) only its shortest mount
+// point is kept.
+func volumeMounts() []volumeMount {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil
+	}
+
+	// A later mount on the same path hides the earlier ones
+	top := make(map[string]int, len(mounts))
+	for i, m := range mounts {
+		top[m.mountPoint] = i
+	}
+
+	byDevice := make(map[string]volumeMount)
+	for i, m := range mounts {
+		if pseudoFileSystems[m.fsType] || top[m.mountPoint] != i {
+			continue
+		}
+		if prev, ok := byDevice[m.device]; ok && len(prev.mountPoint) <= len(m.mountPoint) {
+			continue
+		}
+		v := volumeMount{mountInfo: m}
+		// major:minor resolves to a device name only for block devices
+		if target, err := os.Readlink(filepath.Join("/sys/dev/block", m.device)); err == nil {
+			v.blockDevice = filepath.Base(target)
+		}
+		byDevice[m.device] = v
+	}
+
+	result := make([]volumeMount, 0, len(byDevice))
+	for _, m := range byDevice {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].mountPoint < result[j].mountPoint })
+	return result
+}
+
+// hasMountOption reports whether a comma-separated mount option list contains option
+func hasMountOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isPartition reports whether a block device is a partition of another
+func isPartition(name string) bool {
+	_, err := os.Stat(filepath.Join(sysBlockRoot, name, "partition"))
+	return err == nil
+}
+
+// isVirtualBlockDevice reports whether a block device has no hardware behind it
+func isVirtualBlockDevice(name string) bool {
+	target, err := filepath.EvalSymlinks(filepath.Join(sysBlockRoot, name))
+	return err != nil || strings.Contains(target, "/devices/virtual/")
+}
+
+// parentBlockDevice returns the whole disks a block device is stored on,
+// comma-separated: the disk of a partition, the disks under a device-mapper
+// (LVM, dm-crypt, multipath) or md device, or the device itself
+func parentBlockDevice(name string) string {
+	disks := make(map[string]bool)
+	underlyingDisks(name, disks, 0)
+	if len(disks) == 0 {
+		return name
+	}
+	names := make([]string, 0, len(disks))
+	for disk := range disks {
+		names = append(names, disk)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// maxBlockStackDepth bounds the walk down stacked block devices (e.g. a
+// filesystem on dm-crypt on LVM on md on partitions)
+const maxBlockStackDepth = 8
+
+// underlyingDisks adds the whole disks below a block device to disks,
+// following /sys/block/<device>/slaves down stacked devices
+func underlyingDisks(name string, disks map[string]bool, depth int) {
+	if isPartition(name) {
+		if target, err := filepath.EvalSymlinks(filepath.Join(sysBlockRoot, name)); err == nil {
+			disks[filepath.Base(filepath.Dir(target))] = true
+		}
+		return
+	}
+	slaves, _ := os.ReadDir(filepath.Join(sysBlockRoot, name, "slaves"))
+	if len(slaves) == 0 || depth >= maxBlockStackDepth {
+		disks[name] = true
+		return
+	}
+	for _, slave := range slaves {
+		underlyingDisks(slave.Name(), disks, depth+1)
+	}
+}
+
+// readSysUint reads a sysfs attribute holding one unsigned number
+func readSysUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,78 @@
+// Package collectors provides volume capacity forecasting
+package collectors
+
+import (
+	"time"
+
+	"loadrunner-diagnosis/internal/analyzers"
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Capacity forecast settings
+const (
+	diskHistorySize    = 120       // samples per volume used for the growth forecast
+	diskMinForecast    = 5         // samples required before forecasting
+	diskDefaultHorizon = time.Hour // alert horizon when no test duration is configured
+)
+
+type capacitySample struct {
+	at   time.Time
+	used float64
+}
+
+// capacityForecaster forecasts when volumes fill up from their recent growth.
+// The Theil-Sen fit ignores one-off drops such as a rotated log or a deleted
+// temp file, which would flatten an ordinary least squares slope.
+type capacityForecaster struct {
+	history map[string][]capacitySample // by volume name
+	testEnd time.Time                   // zero when no test duration is configured
+}
+
+// newCapacityForecaster creates an empty forecaster
+func newCapacityForecaster() *capacityForecaster {
+	return &capacityForecaster{history: make(map[string][]capacitySample)}
+}
+
+// Forecast records the usage of a volume and fills its growth rate, time to
+// full and whether it fills before the test ends
+func (f *capacityForecaster) Forecast(info *models.DiskInfo, now time.Time) {
+	info.SecondsToFull = -1
+	if info.TotalBytes == 0 || info.ReadOnly {
+		return
+	}
+
+	history := append(f.history[info.Name], capacitySample{at: now, used: float64(info.TotalBytes - info.FreeBytes)})
+	if len(history) > diskHistorySize {
+		history = history[len(history)-diskHistorySize:]
+	}
+	f.history[info.Name] = history
+	if len(history) < diskMinForecast {
+		return
+	}
+
+	xs := make([]float64, len(history))
+	ys := make([]float64, len(history))
+	for i, s := range history {
+		xs[i] = s.at.Sub(history[0].at).Seconds()
+		ys[i] = s.used
+	}
+
+	slope, _, ok := analyzers.TheilSenFit(xs, ys)
+	if !ok {
+		return
+	}
+
+	// slope is in bytes per second
+	info.GrowthBytesPerSec = slope
+	if slope <= 0 {
+		return
+	}
+	info.SecondsToFull = float64(info.FreeBytes) / slope
+
+	horizon := now.Add(diskDefaultHorizon)
+	if !f.testEnd.IsZero() {
+		horizon = f.testEnd
+	}
+	// Compared in seconds: a Duration overflows past about 292 years
+	info.FillsBeforeTestEnd = info.SecondsToFull <= horizon.Sub(now).Seconds()
+}
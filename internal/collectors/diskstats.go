@@ -0,0 +1,135 @@
+// Package collectors provides disk I/O counter helpers shared by collectors
+package collectors
+
+import (
+	"math"
+	"time"
+
+	"loadrunner-diagnosis/internal/analyzers"
+	"loadrunner-diagnosis/internal/models"
+)
+
+// defaultDiskLatencySLA is the per-interval average latency counted as a breach
+const defaultDiskLatencySLA = 20 * time.Millisecond
+
+// diskCounters are cumulative I/O counters of one disk or volume
+type diskCounters struct {
+	readBytes  uint64
+	writeBytes uint64
+	reads      uint64        // completed reads
+	writes     uint64        // completed writes
+	readTime   time.Duration // total time spent on completed reads
+	writeTime  time.Duration // total time spent on completed writes
+	busyTime   time.Duration // time with at least one IO in flight
+	queueTime  time.Duration // time weighted by the number of IOs in flight
+	inFlight   uint64
+}
+
+// diskRates fills the throughput, IOPS, latency, queue and utilization
+// fields of a disk from two counter samples
+func diskRates(info *models.DiskInfo, prev, cur diskCounters, elapsed time.Duration) {
+	info.QueueLength = cur.inFlight
+
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return
+	}
+
+	info.ReadBytesPerSec = uint64(counterRate(cur.readBytes, prev.readBytes, seconds))
+	info.WriteBytesPerSec = uint64(counterRate(cur.writeBytes, prev.writeBytes, seconds))
+	info.ReadsPerSec = counterRate(cur.reads, prev.reads, seconds)
+	info.WritesPerSec = counterRate(cur.writes, prev.writes, seconds)
+
+	// Latency is the time spent on the IOs completed in the interval divided by their count
+	info.AvgReadLatency = opLatency(cur.readTime-prev.readTime, cur.reads, prev.reads)
+	info.AvgWriteLatency = opLatency(cur.writeTime-prev.writeTime, cur.writes, prev.writes)
+
+	if busy := cur.busyTime - prev.busyTime; busy > 0 {
+		info.BusyPercent = busy.Seconds() / seconds * 100
+		if info.BusyPercent > 100 {
+			info.BusyPercent = 100
+		}
+	}
+	info.IdlePercent = 100 - info.BusyPercent
+
+	if queued := cur.queueTime - prev.queueTime; queued > 0 {
+		info.AvgQueueLength = queued.Seconds() / seconds
+	}
+}
+
+// opLatency returns the average latency in ms of the operations completed
+// between two samples
+func opLatency(spent time.Duration, cur, prev uint64) float64 {
+	if cur <= prev || spent <= 0 {
+		return 0
+	}
+	return float64(spent) / float64(time.Millisecond) / float64(cur-prev)
+}
+
+// diskLatencyTracker keeps per-disk latency distributions over a monitoring run
+type diskLatencyTracker struct {
+	sla   time.Duration
+	disks map[string]*diskLatency
+}
+
+// diskLatency is the latency distribution of one disk, in microseconds
+type diskLatency struct {
+	read      *analyzers.Histogram
+	write     *analyzers.Histogram
+	intervals uint64
+	overSLA   uint64
+}
+
+// newDiskLatencyTracker creates a tracker counting intervals above sla
+func newDiskLatencyTracker(sla time.Duration) *diskLatencyTracker {
+	if sla <= 0 {
+		sla = defaultDiskLatencySLA
+	}
+	return &diskLatencyTracker{sla: sla, disks: make(map[string]*diskLatency)}
+}
+
+// Record adds the interval averages of a disk, weighted by the operations
+// completed in the interval, and fills its latency summary
+func (t *diskLatencyTracker) Record(info *models.DiskInfo, elapsed time.Duration) {
+	d, ok := t.disks[info.Name]
+	if !ok {
+		d = &diskLatency{read: analyzers.NewHistogram(), write: analyzers.NewHistogram()}
+		t.disks[info.Name] = d
+	}
+
+	reads := uint64(math.Round(info.ReadsPerSec * elapsed.Seconds()))
+	writes := uint64(math.Round(info.WritesPerSec * elapsed.Seconds()))
+	if reads > 0 || writes > 0 {
+		d.read.Record(uint64(info.AvgReadLatency*1000), reads)
+		d.write.Record(uint64(info.AvgWriteLatency*1000), writes)
+		d.intervals++
+		slaMs := float64(t.sla) / float64(time.Millisecond)
+		if info.AvgReadLatency > slaMs || info.AvgWriteLatency > slaMs {
+			d.overSLA++
+		}
+	}
+	t.Fill(info)
+}
+
+// Fill sets the latency summary of a disk from what was recorded so far,
+// without adding to it
+func (t *diskLatencyTracker) Fill(info *models.DiskInfo) {
+	d, ok := t.disks[info.Name]
+	if !ok {
+		return
+	}
+	ms := func(us uint64) float64 { return float64(us) / 1000 }
+	info.Latency = &models.DiskLatencyStats{
+		ReadP50:          ms(d.read.Percentile(50)),
+		ReadP95:          ms(d.read.Percentile(95)),
+		ReadP99:          ms(d.read.Percentile(99)),
+		ReadMax:          ms(d.read.Max()),
+		WriteP50:         ms(d.write.Percentile(50)),
+		WriteP95:         ms(d.write.Percentile(95)),
+		WriteP99:         ms(d.write.Percentile(99)),
+		WriteMax:         ms(d.write.Max()),
+		SLAMs:            float64(t.sla) / float64(time.Millisecond),
+		Intervals:        d.intervals,
+		IntervalsOverSLA: d.overSLA,
+	}
+}
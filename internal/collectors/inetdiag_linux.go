@@ -0,0 +1,229 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides netlink sock_diag (inet_diag) socket dumps
+package collectors
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inet_diag extension attribute types
+const (
+	INET_DIAG_INFO = 2
+)
+
+// Linux TCP states (include/net/tcp_states.h)
+const (
+	TCP_ESTABLISHED  = 1
+	TCP_SYN_SENT     = 2
+	TCP_SYN_RECV     = 3
+	TCP_FIN_WAIT1    = 4
+	TCP_FIN_WAIT2    = 5
+	TCP_TIME_WAIT    = 6
+	TCP_CLOSE        = 7
+	TCP_CLOSE_WAIT   = 8
+	TCP_LAST_ACK     = 9
+	TCP_LISTEN       = 10
+	TCP_CLOSING      = 11
+	TCP_NEW_SYN_RECV = 12
+)
+
+// linuxTCPStateNames maps Linux states onto the names used by the Windows collector
+var linuxTCPStateNames = map[uint8]string{
+	TCP_ESTABLISHED:  "ESTABLISHED",
+	TCP_SYN_SENT:     "SYN_SENT",
+	TCP_SYN_RECV:     "SYN_RCVD",
+	TCP_FIN_WAIT1:    "FIN_WAIT1",
+	TCP_FIN_WAIT2:    "FIN_WAIT2",
+	TCP_TIME_WAIT:    "TIME_WAIT",
+	TCP_CLOSE:        "CLOSED",
+	TCP_CLOSE_WAIT:   "CLOSE_WAIT",
+	TCP_LAST_ACK:     "LAST_ACK",
+	TCP_LISTEN:       "LISTEN",
+	TCP_CLOSING:      "CLOSING",
+	TCP_NEW_SYN_RECV: "SYN_RCVD",
+}
+
+// inetDiagSockID mirrors struct inet_diag_sockid (ports and addresses are big-endian)
+type inetDiagSockID struct {
+	SPort  [2]byte
+	DPort  [2]byte
+	Src    [16]byte
+	Dst    [16]byte
+	If     uint32
+	Cookie [2]uint32
+}
+
+// inetDiagReqV2 mirrors struct inet_diag_req_v2
+type inetDiagReqV2 struct {
+	Family   uint8
+	Protocol uint8
+	Ext      uint8
+	Pad      uint8
+	States   uint32
+	ID       inetDiagSockID
+}
+
+// inetDiagMsg mirrors struct inet_diag_msg
+type inetDiagMsg struct {
+	Family  uint8
+	State   uint8
+	Timer   uint8
+	Retrans uint8
+	ID      inetDiagSockID
+	Expires uint32
+	RQueue  uint32
+	WQueue  uint32
+	UID     uint32
+	Inode   uint32
+}
+
+// inetDiagSocket is one socket returned by a sock_diag dump
+type inetDiagSocket struct {
+	LocalAddress  string
+	LocalPort     uint16
+	RemoteAddress string
+	RemotePort    uint16
+	State         uint8
+	RQueue        uint32 // LISTEN: current accept queue, otherwise unread bytes
+	WQueue        uint32 // LISTEN: backlog limit, otherwise unsent bytes
+	UID           uint32
+	Inode         uint32
+	Info          *unix.TCPInfo // only when requested
+}
+
+// dumpTCPSockets dumps all TCP sockets of the given address family (AF_INET or AF_INET6)
+func dumpTCPSockets(family uint8, withInfo bool) ([]inetDiagSocket, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket failed: %w", err)
+	}
+	defer unix.Close(fd)
+
+	req := inetDiagReqV2{
+		Family:   family,
+		Protocol: unix.IPPROTO_TCP,
+		States:   ^uint32(0),
+	}
+	if withInfo {
+		req.Ext = 1 << (INET_DIAG_INFO - 1)
+	}
+
+	hdrLen := unix.SizeofNlMsghdr
+	msg := make([]byte, hdrLen+int(unsafe.Sizeof(req)))
+	hdr := (*unix.NlMsghdr)(unsafe.Pointer(&msg[0]))
+	hdr.Len = uint32(len(msg))
+	hdr.Type = unix.SOCK_DIAG_BY_FAMILY
+	hdr.Flags = unix.NLM_F_REQUEST | unix.NLM_F_DUMP
+	hdr.Seq = 1
+	*(*inetDiagReqV2)(unsafe.Pointer(&msg[hdrLen])) = req
+
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("netlink send failed: %w", err)
+	}
+
+	var sockets []inetDiagSocket
+	buf := make([]byte, os.Getpagesize()*8)
+
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return sockets, fmt.Errorf("netlink receive failed: %w", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return sockets, fmt.Errorf("netlink parse failed: %w", err)
+		}
+
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return sockets, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(binary.LittleEndian.Uint32(m.Data[:4])); errno != 0 {
+						return sockets, fmt.Errorf("inet_diag dump failed: %w", unix.Errno(-errno))
+					}
+				}
+				return sockets, nil
+			case unix.SOCK_DIAG_BY_FAMILY:
+				if sock, ok := parseInetDiagMsg(m.Data); ok {
+					sockets = append(sockets, sock)
+				}
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg decodes an inet_diag_msg and its attributes
+func parseInetDiagMsg(data []byte) (inetDiagSocket, bool) {
+	msgLen := int(unsafe.Sizeof(inetDiagMsg{}))
+	if len(data) < msgLen {
+		return inetDiagSocket{}, false
+	}
+	msg := (*inetDiagMsg)(unsafe.Pointer(&data[0]))
+
+	sock := inetDiagSocket{
+		LocalAddress:  diagAddr(msg.Family, msg.ID.Src),
+		LocalPort:     binary.BigEndian.Uint16(msg.ID.SPort[:]),
+		RemoteAddress: diagAddr(msg.Family, msg.ID.Dst),
+		RemotePort:    binary.BigEndian.Uint16(msg.ID.DPort[:]),
+		State:         msg.State,
+		RQueue:        msg.RQueue,
+		WQueue:        msg.WQueue,
+		UID:           msg.UID,
+		Inode:         msg.Inode,
+	}
+
+	// Walk rtattr-encoded extensions
+	attrs := data[nlmAlign(msgLen):]
+	for len(attrs) >= unix.SizeofRtAttr {
+		attrLen := int(binary.LittleEndian.Uint16(attrs[0:2]))
+		attrType := binary.LittleEndian.Uint16(attrs[2:4])
+		if attrLen < unix.SizeofRtAttr || attrLen > len(attrs) {
+			break
+		}
+
+		if attrType == INET_DIAG_INFO {
+			// Older kernels return a shorter tcp_info; missing fields stay zero
+			var info unix.TCPInfo
+			payload := attrs[unix.SizeofRtAttr:attrLen]
+			size := int(unsafe.Sizeof(info))
+			if len(payload) < size {
+				size = len(payload)
+			}
+			copy((*[unsafe.Sizeof(unix.TCPInfo{})]byte)(unsafe.Pointer(&info))[:size], payload[:size])
+			sock.Info = &info
+		}
+
+		next := nlmAlign(attrLen)
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	return sock, true
+}
+
+// diagAddr formats an inet_diag address for the given family
+func diagAddr(family uint8, addr [16]byte) string {
+	if family == unix.AF_INET {
+		return net.IP(addr[:4]).String()
+	}
+	return net.IP(addr[:]).String()
+}
+
+// nlmAlign rounds a length up to the 4-byte netlink alignment
+func nlmAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
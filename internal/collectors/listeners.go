@@ -0,0 +1,182 @@
+// Package collectors provides listen socket backlog and SYN queue monitoring
+package collectors
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Accept queue threshold as a share of the backlog
+const listenQueueWarningPercent = 80.0
+
+// listenerKey identifies a listener. SO_REUSEPORT lets several processes
+// listen on the same address and port, so the owner is part of the key.
+type listenerKey struct {
+	address string
+	port    uint16
+	pid     uint32
+}
+
+// listenQueue is the accept queue of a listening socket
+type listenQueue struct {
+	accept  uint32
+	backlog uint32
+}
+
+// listenStats holds system-wide listen counters, where the platform reports them
+type listenStats struct {
+	overflows     uint64
+	drops         uint64
+	maxSynBacklog uint32
+}
+
+// listenerTracker builds per-listener metrics and rates the system-wide overflow counters
+type listenerTracker struct {
+	mu            sync.Mutex
+	lastOverflows uint64
+	lastDrops     uint64
+	lastUpdate    time.Time
+}
+
+// newListenerTracker creates a new listener tracker
+func newListenerTracker() *listenerTracker {
+	return &listenerTracker{}
+}
+
+// Update builds listener metrics from the connection table. queues and stats
+// are nil on platforms that do not report accept queues or overflow counters.
+func (t *listenerTracker) Update(connections []models.TCPConnection, queues map[listenerKey]listenQueue, stats *listenStats, now time.Time) *models.ListenerMetrics {
+	metrics := &models.ListenerMetrics{
+		Listeners:           []models.ListenerInfo{},
+		QueueStatsAvailable: queues != nil,
+		Status:              "ok",
+	}
+
+	listeners := make(map[listenerKey]*models.ListenerInfo)
+	for _, conn := range connections {
+		if conn.State != "LISTEN" {
+			continue
+		}
+		key := listenerKey{conn.LocalAddress, conn.LocalPort, conn.PID}
+		if _, ok := listeners[key]; ok {
+			continue // another SO_REUSEPORT socket of the process, queues already added up
+		}
+		info := &models.ListenerInfo{
+			LocalAddress: conn.LocalAddress,
+			LocalPort:    conn.LocalPort,
+			PID:          conn.PID,
+		}
+		if q, ok := queues[key]; ok {
+			info.AcceptQueue = q.accept
+			info.Backlog = q.backlog
+			if q.backlog > 0 {
+				info.QueuePercent = float64(q.accept) / float64(q.backlog) * 100
+			}
+		}
+		listeners[key] = info
+	}
+
+	// Half-open connections count against the listener they arrived on
+	for _, conn := range connections {
+		if conn.State != "SYN_RCVD" {
+			continue
+		}
+		if info := findListener(listeners, conn.LocalAddress, conn.LocalPort, conn.PID); info != nil {
+			info.SynReceived++
+		}
+	}
+
+	for _, info := range listeners {
+		metrics.Listeners = append(metrics.Listeners, *info)
+	}
+	sort.Slice(metrics.Listeners, func(i, j int) bool {
+		x, y := metrics.Listeners[i], metrics.Listeners[j]
+		if x.QueuePercent != y.QueuePercent {
+			return x.QueuePercent > y.QueuePercent
+		}
+		if x.SynReceived != y.SynReceived {
+			return x.SynReceived > y.SynReceived
+		}
+		if x.LocalPort != y.LocalPort {
+			return x.LocalPort < y.LocalPort
+		}
+		return x.PID < y.PID
+	})
+
+	if stats != nil {
+		metrics.MaxSynBacklog = stats.maxSynBacklog
+		metrics.ListenOverflows = stats.overflows
+		metrics.ListenDrops = stats.drops
+		t.rate(metrics, stats, now)
+	}
+
+	classifyListeners(metrics)
+	return metrics
+}
+
+// rate converts the cumulative overflow and drop counters to per-second rates
+func (t *listenerTracker) rate(metrics *models.ListenerMetrics, stats *listenStats, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Counters going backwards (reset or namespace change) restart the baseline
+	if !t.lastUpdate.IsZero() && stats.overflows >= t.lastOverflows && stats.drops >= t.lastDrops {
+		if elapsed := now.Sub(t.lastUpdate).Seconds(); elapsed > 0 {
+			metrics.OverflowsPerSec = float64(stats.overflows-t.lastOverflows) / elapsed
+			metrics.DropsPerSec = float64(stats.drops-t.lastDrops) / elapsed
+		}
+	}
+
+	t.lastOverflows = stats.overflows
+	t.lastDrops = stats.drops
+	t.lastUpdate = now
+}
+
+// classifyListeners sets the status from overflow rates and queue fill
+func classifyListeners(metrics *models.ListenerMetrics) {
+	if metrics.OverflowsPerSec > 0 || metrics.DropsPerSec > 0 {
+		metrics.Status = "critical"
+		metrics.Message = fmt.Sprintf("Listen queue overflowing: %.1f overflows/sec, %.1f drops/sec",
+			metrics.OverflowsPerSec, metrics.DropsPerSec)
+		return
+	}
+
+	// Listeners are sorted fullest first
+	if len(metrics.Listeners) > 0 && metrics.Listeners[0].QueuePercent >= listenQueueWarningPercent {
+		top := metrics.Listeners[0]
+		metrics.Status = "warning"
+		metrics.Message = fmt.Sprintf("Accept queue on %s at %d/%d (PID %d)",
+			net.JoinHostPort(top.LocalAddress, fmt.Sprint(top.LocalPort)), top.AcceptQueue, top.Backlog, top.PID)
+	}
+}
+
+// findListener returns the listener for a local endpoint, falling back to a
+// wildcard listener on the same port (same address family first). A
+// listener of pid is preferred; half-open connections often have no owner,
+// and then the SO_REUSEPORT listener with the lowest PID is taken.
+func findListener(listeners map[listenerKey]*models.ListenerInfo, address string, port uint16, pid uint32) *models.ListenerInfo {
+	addresses := []string{address, "::", "0.0.0.0"}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() != nil {
+		addresses = []string{address, "0.0.0.0", "::"}
+	}
+	for _, addr := range addresses {
+		if info, ok := listeners[listenerKey{addr, port, pid}]; ok {
+			return info
+		}
+		var found *models.ListenerInfo
+		for key, info := range listeners {
+			if key.address == addr && key.port == port && (found == nil || info.PID < found.PID) {
+				found = info
+			}
+		}
+		if found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ package collectors
 
 import (
 	"context"
+	"fmt"
 	"unsafe"
 
 	"loadrunner-diagnosis/internal/models"
@@ -51,16 +52,39 @@ var (
 	procGetPerformanceInfo   = modpsapi.NewProc("GetPerformanceInfo")
 )
 
+// Paging PDH counters
+const (
+	pdhPageFaults  = `\Memory\Page Faults/sec`
+	pdhPageReads   = `\Memory\Page Reads/sec`
+	pdhPagesInput  = `\Memory\Pages Input/sec`
+	pdhPagesOutput = `\Memory\Pages Output/sec`
+)
+
 // MemoryCollector collects memory metrics
 type MemoryCollector struct {
-	lastPageFaults uint64
+	counters *pdhQuery // paging rates, nil when PDH is unavailable
 }
 
 // NewMemoryCollector creates a new memory collector
 func NewMemoryCollector() (*MemoryCollector, error) {
-	return &MemoryCollector{}, nil
+	c := &MemoryCollector{}
+	c.counters, _ = newPdhQuery([]string{pdhPageFaults, pdhPageReads, pdhPagesInput, pdhPagesOutput})
+	return c, nil
+}
+
+// SetCgroupTarget selects the cgroup whose memory is reported. Cgroups only
+// exist on Linux, so selecting one is an error here.
+func (c *MemoryCollector) SetCgroupTarget(path string, pid int) error {
+	if path != "" || pid > 0 {
+		return fmt.Errorf("cgroup memory accounting is only available on Linux")
+	}
+	return nil
 }
 
+// ResetCommitPeak does nothing: Windows reports the commit peak since boot
+// and it cannot be reset
+func (c *MemoryCollector) ResetCommitPeak() {}
+
 // Name returns the collector name
 func (c *MemoryCollector) Name() string {
 	return "memory"
@@ -99,11 +123,30 @@ func (c *MemoryCollector) Collect(ctx context.Context) (*models.MemoryMetrics, e
 		metrics.CommittedBytes = perfInfo.CommitTotal * pageSize
 		metrics.CommitLimit = perfInfo.CommitLimit * pageSize
 		metrics.CacheBytes = perfInfo.SystemCache * pageSize
+		metrics.CommitPeak = perfInfo.CommitPeak * pageSize
+		metrics.KernelPagedBytes = perfInfo.KernelPaged * pageSize
+		metrics.KernelNonpagedBytes = perfInfo.KernelNonpaged * pageSize
 		
 		if metrics.CommitLimit > 0 {
 			metrics.CommitPercent = float64(metrics.CommittedBytes) / float64(metrics.CommitLimit) * 100
 		}
 	}
 
+	// Paging rates; PDH computes them between successive collections
+	if c.counters != nil && c.counters.Collect() == nil {
+		if v, ok := c.counters.Value(pdhPageFaults); ok {
+			metrics.PageFaultsPerSec = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhPageReads); ok {
+			metrics.MajorFaultsPerSec = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhPagesInput); ok {
+			metrics.PagesInputPerSec = uint64(v)
+		}
+		if v, ok := c.counters.Value(pdhPagesOutput); ok {
+			metrics.PagesOutputPerSec = uint64(v)
+		}
+	}
+
 	return metrics, nil
 }
@@ -0,0 +1,228 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides memory metrics collection
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// cgroupMemoryUnlimited is the smallest v1 limit treated as "no limit";
+// unlimited v1 cgroups report PAGE_COUNTER_MAX rounded to the page size
+const cgroupMemoryUnlimited = 1 << 62
+
+// MemoryCollector collects memory metrics from /proc/meminfo and /proc/vmstat
+type MemoryCollector struct {
+	mu          sync.Mutex
+	lastVMStat  map[string]uint64
+	lastCollect time.Time
+	commitPeak  uint64 // highest Committed_AS seen since monitoring started
+
+	// Cgroup to report; by default our own cgroup when running in a container
+	cgroupPath   string
+	cgroupPID    int
+	ownCgroup    bool
+	lastOOMPath  string
+	lastOOMKills uint64
+}
+
+// NewMemoryCollector creates a new memory collector
+func NewMemoryCollector() (*MemoryCollector, error) {
+	vmstat, err := readProcKeyValues(filepath.Join(procRoot, "vmstat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/vmstat: %w", err)
+	}
+	return &MemoryCollector{
+		lastVMStat:  vmstat,
+		lastCollect: time.Now(),
+		ownCgroup:   detectContainer() != "",
+	}, nil
+}
+
+// ResetCommitPeak starts tracking the commit peak anew for a monitoring run
+func (c *MemoryCollector) ResetCommitPeak() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commitPeak = 0
+}
+
+// SetCgroupTarget selects the cgroup whose memory is reported, either by
+// cgroup path or by the PID of a process in it. With neither set, our own
+// cgroup is reported when running in a container.
+func (c *MemoryCollector) SetCgroupTarget(path string, pid int) error {
+	if path != "" {
+		if _, err := cgroupFromPath(path); err != nil {
+			return err
+		}
+	} else if pid > 0 {
+		if _, err := findCgroup(pid); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cgroupPath = path
+	c.cgroupPID = pid
+	return nil
+}
+
+// Name returns the collector name
+func (c *MemoryCollector) Name() string {
+	return "memory"
+}
+
+// Collect gathers memory metrics
+func (c *MemoryCollector) Collect(ctx context.Context) (*models.MemoryMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := &models.MemoryMetrics{}
+
+	meminfo, err := readProcKeyValues(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read /proc/meminfo: %w", err)
+	}
+
+	metrics.TotalPhysical = meminfo["MemTotal"]
+	metrics.AvailablePhysical = meminfo["MemAvailable"]
+	if metrics.AvailablePhysical > metrics.TotalPhysical {
+		metrics.AvailablePhysical = metrics.TotalPhysical
+	}
+	metrics.UsedPhysical = metrics.TotalPhysical - metrics.AvailablePhysical
+	if metrics.TotalPhysical > 0 {
+		metrics.UsedPercent = float64(metrics.UsedPhysical) / float64(metrics.TotalPhysical) * 100
+	}
+
+	// Swap plays the role of the page file
+	metrics.TotalPageFile = meminfo["SwapTotal"]
+	metrics.AvailablePageFile = meminfo["SwapFree"]
+	if metrics.AvailablePageFile <= metrics.TotalPageFile {
+		metrics.UsedPageFile = metrics.TotalPageFile - metrics.AvailablePageFile
+	}
+
+	metrics.CacheBytes = meminfo["Cached"] + meminfo["Buffers"]
+	metrics.SlabReclaimableBytes = meminfo["SReclaimable"]
+	metrics.SlabUnreclaimableBytes = meminfo["SUnreclaim"]
+
+	metrics.CommittedBytes = meminfo["Committed_AS"]
+	metrics.CommitLimit = meminfo["CommitLimit"]
+	if metrics.CommitLimit > 0 {
+		metrics.CommitPercent = float64(metrics.CommittedBytes) / float64(metrics.CommitLimit) * 100
+	}
+	if metrics.CommittedBytes > c.commitPeak {
+		c.commitPeak = metrics.CommittedBytes
+	}
+	metrics.CommitPeak = c.commitPeak
+
+	// Paging rates from cumulative /proc/vmstat counters
+	vmstat, err := readProcKeyValues(filepath.Join(procRoot, "vmstat"))
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read /proc/vmstat: %w", err)
+	}
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+
+	metrics.PageFaultsPerSec = uint64(counterRate(vmstat["pgfault"], c.lastVMStat["pgfault"], elapsed))
+	metrics.MajorFaultsPerSec = uint64(counterRate(vmstat["pgmajfault"], c.lastVMStat["pgmajfault"], elapsed))
+	metrics.PagesInputPerSec = uint64(counterRate(vmstat["pswpin"], c.lastVMStat["pswpin"], elapsed))
+	metrics.PagesOutputPerSec = uint64(counterRate(vmstat["pswpout"], c.lastVMStat["pswpout"], elapsed))
+
+	c.lastVMStat = vmstat
+	c.lastCollect = now
+
+	// A missing cgroup (e.g. the chosen process exited) leaves the section empty
+	if cg, pid := c.targetCgroup(); cg != nil {
+		metrics.Cgroup = c.collectCgroup(cg, pid)
+	}
+
+	return metrics, nil
+}
+
+// targetCgroup resolves the configured cgroup. A PID is resolved on every
+// collection since processes can be moved between cgroups.
+func (c *MemoryCollector) targetCgroup() (*cgroup, int) {
+	switch {
+	case c.cgroupPath != "":
+		if cg, err := cgroupFromPath(c.cgroupPath); err == nil {
+			return cg, 0
+		}
+	case c.cgroupPID > 0:
+		if cg, err := findCgroup(c.cgroupPID); err == nil {
+			return cg, c.cgroupPID
+		}
+	case c.ownCgroup:
+		if cg, err := findCgroup(0); err == nil {
+			return cg, 0
+		}
+	}
+	return nil, 0
+}
+
+// collectCgroup reads the memory controller of a cgroup
+func (c *MemoryCollector) collectCgroup(cg *cgroup, pid int) *models.CgroupMemoryMetrics {
+	ctrl, ok := cg.controller("memory")
+	if !ok {
+		return nil
+	}
+
+	metrics := &models.CgroupMemoryMetrics{Path: ctrl.path, Version: ctrl.version, PID: pid}
+	stat, err := readCgroupStat(ctrl.dir, "memory.stat")
+	if err != nil {
+		return nil
+	}
+
+	var inactiveFile uint64
+	if ctrl.version == 2 {
+		metrics.LimitBytes, _ = readCgroupUint(ctrl.dir, "memory.max") // "max" = unlimited
+		metrics.UsageBytes, _ = readCgroupUint(ctrl.dir, "memory.current")
+		metrics.SwapBytes, _ = readCgroupUint(ctrl.dir, "memory.swap.current")
+		metrics.CacheBytes = stat["file"]
+		inactiveFile = stat["inactive_file"]
+		if events, err := readCgroupStat(ctrl.dir, "memory.events"); err == nil {
+			metrics.OOMKills = events["oom_kill"]
+		}
+	} else {
+		metrics.LimitBytes, _ = readCgroupUint(ctrl.dir, "memory.limit_in_bytes")
+		if metrics.LimitBytes >= cgroupMemoryUnlimited {
+			metrics.LimitBytes = 0
+		}
+		metrics.UsageBytes, _ = readCgroupUint(ctrl.dir, "memory.usage_in_bytes")
+		metrics.SwapBytes = stat["total_swap"]
+		metrics.CacheBytes = stat["total_cache"]
+		inactiveFile = stat["total_inactive_file"]
+		// oom_kill is reported by kernels 4.13 and later
+		if oom, err := readCgroupStat(ctrl.dir, "memory.oom_control"); err == nil {
+			metrics.OOMKills = oom["oom_kill"]
+		}
+	}
+
+	// Working set as the kernel sees it for reclaim: inactive file pages are freed first
+	metrics.WorkingSetBytes = metrics.UsageBytes
+	if inactiveFile < metrics.WorkingSetBytes {
+		metrics.WorkingSetBytes -= inactiveFile
+	}
+	if metrics.LimitBytes > 0 {
+		metrics.UsagePercentOfLimit = float64(metrics.WorkingSetBytes) / float64(metrics.LimitBytes) * 100
+	}
+
+	// OOM kills since the previous sample of the same cgroup
+	if c.lastOOMPath == ctrl.path && metrics.OOMKills >= c.lastOOMKills {
+		metrics.OOMKillsInInterval = metrics.OOMKills - c.lastOOMKills
+	}
+	c.lastOOMPath = ctrl.path
+	c.lastOOMKills = metrics.OOMKills
+
+	if pressure, err := readPressure(filepath.Join(ctrl.dir, "memory.pressure")); err == nil {
+		metrics.Pressure = pressure
+	}
+
+	return metrics
+}
@@ -0,0 +1,196 @@
+// Package collectors provides network adapter inventory and change tracking
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// maxAdapterChanges bounds the change log of a session
+const maxAdapterChanges = 1000
+
+// adapterInventory keeps the adapter configuration at session start and logs
+// every change seen since
+type adapterInventory struct {
+	mu         sync.Mutex
+	baselineAt time.Time
+	baseline   []models.NetworkAdapter
+	current    map[string]models.NetworkAdapter
+	changes    []models.AdapterChange
+}
+
+// newAdapterInventory creates an inventory; the next update becomes the baseline
+func newAdapterInventory() *adapterInventory {
+	return &adapterInventory{}
+}
+
+// Reset starts a new session: the next update becomes the baseline
+func (inv *adapterInventory) Reset() {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.baseline = nil
+	inv.baselineAt = time.Time{}
+	inv.current = nil
+	inv.changes = nil
+}
+
+// Update records the adapters seen now and returns what changed since the
+// previous update
+func (inv *adapterInventory) Update(adapters []models.NetworkAdapter, now time.Time) []models.AdapterChange {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	current := make(map[string]models.NetworkAdapter, len(adapters))
+	for _, a := range adapters {
+		current[a.Name] = a
+	}
+
+	if inv.current == nil {
+		inv.baselineAt = now
+		inv.baseline = sortedAdapters(current)
+		inv.current = current
+		return nil
+	}
+
+	var changes []models.AdapterChange
+	add := func(adapter, field, before, after string) {
+		changes = append(changes, models.AdapterChange{Timestamp: now, Adapter: adapter, Field: field, Old: before, New: after})
+	}
+
+	for name, a := range current {
+		prev, ok := inv.current[name]
+		if !ok {
+			add(name, "added", "", describeAdapter(a))
+			continue
+		}
+		if prev.IsUp != a.IsUp {
+			add(name, "link", linkState(prev.IsUp), linkState(a.IsUp))
+		}
+		if prev.Speed != a.Speed {
+			add(name, "speed", formatSpeed(prev.Speed), formatSpeed(a.Speed))
+		}
+		if prev.Duplex != a.Duplex {
+			add(name, "duplex", prev.Duplex, a.Duplex)
+		}
+		if prev.MTU != a.MTU {
+			add(name, "mtu", strconv.Itoa(int(prev.MTU)), strconv.Itoa(int(a.MTU)))
+		}
+		if prev.MAC != a.MAC {
+			add(name, "mac", prev.MAC, a.MAC)
+		}
+		if before, after := strings.Join(prev.Addresses, ", "), strings.Join(a.Addresses, ", "); before != after {
+			add(name, "addresses", before, after)
+		}
+		if before, after := prev.Driver+" "+prev.DriverVersion, a.Driver+" "+a.DriverVersion; before != after {
+			add(name, "driver", strings.TrimSpace(before), strings.TrimSpace(after))
+		}
+		if prev.RxQueues != a.RxQueues || prev.TxQueues != a.TxQueues {
+			add(name, "queues", fmt.Sprintf("rx %d tx %d", prev.RxQueues, prev.TxQueues), fmt.Sprintf("rx %d tx %d", a.RxQueues, a.TxQueues))
+		}
+		for _, offload := range offloadNames(prev.Offloads, a.Offloads) {
+			before, wasKnown := prev.Offloads[offload]
+			after, isKnown := a.Offloads[offload]
+			if wasKnown && isKnown && before != after {
+				add(name, "offload:"+offload, onOff(before), onOff(after))
+			}
+		}
+	}
+	for name, prev := range inv.current {
+		if _, ok := current[name]; !ok {
+			add(name, "removed", describeAdapter(prev), "")
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Adapter != changes[j].Adapter {
+			return changes[i].Adapter < changes[j].Adapter
+		}
+		return changes[i].Field < changes[j].Field
+	})
+
+	inv.current = current
+	inv.changes = append(inv.changes, changes...)
+	if len(inv.changes) > maxAdapterChanges {
+		inv.changes = inv.changes[len(inv.changes)-maxAdapterChanges:]
+	}
+	return changes
+}
+
+// Snapshot returns the baseline, the current adapters and the change log
+func (inv *adapterInventory) Snapshot() *models.NetworkInventory {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return &models.NetworkInventory{
+		BaselineAt: inv.baselineAt,
+		Baseline:   append([]models.NetworkAdapter{}, inv.baseline...),
+		Current:    sortedAdapters(inv.current),
+		Changes:    append([]models.AdapterChange{}, inv.changes...),
+	}
+}
+
+// sortedAdapters returns the adapters ordered by name
+func sortedAdapters(adapters map[string]models.NetworkAdapter) []models.NetworkAdapter {
+	list := make([]models.NetworkAdapter, 0, len(adapters))
+	for _, a := range adapters {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// offloadNames returns the offload names of both adapters, sorted
+func offloadNames(a, b map[string]bool) []string {
+	seen := make(map[string]bool)
+	for name := range a {
+		seen[name] = true
+	}
+	for name := range b {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// describeAdapter summarizes an adapter for added/removed changes
+func describeAdapter(a models.NetworkAdapter) string {
+	return fmt.Sprintf("%s %s, MTU %d, %s", linkState(a.IsUp), formatSpeed(a.Speed), a.MTU, strings.Join(a.Addresses, " "))
+}
+
+// linkState names a link state
+func linkState(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
+// onOff names a feature state
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// formatSpeed formats a link speed in bits per second
+func formatSpeed(bps uint64) string {
+	switch {
+	case bps == 0:
+		return "unknown"
+	case bps >= 1000000000:
+		return strconv.FormatFloat(float64(bps)/1e9, 'f', -1, 64) + " Gbit/s"
+	default:
+		return strconv.FormatFloat(float64(bps)/1e6, 'f', -1, 64) + " Mbit/s"
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides NetPath probe functionality similar to SolarWinds
+package collectors
+
+import (
+	"fmt"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// NetPathCollector manages network path probes. NetPath is not ported to
+// Linux yet; probes cannot be started and none exist.
+type NetPathCollector struct{}
+
+// NewNetPathCollector creates a new NetPath collector
+func NewNetPathCollector() *NetPathCollector {
+	return &NetPathCollector{}
+}
+
+// StartProbe returns ErrNotAvailable
+func (n *NetPathCollector) StartProbe(target string, config models.NetPathConfig) (*models.NetPathProbe, error) {
+	return nil, ErrNotAvailable
+}
+
+// StopProbe reports that no probe exists for target
+func (n *NetPathCollector) StopProbe(target string) error {
+	return fmt.Errorf("probe not found for target: %s", target)
+}
+
+// GetProbe reports that no probe exists for target
+func (n *NetPathCollector) GetProbe(target string) (*models.NetPathProbe, error) {
+	return nil, fmt.Errorf("probe not found for target: %s", target)
+}
+
+// GetAllProbes returns no probes
+func (n *NetPathCollector) GetAllProbes() []*models.NetPathProbe {
+	return []*models.NetPathProbe{}
+}
+
+// DeleteProbe reports that no probe exists for target
+func (n *NetPathCollector) DeleteProbe(target string) error {
+	return fmt.Errorf("probe not found for target: %s", target)
+}
+
+// ProbeOnce returns ErrNotAvailable
+func (n *NetPathCollector) ProbeOnce(target string, config models.NetPathConfig) (*models.NetPathResult, error) {
+	return nil, ErrNotAvailable
+}
@@ -0,0 +1,178 @@
+// Package collectors provides wrap-safe network interface counter rates
+package collectors
+
+import (
+	"sort"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// interfaceCounters is one sample of an interface's cumulative counters
+type interfaceCounters struct {
+	inOctets    uint64
+	outOctets   uint64
+	inPkts      uint64
+	outPkts     uint64
+	inErrors    uint64
+	outErrors   uint64
+	inDiscards  uint64
+	outDiscards uint64
+	width       uint              // counter width in bits: 32 or 64
+	reasons     map[string]uint64 // detailed drop and error counters, where the platform has them
+}
+
+// Drop reason categories
+const (
+	dropCategoryBuffer   = "buffer"   // NIC or kernel ran out of buffers
+	dropCategoryLink     = "link"     // damaged frames or physical link problems
+	dropCategoryProtocol = "protocol" // frames nobody wanted
+)
+
+// dropReasons describes the detailed drop and error counters
+var dropReasons = map[string]struct {
+	direction string
+	category  string
+}{
+	// Linux /sys/class/net/<if>/statistics
+	"rx_missed_errors":    {"in", dropCategoryBuffer},   // RX ring full, host did not drain it in time
+	"rx_fifo_errors":      {"in", dropCategoryBuffer},   // NIC FIFO overrun
+	"rx_over_errors":      {"in", dropCategoryBuffer},   // RX ring buffer overflow
+	"tx_fifo_errors":      {"out", dropCategoryBuffer},  // NIC FIFO underrun
+	"rx_crc_errors":       {"in", dropCategoryLink},     // bad checksum: cabling, optics, duplex mismatch
+	"rx_frame_errors":     {"in", dropCategoryLink},     // misaligned frames
+	"rx_length_errors":    {"in", dropCategoryLink},     // runts and giants
+	"tx_carrier_errors":   {"out", dropCategoryLink},    // carrier lost while sending
+	"tx_aborted_errors":   {"out", dropCategoryLink},    // excessive collisions
+	"tx_window_errors":    {"out", dropCategoryLink},    // late collisions
+	"tx_heartbeat_errors": {"out", dropCategoryLink},    // SQE test failures
+	"collisions":          {"out", dropCategoryLink},    // half duplex link
+	"rx_nohandler":        {"in", dropCategoryProtocol}, // no protocol handler (e.g. inactive bond slave)
+	// Windows MIB_IF_ROW2
+	"in_unknown_protos": {"in", dropCategoryProtocol},
+}
+
+// interfaceState is the previous sample of an interface and its counter history
+type interfaceState struct {
+	last   interfaceCounters
+	seen   bool
+	wraps  uint64
+	resets uint64
+}
+
+// counterDelta returns the increase of a cumulative counter since the previous
+// sample. A 32-bit counter that went backwards is assumed to have wrapped once;
+// a 64-bit counter cannot wrap in practice, so going backwards means it was
+// reset (driver reload, adapter reset) and the new value is the increase.
+func counterDelta(cur, prev uint64, width uint) (delta uint64, wrapped, reset bool) {
+	if cur >= prev {
+		return cur - prev, false, false
+	}
+	if width == 32 && prev <= 1<<32-1 {
+		return cur + (1 << 32) - prev, true, false
+	}
+	return cur, false, true
+}
+
+// update fills the per-second rates of iface from the counters of this sample
+func (s *interfaceState) update(iface *models.NetworkInterface, cur interfaceCounters, elapsed float64) {
+	iface.CounterBits = int(cur.width)
+
+	if s.seen && elapsed > 0 && cur.width == s.last.width {
+		var wrapped, reset bool
+		rate := func(now, prev uint64) float64 {
+			delta, w, r := counterDelta(now, prev, s.last.width)
+			wrapped = wrapped || w
+			reset = reset || r
+			return float64(delta) / elapsed
+		}
+
+		iface.BytesRecvPerSec = uint64(rate(cur.inOctets, s.last.inOctets))
+		iface.BytesSentPerSec = uint64(rate(cur.outOctets, s.last.outOctets))
+		iface.PacketsRecvPerSec = uint64(rate(cur.inPkts, s.last.inPkts))
+		iface.PacketsSentPerSec = uint64(rate(cur.outPkts, s.last.outPkts))
+		iface.InErrorsPerSec = rate(cur.inErrors, s.last.inErrors)
+		iface.OutErrorsPerSec = rate(cur.outErrors, s.last.outErrors)
+		iface.InDiscardsPerSec = rate(cur.inDiscards, s.last.inDiscards)
+		iface.OutDiscardsPerSec = rate(cur.outDiscards, s.last.outDiscards)
+
+		// Share of the packets that arrived or were handed to the NIC;
+		// errored and discarded packets are not in the packet counters
+		inPkts := rate(cur.inPkts, s.last.inPkts)
+		outPkts := rate(cur.outPkts, s.last.outPkts)
+		iface.InErrorPercent = lossPercent(iface.InErrorsPerSec, inPkts+iface.InErrorsPerSec+iface.InDiscardsPerSec)
+		iface.InDiscardPercent = lossPercent(iface.InDiscardsPerSec, inPkts+iface.InErrorsPerSec+iface.InDiscardsPerSec)
+		iface.OutErrorPercent = lossPercent(iface.OutErrorsPerSec, outPkts+iface.OutErrorsPerSec+iface.OutDiscardsPerSec)
+		iface.OutDiscardPercent = lossPercent(iface.OutDiscardsPerSec, outPkts+iface.OutErrorsPerSec+iface.OutDiscardsPerSec)
+
+		rates := make(map[string]float64)
+		for name, value := range cur.reasons {
+			if prev, ok := s.last.reasons[name]; ok {
+				rates[name] = rate(value, prev)
+			}
+		}
+		diagnoseDrops(iface, cur.reasons, rates)
+
+		if wrapped {
+			s.wraps++
+		}
+		if reset {
+			s.resets++
+		}
+	}
+
+	s.last = cur
+	s.seen = true
+	iface.CounterWraps = s.wraps
+	iface.CounterResets = s.resets
+}
+
+// lossPercent returns lost as a percentage of total
+func lossPercent(lost, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return lost / total * 100
+}
+
+// diagnoseDrops lists the detailed drop reasons of an interface and tells NIC
+// buffer exhaustion apart from link errors. Without detailed counters
+// (Windows), discards count as buffer exhaustion and errors as link errors.
+func diagnoseDrops(iface *models.NetworkInterface, totals map[string]uint64, rates map[string]float64) {
+	var buffer, link float64
+	detailed := false
+	for name, total := range totals {
+		info, ok := dropReasons[name]
+		if !ok {
+			continue
+		}
+		if info.category == dropCategoryLink {
+			detailed = true
+		}
+		if total == 0 {
+			continue
+		}
+		iface.DropReasons = append(iface.DropReasons, models.NetworkDropReason{
+			Reason:    name,
+			Direction: info.direction,
+			Category:  info.category,
+			Total:     total,
+			PerSec:    rates[name],
+		})
+		switch info.category {
+		case dropCategoryBuffer:
+			buffer += rates[name]
+		case dropCategoryLink:
+			link += rates[name]
+		}
+	}
+	sort.Slice(iface.DropReasons, func(i, j int) bool {
+		return iface.DropReasons[i].Reason < iface.DropReasons[j].Reason
+	})
+
+	buffer += iface.InDiscardsPerSec + iface.OutDiscardsPerSec
+	if !detailed {
+		link += iface.InErrorsPerSec + iface.OutErrorsPerSec
+	}
+	iface.BufferExhaustion = buffer > 0
+	iface.LinkErrors = link > 0
+}
@@ -7,6 +7,10 @@ package collectors
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -14,33 +18,44 @@ import (
 	"loadrunner-diagnosis/internal/models"
 
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 )
 
 var (
-	procGetIfEntry = modiphlpapi.NewProc("GetIfEntry")
+	procGetIfEntry  = modiphlpapi.NewProc("GetIfEntry")
+	procGetIfEntry2 = modiphlpapi.NewProc("GetIfEntry2")
 )
 
 // NetworkCollector collects network interface metrics
 type NetworkCollector struct {
 	mu          sync.RWMutex
 	lastCollect time.Time
-	lastStats   map[string]*interfaceStats
-}
-
-type interfaceStats struct {
-	inOctets  uint64
-	outOctets uint64
-	inPkts    uint64
-	outPkts   uint64
+	lastStats   map[string]*interfaceState
+	inventory   *adapterInventory
+	adapterSet  string            // sorted adapter GUIDs driverKeys was read for
+	driverKeys  map[string]string // adapter GUID to driver settings subkey
 }
 
 // NewNetworkCollector creates a new network collector
 func NewNetworkCollector() (*NetworkCollector, error) {
 	return &NetworkCollector{
-		lastStats: make(map[string]*interfaceStats),
+		lastStats: make(map[string]*interfaceState),
+		inventory: newAdapterInventory(),
 	}, nil
 }
 
+// ResetInventory starts a new session: the adapters seen by the next
+// collection become the baseline
+func (c *NetworkCollector) ResetInventory() {
+	c.inventory.Reset()
+}
+
+// Inventory returns the adapter configuration at session start, now, and the
+// changes in between
+func (c *NetworkCollector) Inventory() *models.NetworkInventory {
+	return c.inventory.Snapshot()
+}
+
 // Name returns the collector name
 func (c *NetworkCollector) Name() string {
 	return "network"
@@ -67,6 +82,7 @@ func (c *NetworkCollector) Collect(ctx context.Context) (*models.NetworkMetrics,
 		elapsed = 1
 	}
 
+	present := make(map[string]bool)
 	for _, adapter := range adapters {
 		iface := models.NetworkInterface{
 			Name:        adapter.Name,
@@ -90,19 +106,24 @@ func (c *NetworkCollector) Collect(ctx context.Context) (*models.NetworkMetrics,
 
 			// Calculate rates
 			key := adapter.Name
-			if last, ok := c.lastStats[key]; ok {
-				iface.BytesSentPerSec = uint64(float64(stats.OutOctets-last.outOctets) / elapsed)
-				iface.BytesRecvPerSec = uint64(float64(stats.InOctets-last.inOctets) / elapsed)
-				iface.PacketsSentPerSec = uint64(float64(stats.OutPkts-last.outPkts) / elapsed)
-				iface.PacketsRecvPerSec = uint64(float64(stats.InPkts-last.inPkts) / elapsed)
-			}
-
-			c.lastStats[key] = &interfaceStats{
-				inOctets:  stats.InOctets,
-				outOctets: stats.OutOctets,
-				inPkts:    stats.InPkts,
-				outPkts:   stats.OutPkts,
+			state, ok := c.lastStats[key]
+			if !ok {
+				state = &interfaceState{}
+				c.lastStats[key] = state
 			}
+			present[key] = true
+			state.update(&iface, interfaceCounters{
+				inOctets:    stats.InOctets,
+				outOctets:   stats.OutOctets,
+				inPkts:      stats.InPkts,
+				outPkts:     stats.OutPkts,
+				inErrors:    stats.InErrors,
+				outErrors:   stats.OutErrors,
+				inDiscards:  stats.InDiscards,
+				outDiscards: stats.OutDiscards,
+				width:       stats.Width,
+				reasons:     map[string]uint64{"in_unknown_protos": stats.InUnknownProtos},
+			}, elapsed)
 
 			// Calculate utilization
 			if adapter.Speed > 0 {
@@ -118,6 +139,18 @@ func (c *NetworkCollector) Collect(ctx context.Context) (*models.NetworkMetrics,
 		metrics.Interfaces = append(metrics.Interfaces, iface)
 	}
 
+	// Forget adapters that went away so a returning one starts fresh
+	for key := range c.lastStats {
+		if !present[key] {
+			delete(c.lastStats, key)
+		}
+	}
+
+	// Record link and configuration changes for the timeline
+	if adapters, err := c.listAdapters(); err == nil {
+		metrics.AdapterChanges = c.inventory.Update(adapters, now)
+	}
+
 	c.lastCollect = now
 	return metrics, nil
 }
@@ -142,6 +175,9 @@ type InterfaceStats struct {
 	InDiscards  uint64
 	OutDiscards uint64
 	OutQLen     uint64
+	Width       uint // counter width in bits
+
+	InUnknownProtos uint64
 }
 
 // getNetworkAdapters gets list of network adapters using GetAdaptersAddresses
@@ -215,8 +251,35 @@ type MIB_IFROW struct {
 	Descr           [256]byte
 }
 
-// getInterfaceStats gets statistics for a specific interface
+// getInterfaceStats gets statistics for a specific interface. GetIfEntry2
+// returns 64-bit counters; the legacy GetIfEntry counters are 32-bit and wrap
+// every 34 seconds at 1 Gbit/s, so it is only used where GetIfEntry2 is missing.
 func getInterfaceStats(index uint32) (*InterfaceStats, error) {
+	if procGetIfEntry2.Find() == nil {
+		var row windows.MibIfRow2
+		row.InterfaceIndex = index
+
+		ret, _, _ := procGetIfEntry2.Call(uintptr(unsafe.Pointer(&row)))
+		if ret != 0 {
+			return nil, fmt.Errorf("GetIfEntry2 failed: %d", ret)
+		}
+
+		return &InterfaceStats{
+			InOctets:    row.InOctets,
+			OutOctets:   row.OutOctets,
+			InPkts:      row.InUcastPkts + row.InNUcastPkts,
+			OutPkts:     row.OutUcastPkts + row.OutNUcastPkts,
+			InErrors:    row.InErrors,
+			OutErrors:   row.OutErrors,
+			InDiscards:  row.InDiscards,
+			OutDiscards: row.OutDiscards,
+			OutQLen:     row.OutQLen,
+			Width:       64,
+
+			InUnknownProtos: row.InUnknownProtos,
+		}, nil
+	}
+
 	var row MIB_IFROW
 	row.Index = index
 
@@ -235,5 +298,156 @@ func getInterfaceStats(index uint32) (*InterfaceStats, error) {
 		InDiscards:  uint64(row.InDiscards),
 		OutDiscards: uint64(row.OutDiscards),
 		OutQLen:     uint64(row.OutQLen),
+		Width:       32,
+
+		InUnknownProtos: uint64(row.InUnknownProtos),
 	}, nil
 }
+
+// networkClassKey is the device class of network adapters; each adapter's
+// driver settings live in a numbered subkey
+const networkClassKey = `SYSTEM\CurrentControlSet\Control\Class\{4d36e972-e325-11ce-bfc1-08002be10318}`
+
+// listAdapters returns the configuration of every network adapter, including
+// those that are down. Loopback is skipped.
+func (c *NetworkCollector) listAdapters() ([]models.NetworkAdapter, error) {
+	size := uint32(15000)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(
+			windows.AF_UNSPEC,
+			windows.GAA_FLAG_INCLUDE_PREFIX,
+			0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])),
+			&size,
+		)
+		if err == nil {
+			break
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return nil, err
+		}
+	}
+
+	// Finding the driver subkeys opens every subkey of the class, so it is
+	// only done again when adapters are added or removed
+	var guids []string
+	for addr := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); addr != nil; addr = addr.Next {
+		guids = append(guids, strings.ToLower(windows.BytePtrToString(addr.AdapterName)))
+	}
+	sort.Strings(guids)
+	if set := strings.Join(guids, ","); c.driverKeys == nil || set != c.adapterSet {
+		c.driverKeys = adapterDriverKeys()
+		c.adapterSet = set
+	}
+
+	var adapters []models.NetworkAdapter
+	for addr := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); addr != nil; addr = addr.Next {
+		if addr.IfType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			continue
+		}
+
+		adapter := models.NetworkAdapter{
+			Name:        windows.UTF16PtrToString(addr.FriendlyName),
+			Description: windows.UTF16PtrToString(addr.Description),
+			Index:       addr.IfIndex,
+			MAC:         net.HardwareAddr(addr.PhysicalAddress[:addr.PhysicalAddressLength]).String(),
+			MTU:         addr.Mtu,
+			Addresses:   []string{},
+			IsUp:        addr.OperStatus == windows.IfOperStatusUp,
+			Duplex:      "unknown",
+		}
+		if adapter.IsUp {
+			adapter.Speed = addr.TransmitLinkSpeed
+		}
+		for u := addr.FirstUnicastAddress; u != nil; u = u.Next {
+			if ip := u.Address.IP(); ip != nil {
+				adapter.Addresses = append(adapter.Addresses, fmt.Sprintf("%s/%d", ip, u.OnLinkPrefixLength))
+			}
+		}
+
+		if key, ok := c.driverKeys[strings.ToLower(windows.BytePtrToString(addr.AdapterName))]; ok {
+			readAdapterSettings(key, &adapter)
+		}
+
+		adapters = append(adapters, adapter)
+	}
+	return adapters, nil
+}
+
+// adapterDriverKeys maps adapter GUIDs to their driver settings subkey
+func adapterDriverKeys() map[string]string {
+	keys := make(map[string]string)
+
+	class, err := registry.OpenKey(registry.LOCAL_MACHINE, networkClassKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return keys
+	}
+	defer class.Close()
+
+	names, err := class.ReadSubKeyNames(-1)
+	if err != nil {
+		return keys
+	}
+	for _, name := range names {
+		key, err := registry.OpenKey(class, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		if id, _, err := key.GetStringValue("NetCfgInstanceId"); err == nil {
+			keys[strings.ToLower(id)] = networkClassKey + `\` + name
+		}
+		key.Close()
+	}
+	return keys
+}
+
+// readAdapterSettings reads the driver and the standardized advanced
+// properties (*SpeedDuplex, offloads, RSS) of an adapter
+func readAdapterSettings(path string, adapter *models.NetworkAdapter) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+
+	value := func(name string) (string, bool) {
+		v, _, err := key.GetStringValue(name)
+		return v, err == nil
+	}
+
+	adapter.Driver, _ = value("DriverDesc")
+	adapter.DriverVersion, _ = value("DriverVersion")
+
+	// 0 = auto-negotiation; 1, 3 and 5 are the half duplex speeds
+	if v, ok := value("*SpeedDuplex"); ok {
+		switch v {
+		case "0":
+			adapter.Duplex = "auto"
+		case "1", "3", "5":
+			adapter.Duplex = "half"
+		default:
+			adapter.Duplex = "full"
+		}
+	}
+
+	offloads := make(map[string]bool)
+	// Checksum offload: 0 = disabled, 1 = Tx, 2 = Rx, 3 = Rx and Tx
+	if v, ok := value("*TCPChecksumOffloadIPv4"); ok {
+		offloads["tx-checksum"] = v == "1" || v == "3"
+		offloads["rx-checksum"] = v == "2" || v == "3"
+	}
+	for name, keyword := range map[string]string{"tso": "*LsoV2IPv4", "lro": "*RscIPv4", "rss": "*RSS"} {
+		if v, ok := value(keyword); ok {
+			offloads[name] = v != "0"
+		}
+	}
+	if len(offloads) > 0 {
+		adapter.Offloads = offloads
+	}
+
+	if v, ok := value("*NumRssQueues"); ok && offloads["rss"] {
+		adapter.RxQueues, _ = strconv.Atoi(v)
+	}
+}
@@ -0,0 +1,342 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides network interface metrics collection
+package collectors
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"loadrunner-diagnosis/internal/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysNetRoot lists network interfaces and their attributes
+const sysNetRoot = "/sys/class/net"
+
+// ARPHRD_LOOPBACK is the interface type of loopback devices
+const ARPHRD_LOOPBACK = 772
+
+// NetworkCollector collects network interface metrics from /sys/class/net
+type NetworkCollector struct {
+	mu          sync.Mutex
+	lastCollect time.Time
+	lastStats   map[string]*interfaceState
+	inventory   *adapterInventory
+}
+
+// NewNetworkCollector creates a new network collector
+func NewNetworkCollector() (*NetworkCollector, error) {
+	return &NetworkCollector{
+		lastStats: make(map[string]*interfaceState),
+		inventory: newAdapterInventory(),
+	}, nil
+}
+
+// ResetInventory starts a new session: the adapters seen by the next
+// collection become the baseline
+func (c *NetworkCollector) ResetInventory() {
+	c.inventory.Reset()
+}
+
+// Inventory returns the adapter configuration at session start, now, and the
+// changes in between
+func (c *NetworkCollector) Inventory() *models.NetworkInventory {
+	return c.inventory.Snapshot()
+}
+
+// Name returns the collector name
+func (c *NetworkCollector) Name() string {
+	return "network"
+}
+
+// Collect gathers network interface metrics. The statistics files are
+// 64-bit on every kernel since 2.6.36, including 32-bit ones.
+func (c *NetworkCollector) Collect(ctx context.Context) (*models.NetworkMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := &models.NetworkMetrics{
+		Interfaces: []models.NetworkInterface{},
+	}
+
+	entries, err := os.ReadDir(sysNetRoot)
+	if err != nil {
+		return metrics, nil
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+	if elapsed < 0.1 {
+		elapsed = 1
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(sysNetRoot, name)
+
+		// Skip loopback and interfaces that are down; tunnels and some
+		// virtual devices report "unknown" while passing traffic
+		if t, err := readSysUint(filepath.Join(dir, "type")); err == nil && t == ARPHRD_LOOPBACK {
+			continue
+		}
+		state := readSysString(filepath.Join(dir, "operstate"))
+		if state != "up" && state != "unknown" {
+			continue
+		}
+
+		iface := models.NetworkInterface{
+			Name:        name,
+			Description: interfaceDescription(dir),
+			IsUp:        true,
+		}
+
+		// Speed is in Mbit/s; -1 (unknown) fails to parse as unsigned
+		if speed, err := readSysUint(filepath.Join(dir, "speed")); err == nil {
+			iface.Speed = speed * 1000000
+		}
+
+		stats := readInterfaceCounters(dir)
+		iface.BytesSent = stats.outOctets
+		iface.BytesReceived = stats.inOctets
+		iface.PacketsSent = stats.outPkts
+		iface.PacketsReceived = stats.inPkts
+		iface.InErrors = stats.inErrors
+		iface.OutErrors = stats.outErrors
+		iface.InDiscards = stats.inDiscards
+		iface.OutDiscards = stats.outDiscards
+
+		st, ok := c.lastStats[name]
+		if !ok {
+			st = &interfaceState{}
+			c.lastStats[name] = st
+		}
+		present[name] = true
+		st.update(&iface, stats, elapsed)
+
+		// Calculate utilization
+		if iface.Speed > 0 {
+			totalBytesPerSec := float64(iface.BytesSentPerSec + iface.BytesRecvPerSec)
+			maxBytesPerSec := float64(iface.Speed) / 8
+			iface.Utilization = (totalBytesPerSec / maxBytesPerSec) * 100
+			if iface.Utilization > 100 {
+				iface.Utilization = 100
+			}
+		}
+
+		metrics.Interfaces = append(metrics.Interfaces, iface)
+	}
+
+	// Forget interfaces that went away so a returning one starts fresh
+	for name := range c.lastStats {
+		if !present[name] {
+			delete(c.lastStats, name)
+		}
+	}
+
+	sort.Slice(metrics.Interfaces, func(i, j int) bool {
+		return metrics.Interfaces[i].Name < metrics.Interfaces[j].Name
+	})
+
+	// Record link and configuration changes for the timeline
+	if adapters, err := listAdapters(); err == nil {
+		metrics.AdapterChanges = c.inventory.Update(adapters, now)
+	}
+
+	c.lastCollect = now
+	return metrics, nil
+}
+
+// readInterfaceCounters reads the cumulative counters of an interface
+func readInterfaceCounters(dir string) interfaceCounters {
+	stat := func(name string) uint64 {
+		v, _ := readSysUint(filepath.Join(dir, "statistics", name))
+		return v
+	}
+	reasons := make(map[string]uint64)
+	for name := range dropReasons {
+		if v, err := readSysUint(filepath.Join(dir, "statistics", name)); err == nil {
+			reasons[name] = v
+		}
+	}
+	return interfaceCounters{
+		inOctets:    stat("rx_bytes"),
+		outOctets:   stat("tx_bytes"),
+		inPkts:      stat("rx_packets"),
+		outPkts:     stat("tx_packets"),
+		inErrors:    stat("rx_errors"),
+		outErrors:   stat("tx_errors"),
+		inDiscards:  stat("rx_dropped"),
+		outDiscards: stat("tx_dropped"),
+		width:       64,
+		reasons:     reasons,
+	}
+}
+
+// interfaceDescription returns the interface alias, or its driver name
+func interfaceDescription(dir string) string {
+	if alias := readSysString(filepath.Join(dir, "ifalias")); alias != "" {
+		return alias
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+		return filepath.Base(target)
+	}
+	return ""
+}
+
+// readSysString reads a single-line sysfs attribute ("" when missing)
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Legacy ethtool commands reporting one offload each
+var ethtoolOffloads = []struct {
+	name string
+	cmd  uint32
+}{
+	{"rx-checksum", unix.ETHTOOL_GRXCSUM},
+	{"tx-checksum", unix.ETHTOOL_GTXCSUM},
+	{"tso", unix.ETHTOOL_GTSO},
+	{"gso", unix.ETHTOOL_GGSO},
+	{"gro", unix.ETHTOOL_GGRO},
+}
+
+// ETH_FLAG_LRO is the large receive offload bit of ETHTOOL_GFLAGS
+const ETH_FLAG_LRO = 1 << 15
+
+// ethtoolValue is struct ethtool_value
+type ethtoolValue struct {
+	cmd  uint32
+	data uint32
+}
+
+// ethtoolIfreq is struct ifreq carrying a pointer to an ethtool command
+type ethtoolIfreq struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [24 - unsafe.Sizeof(unsafe.Pointer(nil))]byte
+}
+
+// ethtool runs an ethtool command whose result is written to data
+func ethtool(fd int, name string, data unsafe.Pointer) error {
+	var ifr ethtoolIfreq
+	copy(ifr.name[:unix.IFNAMSIZ-1], name)
+	ifr.data = data
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// listAdapters returns the configuration of every network adapter, including
+// those that are down. Loopback is skipped.
+func listAdapters() ([]models.NetworkAdapter, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	// Offloads and driver details need an ethtool socket; without one
+	// (restricted containers) they are left empty
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err == nil {
+		defer unix.Close(fd)
+	} else {
+		fd = -1
+	}
+
+	var adapters []models.NetworkAdapter
+	for _, ni := range ifaces {
+		if ni.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		dir := filepath.Join(sysNetRoot, ni.Name)
+
+		adapter := models.NetworkAdapter{
+			Name:        ni.Name,
+			Description: readSysString(filepath.Join(dir, "ifalias")),
+			Index:       uint32(ni.Index),
+			MAC:         ni.HardwareAddr.String(),
+			MTU:         uint32(ni.MTU),
+			Addresses:   []string{},
+			IsUp:        ni.Flags&net.FlagRunning != 0,
+			Duplex:      readSysString(filepath.Join(dir, "duplex")),
+			RxQueues:    countQueues(dir, "rx-"),
+			TxQueues:    countQueues(dir, "tx-"),
+		}
+		if adapter.Duplex == "" {
+			adapter.Duplex = "unknown"
+		}
+		if speed, err := readSysUint(filepath.Join(dir, "speed")); err == nil && adapter.IsUp {
+			adapter.Speed = speed * 1000000
+		}
+		if addrs, err := ni.Addrs(); err == nil {
+			for _, addr := range addrs {
+				adapter.Addresses = append(adapter.Addresses, addr.String())
+			}
+		}
+
+		if target, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+			adapter.Driver = filepath.Base(target)
+		}
+		if fd >= 0 {
+			if info, err := unix.IoctlGetEthtoolDrvinfo(fd, ni.Name); err == nil {
+				adapter.Driver = unix.ByteSliceToString(info.Driver[:])
+				adapter.DriverVersion = unix.ByteSliceToString(info.Version[:])
+			}
+			adapter.Offloads = readOffloads(fd, ni.Name)
+		}
+
+		adapters = append(adapters, adapter)
+	}
+	return adapters, nil
+}
+
+// readOffloads returns the offloads the driver reports
+func readOffloads(fd int, name string) map[string]bool {
+	offloads := make(map[string]bool)
+	for _, o := range ethtoolOffloads {
+		value := ethtoolValue{cmd: o.cmd}
+		if ethtool(fd, name, unsafe.Pointer(&value)) == nil {
+			offloads[o.name] = value.data != 0
+		}
+	}
+	value := ethtoolValue{cmd: unix.ETHTOOL_GFLAGS}
+	if ethtool(fd, name, unsafe.Pointer(&value)) == nil {
+		offloads["lro"] = value.data&ETH_FLAG_LRO != 0
+	}
+	if len(offloads) == 0 {
+		return nil
+	}
+	return offloads
+}
+
+// countQueues counts the rx-N or tx-N queue directories of an interface
+func countQueues(dir, prefix string) int {
+	entries, err := os.ReadDir(filepath.Join(dir, "queues"))
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+// Package collectors provides Performance Data Helper (PDH) counter queries
+package collectors
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modpdh                          = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQueryW               = modpdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW       = modpdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = modpdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue = modpdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhCloseQuery               = modpdh.NewProc("PdhCloseQuery")
+)
+
+// PDH format flags
+const (
+	PDH_FMT_DOUBLE   = 0x00000200
+	PDH_FMT_NOCAP100 = 0x00008000
+)
+
+// PDH_FMT_COUNTERVALUE structure holding a double
+type PDH_FMT_COUNTERVALUE_DOUBLE struct {
+	CStatus     uint32
+	_           uint32
+	DoubleValue float64
+}
+
+// pdhQuery is a PDH query over a fixed set of English counter paths
+type pdhQuery struct {
+	mu       sync.Mutex
+	handle   uintptr
+	counters map[string]uintptr
+}
+
+// newPdhQuery opens a query and adds the given counters. Counters that do not
+// exist on this system are skipped. Rate counters need two samples, so the
+// query is collected once up front.
+func newPdhQuery(paths []string) (*pdhQuery, error) {
+	if err := modpdh.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load pdh.dll: %w", err)
+	}
+
+	q := &pdhQuery{counters: make(map[string]uintptr)}
+	ret, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&q.handle)))
+	if ret != 0 {
+		return nil, fmt.Errorf("PdhOpenQuery failed: 0x%x", ret)
+	}
+
+	for _, path := range paths {
+		pathPtr, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			continue
+		}
+		var counter uintptr
+		ret, _, _ := procPdhAddEnglishCounterW.Call(q.handle, uintptr(unsafe.Pointer(pathPtr)), 0, uintptr(unsafe.Pointer(&counter)))
+		if ret == 0 {
+			q.counters[path] = counter
+		}
+	}
+
+	if len(q.counters) == 0 {
+		q.Close()
+		return nil, fmt.Errorf("no PDH counters available")
+	}
+
+	q.Collect()
+	return q, nil
+}
+
+// Collect samples all counters of the query
+func (q *pdhQuery) Collect() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ret, _, _ := procPdhCollectQueryData.Call(q.handle)
+	if ret != 0 {
+		return fmt.Errorf("PdhCollectQueryData failed: 0x%x", ret)
+	}
+	return nil
+}
+
+// Value returns the formatted value of a counter from the last collection
+func (q *pdhQuery) Value(path string) (float64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counter, ok := q.counters[path]
+	if !ok {
+		return 0, false
+	}
+
+	var value PDH_FMT_COUNTERVALUE_DOUBLE
+	ret, _, _ := procPdhGetFormattedCounterValue.Call(counter, PDH_FMT_DOUBLE|PDH_FMT_NOCAP100, 0, uintptr(unsafe.Pointer(&value)))
+	if ret != 0 || value.CStatus != 0 {
+		return 0, false
+	}
+	return value.DoubleValue, true
+}
+
+// Close closes the query
+func (q *pdhQuery) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.handle != 0 {
+		procPdhCloseQuery.Call(q.handle)
+		q.handle = 0
+	}
+}
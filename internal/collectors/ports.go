@@ -0,0 +1,225 @@
+// Package collectors provides ephemeral port exhaustion monitoring
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/analyzers"
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Default dynamic port range (IANA, used by Windows Vista+ when not reconfigured)
+const (
+	defaultDynamicPortStart = 49152
+	defaultDynamicPortCount = 16384
+)
+
+// Port exhaustion thresholds
+const (
+	portWarningPercent  = 60.0
+	portCriticalPercent = 85.0
+	portWarningHorizon  = 10 * time.Minute
+	portCriticalHorizon = 2 * time.Minute
+	portForecastFloor   = 20.0 // forecast-only alerts are ignored below this usage
+	portHistorySize     = 60   // samples used for the growth forecast
+	portMinForecast     = 5    // samples required before forecasting
+	portTopEndpoints    = 10
+)
+
+// PortExhaustionCollector tracks ephemeral port usage per local IP and per
+// remote endpoint and forecasts time to exhaustion from the growth rate
+type PortExhaustionCollector struct {
+	mu         sync.Mutex
+	rangeStart uint16
+	rangeCount int
+	history    []portSample
+}
+
+type portSample struct {
+	at      time.Time
+	percent float64
+}
+
+type portKey struct {
+	local      string
+	remote     string
+	remotePort uint16
+}
+
+type portCount struct {
+	ports    map[uint16]struct{}
+	timeWait int
+}
+
+// NewPortExhaustionCollector creates a new port exhaustion collector
+func NewPortExhaustionCollector() *PortExhaustionCollector {
+	c := &PortExhaustionCollector{
+		rangeStart: defaultDynamicPortStart,
+		rangeCount: defaultDynamicPortCount,
+	}
+	if start, count, err := getDynamicPortRange(); err == nil && count > 0 {
+		c.rangeStart = start
+		c.rangeCount = count
+	}
+	return c
+}
+
+// Name returns the collector name
+func (c *PortExhaustionCollector) Name() string {
+	return "ports"
+}
+
+// Analyze computes ephemeral port usage for the given connection table
+func (c *PortExhaustionCollector) Analyze(connections []models.TCPConnection) *models.PortExhaustionMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := &models.PortExhaustionMetrics{
+		DynamicPortStart:    c.rangeStart,
+		DynamicPortCount:    c.rangeCount,
+		SecondsToExhaustion: -1,
+		Status:              "ok",
+	}
+
+	allPorts := make(map[uint16]struct{})
+	perLocal := make(map[portKey]*portCount)
+	perEndpoint := make(map[portKey]*portCount)
+
+	for _, conn := range connections {
+		if !c.isEphemeral(conn.LocalPort) {
+			continue
+		}
+		allPorts[conn.LocalPort] = struct{}{}
+		addPort(perLocal, portKey{local: conn.LocalAddress}, conn)
+
+		// Listeners have no remote side and do not count against a 4-tuple
+		if conn.State == "LISTEN" {
+			continue
+		}
+		key := portKey{local: conn.LocalAddress, remote: conn.RemoteAddress, remotePort: conn.RemotePort}
+		addPort(perEndpoint, key, conn)
+	}
+
+	metrics.PortsInUse = len(allPorts)
+
+	for key, pc := range perLocal {
+		metrics.PerLocalAddress = append(metrics.PerLocalAddress, c.usage(key, pc))
+	}
+	for key, pc := range perEndpoint {
+		metrics.TopEndpoints = append(metrics.TopEndpoints, c.usage(key, pc))
+	}
+	sortPortUsage(metrics.PerLocalAddress)
+	sortPortUsage(metrics.TopEndpoints)
+	if len(metrics.TopEndpoints) > portTopEndpoints {
+		metrics.TopEndpoints = metrics.TopEndpoints[:portTopEndpoints]
+	}
+
+	// The binding limit is whichever local IP or 4-tuple is closest to the range size
+	if len(metrics.PerLocalAddress) > 0 {
+		worst := metrics.PerLocalAddress[0]
+		metrics.UsedPercent = worst.UsedPercent
+		metrics.Bottleneck = worst.LocalAddress
+	}
+	if len(metrics.TopEndpoints) > 0 && metrics.TopEndpoints[0].UsedPercent > metrics.UsedPercent {
+		worst := metrics.TopEndpoints[0]
+		metrics.UsedPercent = worst.UsedPercent
+		metrics.Bottleneck = fmt.Sprintf("%s -> %s:%d", worst.LocalAddress, worst.RemoteAddress, worst.RemotePort)
+	}
+
+	c.forecast(metrics, time.Now())
+	c.classify(metrics)
+
+	return metrics
+}
+
+// isEphemeral reports whether a port is inside the dynamic port range
+func (c *PortExhaustionCollector) isEphemeral(port uint16) bool {
+	return int(port) >= int(c.rangeStart) && int(port) < int(c.rangeStart)+c.rangeCount
+}
+
+// usage converts a port count into a PortUsage entry
+func (c *PortExhaustionCollector) usage(key portKey, pc *portCount) models.PortUsage {
+	u := models.PortUsage{
+		LocalAddress:  key.local,
+		RemoteAddress: key.remote,
+		RemotePort:    key.remotePort,
+		PortsInUse:    len(pc.ports),
+		TimeWait:      pc.timeWait,
+	}
+	if c.rangeCount > 0 {
+		u.UsedPercent = float64(u.PortsInUse) / float64(c.rangeCount) * 100
+	}
+	return u
+}
+
+// forecast estimates time to exhaustion from the recent growth of the worst-case usage
+func (c *PortExhaustionCollector) forecast(metrics *models.PortExhaustionMetrics, now time.Time) {
+	c.history = append(c.history, portSample{at: now, percent: metrics.UsedPercent})
+	if len(c.history) > portHistorySize {
+		c.history = c.history[len(c.history)-portHistorySize:]
+	}
+	if len(c.history) < portMinForecast {
+		return
+	}
+
+	xs := make([]float64, len(c.history))
+	ys := make([]float64, len(c.history))
+	for i, s := range c.history {
+		xs[i] = s.at.Sub(c.history[0].at).Seconds()
+		ys[i] = s.percent
+	}
+
+	slope, _, ok := analyzers.LinearFit(xs, ys)
+	if !ok {
+		return
+	}
+
+	// slope is in percent of the range per second
+	metrics.GrowthRate = slope / 100 * float64(c.rangeCount)
+	if slope > 0 && metrics.UsedPercent < 100 {
+		metrics.SecondsToExhaustion = (100 - metrics.UsedPercent) / slope
+	}
+}
+
+// classify sets the status and warning message
+func (c *PortExhaustionCollector) classify(metrics *models.PortExhaustionMetrics) {
+	eta := time.Duration(metrics.SecondsToExhaustion * float64(time.Second))
+	forecasting := metrics.SecondsToExhaustion >= 0 && metrics.UsedPercent >= portForecastFloor
+
+	switch {
+	case metrics.UsedPercent >= portCriticalPercent || (forecasting && eta <= portCriticalHorizon):
+		metrics.Status = "critical"
+	case metrics.UsedPercent >= portWarningPercent || (forecasting && eta <= portWarningHorizon):
+		metrics.Status = "warning"
+	default:
+		return
+	}
+
+	metrics.Message = fmt.Sprintf("Ephemeral ports %.1f%% used at %s", metrics.UsedPercent, metrics.Bottleneck)
+	if forecasting {
+		metrics.Message += fmt.Sprintf(", exhaustion in ~%s", eta.Round(time.Second))
+	}
+}
+
+// addPort records a connection's local port under the given key
+func addPort(counts map[portKey]*portCount, key portKey, conn models.TCPConnection) {
+	pc, ok := counts[key]
+	if !ok {
+		pc = &portCount{ports: make(map[uint16]struct{})}
+		counts[key] = pc
+	}
+	pc.ports[conn.LocalPort] = struct{}{}
+	if conn.State == "TIME_WAIT" {
+		pc.timeWait++
+	}
+}
+
+// sortPortUsage sorts usage entries by ports in use (descending)
+func sortPortUsage(usage []models.PortUsage) {
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].PortsInUse > usage[j].PortsInUse
+	})
+}
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+// Package collectors provides Pressure Stall Information (PSI) collection
+package collectors
+
+import (
+	"context"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// PressureCollector reports pressure stall information. PSI is a Linux
+// kernel feature, so on Windows it is never available.
+type PressureCollector struct{}
+
+// NewPressureCollector creates a new PSI collector
+func NewPressureCollector() (*PressureCollector, error) {
+	return &PressureCollector{}, nil
+}
+
+// Name returns the collector name
+func (c *PressureCollector) Name() string {
+	return "pressure"
+}
+
+// Collect always returns ErrNotAvailable on Windows
+func (c *PressureCollector) Collect(ctx context.Context) (*models.PressureStallMetrics, error) {
+	return nil, ErrNotAvailable
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides Pressure Stall Information (PSI) collection
+package collectors
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// PressureCollector collects system-wide PSI from /proc/pressure
+type PressureCollector struct {
+	mu          sync.Mutex
+	last        map[string]*models.PressureMetrics
+	lastCollect time.Time
+}
+
+// NewPressureCollector creates a new PSI collector
+func NewPressureCollector() (*PressureCollector, error) {
+	return &PressureCollector{last: make(map[string]*models.PressureMetrics)}, nil
+}
+
+// Name returns the collector name
+func (c *PressureCollector) Name() string {
+	return "pressure"
+}
+
+// Collect gathers PSI for CPU, memory and IO. Kernels without PSI
+// (before 4.20, or booted with psi=0) return ErrNotAvailable.
+func (c *PressureCollector) Collect(ctx context.Context) (*models.PressureStallMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+	if c.lastCollect.IsZero() {
+		elapsed = 0
+	}
+
+	metrics := &models.PressureStallMetrics{}
+	for resource, field := range map[string]**models.PressureMetrics{
+		"cpu":    &metrics.CPU,
+		"memory": &metrics.Memory,
+		"io":     &metrics.IO,
+	} {
+		pressure, err := readPressure(filepath.Join(procRoot, "pressure", resource))
+		if err != nil {
+			continue
+		}
+		pressureDelta(c.last[resource], pressure, elapsed)
+		c.last[resource] = pressure
+		*field = pressure
+	}
+
+	if metrics.CPU == nil && metrics.Memory == nil && metrics.IO == nil {
+		if _, err := os.Stat(filepath.Join(procRoot, "pressure")); err != nil {
+			return nil, ErrNotAvailable
+		}
+	}
+	c.lastCollect = now
+	return metrics, nil
+}
+
+// pressureDelta fills the interval stall time and share from the cumulative
+// totals of two samples
+func pressureDelta(prev, cur *models.PressureMetrics, elapsed float64) {
+	if prev == nil || elapsed <= 0 {
+		return
+	}
+	if cur.SomeTotal >= prev.SomeTotal {
+		cur.SomeStallSeconds = float64(cur.SomeTotal-prev.SomeTotal) / 1e6
+		cur.SomeIntervalPercent = cur.SomeStallSeconds / elapsed * 100
+	}
+	if cur.FullTotal >= prev.FullTotal {
+		cur.FullStallSeconds = float64(cur.FullTotal-prev.FullTotal) / 1e6
+		cur.FullIntervalPercent = cur.FullStallSeconds / elapsed * 100
+	}
+}
@@ -7,7 +7,9 @@ package collectors
 import (
 	"context"
 	"fmt"
-	"sort"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
 	"loadrunner-diagnosis/internal/models"
@@ -18,9 +20,34 @@ import (
 const (
 	PROCESS_QUERY_INFORMATION = 0x0400
 	PROCESS_VM_READ           = 0x0010
-	MAX_PROCESSES             = 1024
 )
 
+// MAX_HANDLES_PER_PROCESS is the kernel limit of handles a process can open
+const MAX_HANDLES_PER_PROCESS = 1 << 24
+
+// STILL_ACTIVE is the exit code GetExitCodeProcess reports for a running process
+const STILL_ACTIVE = 259
+
+// Exit codes of crashed processes: NTSTATUS errors, and unhandled .NET and
+// C++ exceptions
+const (
+	EXCEPTION_CLR         = 0xE0434352
+	EXCEPTION_CPP         = 0xE06D7363
+	STATUS_CONTROL_C_EXIT = 0xC000013A // an NTSTATUS that is not a crash
+)
+
+// crashReasons describes common crash exit codes
+var crashReasons = map[uint32]string{
+	0xC0000005:            "access violation",
+	0xC00000FD:            "stack overflow",
+	0xC0000409:            "fail-fast (stack buffer overrun)",
+	0xC0000374:            "heap corruption",
+	0xC0000017:            "out of memory",
+	EXCEPTION_CLR:         "unhandled .NET exception",
+	EXCEPTION_CPP:         "unhandled C++ exception",
+	STATUS_CONTROL_C_EXIT: "terminated by Ctrl+C",
+}
+
 // PROCESS_MEMORY_COUNTERS structure
 type PROCESS_MEMORY_COUNTERS struct {
 	Cb                         uint32
@@ -42,11 +69,28 @@ var (
 	procEnumProcesses              = processPsapi.NewProc("EnumProcesses")
 	procGetProcessMemoryInfo       = processPsapi.NewProc("GetProcessMemoryInfo")
 	procQueryFullProcessImageNameW = processKernel32.NewProc("QueryFullProcessImageNameW")
+	procGetProcessIoCounters       = processKernel32.NewProc("GetProcessIoCounters")
+	procGetProcessHandleCount      = processKernel32.NewProc("GetProcessHandleCount")
 )
 
 // ProcessCollector collects process metrics
 type ProcessCollector struct {
+	mu          sync.Mutex
 	totalMemory uint64
+	tracker     *processTracker
+	lastCollect time.Time
+	users       map[string]string // account name by SID
+	leaks       *leakDetector
+	identities  identityCache
+	lifecycle   *processLifecycle
+	watches     map[uint32]exitWatch // by PID
+}
+
+// exitWatch is a handle kept open on a process to read its exit code once it
+// is gone. The handle also keeps the PID from being reused meanwhile.
+type exitWatch struct {
+	handle    windows.Handle
+	startTime uint64
 }
 
 // NewProcessCollector creates a new process collector
@@ -70,31 +114,100 @@ func NewProcessCollector() (*ProcessCollector, error) {
 	
 	return &ProcessCollector{
 		totalMemory: memStatus.TotalPhys,
+		tracker:     newProcessTracker(),
+		users:       make(map[string]string),
+		leaks:       newLeakDetector(memStatus.TotalPhys),
+		identities:  make(identityCache),
+		lifecycle:   newProcessLifecycle(),
+		watches:     make(map[uint32]exitWatch),
 	}, nil
 }
 
+// ResetLeaks clears the leak history and sets the memory and handle limits
+// growth is projected against (0 = physical memory and the kernel handle limit)
+func (c *ProcessCollector) ResetLeaks(memoryLimit, handleLimit uint64) {
+	c.leaks.Reset(memoryLimit, handleLimit)
+}
+
+// Leaks returns the processes whose memory, handles or threads grow steadily
+func (c *ProcessCollector) Leaks() []models.ProcessLeak {
+	return c.leaks.Leaks(time.Now())
+}
+
+// LeakReport returns the leaking processes and how many are tracked
+func (c *ProcessCollector) LeakReport() *models.ProcessLeakReport {
+	return c.leaks.Report(time.Now())
+}
+
+// ResetEvents clears the lifecycle event log; the next collection is the
+// baseline
+func (c *ProcessCollector) ResetEvents() {
+	c.lifecycle.Reset()
+}
+
+// Events returns the lifecycle events of the session
+func (c *ProcessCollector) Events() []models.ProcessEvent {
+	return c.lifecycle.Events()
+}
+
+// PendingEvents returns the lifecycle events since the previous call
+func (c *ProcessCollector) PendingEvents() []models.ProcessEvent {
+	return c.lifecycle.Pending()
+}
+
 // Name returns the collector name
 func (c *ProcessCollector) Name() string {
 	return "process"
 }
 
-// Collect gathers process metrics
+// Collect gathers metrics of every process; ranking and top-N are applied
+// by a ProcessQuery. Leak detection is not fed; only monitoring ticks feed it.
 func (c *ProcessCollector) Collect(ctx context.Context) ([]models.ProcessInfo, error) {
-	// Enumerate processes
-	pids := make([]uint32, MAX_PROCESSES)
+	return c.collect(ctx, false)
+}
+
+// CollectTick gathers metrics of every process for a monitoring tick and
+// feeds them to leak detection
+func (c *ProcessCollector) CollectTick(ctx context.Context) ([]models.ProcessInfo, error) {
+	return c.collect(ctx, true)
+}
+
+// collect gathers metrics of every process, feeding leak detection when tick
+// is set
+func (c *ProcessCollector) collect(ctx context.Context, tick bool) ([]models.ProcessInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+	if c.lastCollect.IsZero() {
+		elapsed = 0
+	}
+
+	// Enumerate processes. EnumProcesses cannot report a truncated list, so
+	// the buffer grows until it is not filled completely.
+	pids := make([]uint32, 1024)
 	var bytesReturned uint32
-	
-	ret, _, _ := procEnumProcesses.Call(
-		uintptr(unsafe.Pointer(&pids[0])),
-		uintptr(len(pids)*4),
-		uintptr(unsafe.Pointer(&bytesReturned)),
-	)
-	if ret == 0 {
-		return nil, windows.GetLastError()
+	for {
+		ret, _, err := procEnumProcesses.Call(
+			uintptr(unsafe.Pointer(&pids[0])),
+			uintptr(len(pids)*4),
+			uintptr(unsafe.Pointer(&bytesReturned)),
+		)
+		if ret == 0 {
+			return nil, err
+		}
+		if int(bytesReturned) < len(pids)*4 {
+			break
+		}
+		pids = make([]uint32, len(pids)*2)
 	}
 
 	numProcesses := bytesReturned / 4
 	processes := make([]models.ProcessInfo, 0, numProcesses)
+	entries := processSnapshot()
+	seen := make(map[uint32]bool)
+	startTimes := make(map[uint32]uint64)
 
 	for i := uint32(0); i < numProcesses; i++ {
 		pid := pids[i]
@@ -102,27 +215,32 @@ func (c *ProcessCollector) Collect(ctx context.Context) ([]models.ProcessInfo, e
 			continue
 		}
 
-		info := c.getProcessInfo(pid)
+		info, counters := c.getProcessInfo(pid)
 		if info != nil {
+			info.ThreadCount = entries[pid].threads
+			info.ParentPID = entries[pid].parentPID
+			c.tracker.update(info, counters, elapsed)
+			if tick {
+				c.leaks.Record(info, counters.startTime, now)
+			}
+			seen[pid] = true
+			startTimes[pid] = counters.startTime
 			processes = append(processes, *info)
 		}
 	}
-
-	// Sort by memory usage (descending)
-	sort.Slice(processes, func(i, j int) bool {
-		return processes[i].MemoryBytes > processes[j].MemoryBytes
-	})
-
-	// Return top 50 processes
-	if len(processes) > 50 {
-		processes = processes[:50]
-	}
+	c.tracker.prune(seen)
+	c.leaks.Prune(seen)
+	c.identities.prune(seen)
+	c.lastCollect = now
+	exits, running := c.watchExits(startTimes)
+	c.lifecycle.Update(processes, exits, running, now)
 
 	return processes, nil
 }
 
-// getProcessInfo gets information about a specific process
-func (c *ProcessCollector) getProcessInfo(pid uint32) *models.ProcessInfo {
+// getProcessInfo gets information about a specific process and its
+// cumulative counters
+func (c *ProcessCollector) getProcessInfo(pid uint32) (*models.ProcessInfo, processCounters) {
 	// Open process
 	handle, err := windows.OpenProcess(
 		PROCESS_QUERY_INFORMATION|PROCESS_VM_READ,
@@ -130,12 +248,12 @@ func (c *ProcessCollector) getProcessInfo(pid uint32) *models.ProcessInfo {
 		pid,
 	)
 	if err != nil {
-		return nil
+		return nil, processCounters{}
 	}
 	defer windows.CloseHandle(handle)
 
 	// Get process name
-	name := c.getProcessName(handle)
+	name, exePath := c.getProcessName(handle)
 	if name == "" {
 		name = "Unknown"
 	}
@@ -150,26 +268,237 @@ func (c *ProcessCollector) getProcessInfo(pid uint32) *models.ProcessInfo {
 		uintptr(memCounters.Cb),
 	)
 	
+	counters := processCounters{faultWidth: 32}
 	memoryBytes := uint64(0)
+	privateBytes := uint64(0)
 	if ret != 0 {
 		memoryBytes = memCounters.WorkingSetSize
+		privateBytes = memCounters.PagefileUsage // commit charge, the Private Bytes counter
+		counters.pageFaults = uint64(memCounters.PageFaultCount)
+	}
+
+	// CPU times are in 100 ns units
+	var startTime time.Time
+	var creation, exit, kernel, user windows.Filetime
+	if windows.GetProcessTimes(handle, &creation, &exit, &kernel, &user) == nil {
+		counters.startTime = filetimeTicks(creation)
+		startTime = time.Unix(0, creation.Nanoseconds())
+		counters.kernelTime = float64(filetimeTicks(kernel)) / 1e7
+		counters.userTime = float64(filetimeTicks(user)) / 1e7
 	}
 
+	// Transfer counts include file, device and network I/O
+	var io windows.IO_COUNTERS
+	if ret, _, _ := procGetProcessIoCounters.Call(uintptr(handle), uintptr(unsafe.Pointer(&io))); ret != 0 {
+		counters.readBytes = io.ReadTransferCount
+		counters.writeBytes = io.WriteTransferCount
+	}
+
+	var handles uint32
+	procGetProcessHandleCount.Call(uintptr(handle), uintptr(unsafe.Pointer(&handles)))
+
 	memoryPercent := float64(0)
 	if c.totalMemory > 0 {
 		memoryPercent = float64(memoryBytes) / float64(c.totalMemory) * 100
 	}
 
+	id := c.identities.lookup(pid, counters.startTime, func() processIdentity {
+		commandLine, workingDir := readProcessParameters(handle)
+		return processIdentity{exePath: exePath, commandLine: commandLine, workingDir: workingDir}
+	})
+
 	return &models.ProcessInfo{
 		PID:           pid,
 		Name:          name,
 		MemoryBytes:   memoryBytes,
 		MemoryPercent: memoryPercent,
+		PrivateBytes:  privateBytes,
+		HandleCount:   handles,
+		User:          c.processUser(handle),
+		StartTime:     startTime,
+		ExePath:       id.exePath,
+		CommandLine:   id.commandLine,
+		WorkingDir:    id.workingDir,
+	}, counters
+}
+
+// watchExits reads the exit code of the watched processes that are gone and
+// starts watching the new ones. A watched process missing from startTimes
+// is only gone once its handle is signaled: OpenProcess can fail for a
+// moment on a live process, which is returned in running and stays watched.
+func (c *ProcessCollector) watchExits(startTimes map[uint32]uint64) (exits map[uint32]processExit, running map[uint32]bool) {
+	exits = make(map[uint32]processExit)
+	running = make(map[uint32]bool)
+	for pid, w := range c.watches {
+		start, ok := startTimes[pid]
+		if ok && start == w.startTime {
+			continue
+		}
+		// The held handle keeps the PID from being reused, so a missing PID
+		// is either this process, still running, or an exit
+		if !ok {
+			if event, err := windows.WaitForSingleObject(w.handle, 0); err == nil && event == uint32(windows.WAIT_TIMEOUT) {
+				running[pid] = true
+				continue
+			}
+		}
+		var code uint32
+		if windows.GetExitCodeProcess(w.handle, &code) == nil && code != STILL_ACTIVE {
+			exits[pid] = describeExit(code)
+		}
+		windows.CloseHandle(w.handle)
+		delete(c.watches, pid)
+	}
+
+	for pid, start := range startTimes {
+		if _, ok := c.watches[pid]; ok {
+			continue
+		}
+		handle, err := windows.OpenProcess(windows.SYNCHRONIZE|windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+		if err != nil {
+			continue
+		}
+		c.watches[pid] = exitWatch{handle: handle, startTime: start}
+	}
+	return exits, running
+}
+
+// describeExit classifies an exit code: NTSTATUS errors and unhandled
+// exceptions are crashes
+func describeExit(code uint32) processExit {
+	exit := processExit{code: int64(code), reason: crashReasons[code]}
+	if code >= 0xC0000000 && code != STATUS_CONTROL_C_EXIT || code == EXCEPTION_CLR || code == EXCEPTION_CPP {
+		exit.crash = true
+		if exit.reason == "" {
+			exit.reason = fmt.Sprintf("exception 0x%08X", code)
+		}
+	}
+	return exit
+}
+
+// processHandleLimit returns the number of handles a process can open
+func processHandleLimit(pid uint32) uint64 {
+	return MAX_HANDLES_PER_PROCESS
+}
+
+// processUser returns the DOMAIN\user owning a process. Names are cached by
+// SID since account lookups can go to a domain controller.
+func (c *ProcessCollector) processUser(handle windows.Handle) string {
+	var token windows.Token
+	if err := windows.OpenProcessToken(handle, windows.TOKEN_QUERY, &token); err != nil {
+		return ""
+	}
+	defer token.Close()
+
+	tokenUser, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	sid := tokenUser.User.Sid.String()
+	if name, ok := c.users[sid]; ok {
+		return name
+	}
+
+	name := sid
+	if account, domain, _, err := tokenUser.User.Sid.LookupAccount(""); err == nil {
+		name = domain + `\` + account
+	}
+	c.users[sid] = name
+	return name
+}
+
+// filetimeTicks returns a FILETIME as a count of 100 ns ticks
+func filetimeTicks(ft windows.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
+
+// processEntry is what a Toolhelp snapshot reports about a process
+type processEntry struct {
+	threads   uint32
+	parentPID uint32
+}
+
+// processSnapshot returns the thread count and parent of every process from
+// a Toolhelp snapshot
+func processSnapshot() map[uint32]processEntry {
+	entries := make(map[uint32]processEntry)
+
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return entries
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		entries[entry.ProcessID] = processEntry{threads: entry.Threads, parentPID: entry.ParentProcessID}
+	}
+	return entries
+}
+
+// unicodeString is UNICODE_STRING as laid out in another process; Buffer is
+// an address there, so it is not a Go pointer
+type unicodeString struct {
+	Length        uint16
+	MaximumLength uint16
+	Buffer        uintptr
+}
+
+// readProcessParameters reads the command line and current directory from
+// the process environment block. Protected and some system processes cannot
+// be read and return empty strings.
+func readProcessParameters(handle windows.Handle) (commandLine, workingDir string) {
+	var pbi windows.PROCESS_BASIC_INFORMATION
+	if err := windows.NtQueryInformationProcess(handle, windows.ProcessBasicInformation,
+		unsafe.Pointer(&pbi), uint32(unsafe.Sizeof(pbi)), nil); err != nil || pbi.PebBaseAddress == nil {
+		return "", ""
+	}
+
+	// Only addresses are read from the PEB, never whole structures holding
+	// pointers into another address space
+	var peb windows.PEB
+	var params windows.RTL_USER_PROCESS_PARAMETERS
+	var paramsAddr uintptr
+	pebAddr := uintptr(unsafe.Pointer(pbi.PebBaseAddress))
+	if !readProcessMemory(handle, pebAddr+unsafe.Offsetof(peb.ProcessParameters), unsafe.Pointer(&paramsAddr), unsafe.Sizeof(paramsAddr)) {
+		return "", ""
 	}
+
+	var cmd, cwd unicodeString
+	if readProcessMemory(handle, paramsAddr+unsafe.Offsetof(params.CommandLine), unsafe.Pointer(&cmd), unsafe.Sizeof(cmd)) {
+		commandLine = readRemoteString(handle, cmd)
+	}
+	if readProcessMemory(handle, paramsAddr+unsafe.Offsetof(params.CurrentDirectory), unsafe.Pointer(&cwd), unsafe.Sizeof(cwd)) {
+		// The current directory ends in a backslash; keep it only at a drive root
+		workingDir = readRemoteString(handle, cwd)
+		if len(workingDir) > 3 {
+			workingDir = strings.TrimSuffix(workingDir, `\`)
+		}
+	}
+	return commandLine, workingDir
 }
 
-// getProcessName gets the name of a process
-func (c *ProcessCollector) getProcessName(handle windows.Handle) string {
+// readRemoteString reads the characters of a UNICODE_STRING of another process
+func readRemoteString(handle windows.Handle, s unicodeString) string {
+	if s.Length == 0 || s.Buffer == 0 {
+		return ""
+	}
+	buf := make([]uint16, s.Length/2)
+	if !readProcessMemory(handle, s.Buffer, unsafe.Pointer(&buf[0]), uintptr(s.Length)) {
+		return ""
+	}
+	return windows.UTF16ToString(buf)
+}
+
+// readProcessMemory copies size bytes at addr in another process to dst
+func readProcessMemory(handle windows.Handle, addr uintptr, dst unsafe.Pointer, size uintptr) bool {
+	var read uintptr
+	return windows.ReadProcessMemory(handle, addr, (*byte)(dst), size, &read) == nil && read == size
+}
+
+// getProcessName gets the name and executable path of a process
+func (c *ProcessCollector) getProcessName(handle windows.Handle) (string, string) {
 	var buf [windows.MAX_PATH]uint16
 	size := uint32(len(buf))
 	
@@ -181,7 +510,7 @@ func (c *ProcessCollector) getProcessName(handle windows.Handle) string {
 	)
 	
 	if ret == 0 {
-		return ""
+		return "", ""
 	}
 
 	fullPath := windows.UTF16ToString(buf[:size])
@@ -189,8 +518,8 @@ func (c *ProcessCollector) getProcessName(handle windows.Handle) string {
 	// Extract just the filename
 	for i := len(fullPath) - 1; i >= 0; i-- {
 		if fullPath[i] == '\\' {
-			return fullPath[i+1:]
+			return fullPath[i+1:], fullPath
 		}
 	}
-	return fullPath
+	return fullPath, fullPath
 }
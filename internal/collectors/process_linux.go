@@ -0,0 +1,323 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides process metrics collection
+package collectors
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// ProcessCollector collects process metrics from /proc/<pid>
+type ProcessCollector struct {
+	mu          sync.Mutex
+	totalMemory uint64
+	pageSize    uint64
+	tracker     *processTracker
+	lastCollect time.Time
+	users       map[uint32]string // user name by UID
+	leaks       *leakDetector
+	identities  identityCache
+	bootTime    time.Time
+	lifecycle   *processLifecycle
+	zombies     map[uint32]processExit // exit status of zombies, until reaped
+}
+
+// NewProcessCollector creates a new process collector
+func NewProcessCollector() (*ProcessCollector, error) {
+	// Get total memory for percentage calculation
+	var totalMemory uint64
+	if meminfo, err := readProcKeyValues(filepath.Join(procRoot, "meminfo")); err == nil {
+		totalMemory = meminfo["MemTotal"]
+	}
+
+	// Process start times are in clock ticks since boot
+	var bootTime time.Time
+	if stat, err := readProcKeyValues(filepath.Join(procRoot, "stat")); err == nil {
+		bootTime = time.Unix(int64(stat["btime"]), 0)
+	}
+
+	return &ProcessCollector{
+		totalMemory: totalMemory,
+		pageSize:    uint64(os.Getpagesize()),
+		tracker:     newProcessTracker(),
+		users:       make(map[uint32]string),
+		leaks:       newLeakDetector(totalMemory),
+		identities:  make(identityCache),
+		bootTime:    bootTime,
+		lifecycle:   newProcessLifecycle(),
+		zombies:     make(map[uint32]processExit),
+	}, nil
+}
+
+// ResetLeaks clears the leak history and sets the memory and handle limits
+// growth is projected against (0 = total memory and RLIMIT_NOFILE)
+func (c *ProcessCollector) ResetLeaks(memoryLimit, handleLimit uint64) {
+	c.leaks.Reset(memoryLimit, handleLimit)
+}
+
+// Leaks returns the processes whose memory, file descriptors or threads grow
+// steadily
+func (c *ProcessCollector) Leaks() []models.ProcessLeak {
+	return c.leaks.Leaks(time.Now())
+}
+
+// LeakReport returns the leaking processes and how many are tracked
+func (c *ProcessCollector) LeakReport() *models.ProcessLeakReport {
+	return c.leaks.Report(time.Now())
+}
+
+// ResetEvents clears the lifecycle event log; the next collection is the
+// baseline
+func (c *ProcessCollector) ResetEvents() {
+	c.lifecycle.Reset()
+}
+
+// Events returns the lifecycle events of the session
+func (c *ProcessCollector) Events() []models.ProcessEvent {
+	return c.lifecycle.Events()
+}
+
+// PendingEvents returns the lifecycle events since the previous call
+func (c *ProcessCollector) PendingEvents() []models.ProcessEvent {
+	return c.lifecycle.Pending()
+}
+
+// Name returns the collector name
+func (c *ProcessCollector) Name() string {
+	return "process"
+}
+
+// Collect gathers metrics of every process; ranking and top-N are applied
+// by a ProcessQuery. Leak detection is not fed; only monitoring ticks feed it.
+func (c *ProcessCollector) Collect(ctx context.Context) ([]models.ProcessInfo, error) {
+	return c.collect(ctx, false)
+}
+
+// CollectTick gathers metrics of every process for a monitoring tick and
+// feeds them to leak detection
+func (c *ProcessCollector) CollectTick(ctx context.Context) ([]models.ProcessInfo, error) {
+	return c.collect(ctx, true)
+}
+
+// collect gathers metrics of every process, feeding leak detection when tick
+// is set
+func (c *ProcessCollector) collect(ctx context.Context, tick bool) ([]models.ProcessInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastCollect).Seconds()
+	if c.lastCollect.IsZero() {
+		elapsed = 0
+	}
+
+	pids, err := listPIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	zombies := c.zombies
+	c.zombies = make(map[uint32]processExit)
+
+	processes := make([]models.ProcessInfo, 0, len(pids))
+	seen := make(map[uint32]bool)
+	for _, pid := range pids {
+		info, counters := c.getProcessInfo(pid)
+		if info == nil {
+			continue
+		}
+		c.tracker.update(info, counters, elapsed)
+		if tick {
+			c.leaks.Record(info, counters.startTime, now)
+		}
+		seen[pid] = true
+		processes = append(processes, *info)
+	}
+	c.tracker.prune(seen)
+	c.leaks.Prune(seen)
+	c.identities.prune(seen)
+	c.lastCollect = now
+
+	// The exit status is only readable while the process is a zombie and
+	// with ptrace access; it is known for the zombies reaped since the
+	// previous collection
+	exits := make(map[uint32]processExit)
+	for pid, exit := range zombies {
+		if _, ok := c.zombies[pid]; !ok {
+			exits[pid] = exit
+		}
+	}
+	c.lifecycle.Update(processes, exits, nil, now)
+
+	return processes, nil
+}
+
+// getProcessInfo gets information about a specific process and its
+// cumulative counters. Processes that exit while being read are skipped.
+func (c *ProcessCollector) getProcessInfo(pid uint32) (*models.ProcessInfo, processCounters) {
+	dir := filepath.Join(procRoot, strconv.FormatUint(uint64(pid), 10))
+
+	stat, err := readPidStat(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, processCounters{}
+	}
+
+	if stat.state == "Z" && stat.exitCode >= 0 {
+		c.zombies[pid] = describeExit(stat.exitCode)
+	}
+
+	info := &models.ProcessInfo{
+		PID:         pid,
+		Name:        stat.comm,
+		MemoryBytes: stat.rssPages * c.pageSize,
+		ThreadCount: stat.threads,
+		ParentPID:   stat.ppid,
+	}
+	if !c.bootTime.IsZero() {
+		info.StartTime = c.bootTime.Add(time.Duration(stat.startTime) * time.Second / clockTicks)
+	}
+
+	id := c.identities.lookup(pid, stat.startTime, func() processIdentity {
+		return readProcessIdentity(dir)
+	})
+	info.ExePath = id.exePath
+	info.CommandLine = id.commandLine
+	info.WorkingDir = id.workingDir
+	if c.totalMemory > 0 {
+		info.MemoryPercent = float64(info.MemoryBytes) / float64(c.totalMemory) * 100
+	}
+
+	if resident, shared, err := readPidStatm(filepath.Join(dir, "statm")); err == nil && resident > shared {
+		info.PrivateBytes = (resident - shared) * c.pageSize
+	}
+
+	// /proc/<pid> is owned by the effective UID of the process
+	if fi, err := os.Stat(dir); err == nil {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			info.User = c.userName(st.Uid)
+		}
+	}
+
+	// Listing fd needs the same user or CAP_SYS_PTRACE; left at 0 otherwise
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		info.HandleCount = uint32(len(fds))
+	}
+
+	counters := processCounters{
+		startTime:  stat.startTime,
+		userTime:   float64(stat.utime) / clockTicks,
+		kernelTime: float64(stat.stime) / clockTicks,
+		pageFaults: stat.minflt + stat.majflt,
+		faultWidth: 64,
+	}
+
+	// rchar/wchar count all read/write calls (files, pipes, sockets), like
+	// the transfer counts on Windows
+	if io, err := readProcKeyValues(filepath.Join(dir, "io")); err == nil {
+		counters.readBytes = io["rchar"]
+		counters.writeBytes = io["wchar"]
+	}
+
+	return info, counters
+}
+
+// readProcessIdentity reads the executable, command line and working
+// directory of a process. exe and cwd of other users' processes need root or
+// CAP_SYS_PTRACE and are left empty otherwise; kernel threads have no command
+// line.
+func readProcessIdentity(dir string) processIdentity {
+	var id processIdentity
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		id.exePath = exe
+	}
+	if cwd, err := os.Readlink(filepath.Join(dir, "cwd")); err == nil {
+		id.workingDir = cwd
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		// Processes that rewrite their title (nginx, postgres) leave a
+		// single space-separated string
+		args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		if len(args) == 1 {
+			id.commandLine = args[0]
+		} else {
+			id.commandLine = joinCommandLine(args)
+		}
+	}
+	return id
+}
+
+// userName returns the name of a UID, or the UID itself when it has no
+// passwd entry (common for container users)
+func (c *ProcessCollector) userName(uid uint32) string {
+	if name, ok := c.users[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	c.users[uid] = name
+	return name
+}
+
+// processHandleLimit returns the open file limit of a process, 0 when unknown
+func processHandleLimit(pid uint32) uint64 {
+	return readOpenFilesLimit(filepath.Join(procRoot, strconv.FormatUint(uint64(pid), 10), "limits"))
+}
+
+// Signals that mean a process crashed rather than was stopped
+var crashSignals = map[syscall.Signal]bool{
+	syscall.SIGSEGV: true, syscall.SIGABRT: true, syscall.SIGBUS: true, syscall.SIGFPE: true,
+	syscall.SIGILL: true, syscall.SIGSYS: true, syscall.SIGTRAP: true,
+}
+
+// describeExit decodes a wait status: the exit code of a process that
+// exited, or 128 + the signal that killed it, as shells report it
+func describeExit(status int64) processExit {
+	ws := syscall.WaitStatus(status)
+	if !ws.Signaled() {
+		return processExit{code: int64(ws.ExitStatus())}
+	}
+	sig := ws.Signal()
+	exit := processExit{code: 128 + int64(sig), reason: "killed by " + signalName(sig)}
+	if ws.CoreDump() {
+		exit.reason += " (core dumped)"
+	}
+	switch {
+	case crashSignals[sig]:
+		exit.crash = true
+	case sig == syscall.SIGKILL:
+		exit.reason += " (OOM killer?)"
+	}
+	return exit
+}
+
+// Names of the signals a process is commonly killed by
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP: "SIGHUP", syscall.SIGINT: "SIGINT", syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL: "SIGILL", syscall.SIGTRAP: "SIGTRAP", syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS: "SIGBUS", syscall.SIGFPE: "SIGFPE", syscall.SIGKILL: "SIGKILL",
+	syscall.SIGSEGV: "SIGSEGV", syscall.SIGPIPE: "SIGPIPE", syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM", syscall.SIGSYS: "SIGSYS", syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// signalName returns the SIGxxx name of a signal
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return "signal " + strconv.Itoa(int(sig))
+}
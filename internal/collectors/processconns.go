@@ -0,0 +1,108 @@
+// Package collectors provides per-process TCP connection attribution
+package collectors
+
+import (
+	"net"
+	"sort"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// topRemoteEndpoints is how many remote endpoints are listed per process
+const topRemoteEndpoints = 5
+
+// processConnections accumulates the TCP connections of one process
+type processConnections struct {
+	total     int
+	states    map[string]int
+	listening map[uint16]bool
+	remotes   []models.TCPConnection // connected to a remote endpoint
+}
+
+// fillConnections attributes TCP connections to the processes owning them:
+// the count by state, the listening ports and the distinct remote endpoints.
+// Clients connected to a listening port of the process are counted by address
+// only, since their ports are ephemeral. Connections without an owner
+// (TIME_WAIT, or PIDs hidden from us) are skipped.
+func fillConnections(procs []models.ProcessInfo, connections []models.TCPConnection) {
+	byPID := make(map[uint32]*processConnections)
+	for _, conn := range connections {
+		if conn.PID == 0 {
+			continue
+		}
+		pc, ok := byPID[conn.PID]
+		if !ok {
+			pc = &processConnections{
+				states:    make(map[string]int),
+				listening: make(map[uint16]bool),
+			}
+			byPID[conn.PID] = pc
+		}
+		pc.total++
+		pc.states[conn.State]++
+
+		if conn.State == "LISTEN" {
+			pc.listening[conn.LocalPort] = true
+			continue
+		}
+		if conn.RemotePort == 0 || isUnspecified(conn.RemoteAddress) {
+			continue
+		}
+		pc.remotes = append(pc.remotes, conn)
+	}
+
+	for i := range procs {
+		p := &procs[i]
+		pc, ok := byPID[p.PID]
+		if !ok {
+			p.Connections = 0
+			continue
+		}
+		p.Connections = pc.total
+		p.ConnectionStates = pc.states
+
+		endpoints := make(map[models.ProcessRemoteEndpoint]int)
+		addresses := make(map[string]bool)
+		for _, conn := range pc.remotes {
+			ep := models.ProcessRemoteEndpoint{Address: conn.RemoteAddress, Port: conn.RemotePort}
+			if pc.listening[conn.LocalPort] {
+				ep = models.ProcessRemoteEndpoint{Address: conn.RemoteAddress, Inbound: true}
+			}
+			endpoints[ep]++
+			addresses[conn.RemoteAddress] = true
+		}
+		p.RemoteEndpoints = len(endpoints)
+		p.RemoteAddresses = len(addresses)
+
+		p.ListeningPorts = make([]uint16, 0, len(pc.listening))
+		for port := range pc.listening {
+			p.ListeningPorts = append(p.ListeningPorts, port)
+		}
+		sort.Slice(p.ListeningPorts, func(a, b int) bool { return p.ListeningPorts[a] < p.ListeningPorts[b] })
+
+		top := make([]models.ProcessRemoteEndpoint, 0, len(endpoints))
+		for ep, n := range endpoints {
+			ep.Connections = n
+			top = append(top, ep)
+		}
+		sort.Slice(top, func(a, b int) bool {
+			if top[a].Connections != top[b].Connections {
+				return top[a].Connections > top[b].Connections
+			}
+			if top[a].Address != top[b].Address {
+				return top[a].Address < top[b].Address
+			}
+			return top[a].Port < top[b].Port
+		})
+		if len(top) > topRemoteEndpoints {
+			top = top[:topRemoteEndpoints]
+		}
+		p.TopRemoteEndpoints = top
+	}
+}
+
+// isUnspecified reports whether an address is empty or 0.0.0.0 / ::
+func isUnspecified(address string) bool {
+	ip := net.ParseIP(address)
+	return ip == nil || ip.IsUnspecified()
+}
@@ -0,0 +1,220 @@
+// Package collectors provides process start, exit and crash events
+package collectors
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Process lifecycle settings
+const (
+	maxProcessEvents  = 1000            // events kept per session
+	restartLoopStarts = 3               // restarts within the window that make a loop
+	restartLoopWindow = 5 * time.Minute // window restarts are counted in
+)
+
+// Process event types
+const (
+	ProcessEventStart       = "start"
+	ProcessEventExit        = "exit"
+	ProcessEventCrash       = "crash"
+	ProcessEventRestartLoop = "restartLoop"
+)
+
+// processExit is how a process ended, as far as the OS reported it
+type processExit struct {
+	code   int64
+	crash  bool
+	reason string
+}
+
+// lifecycleEntry is a running process as last enumerated
+type lifecycleEntry struct {
+	startTime   time.Time
+	parentPID   uint32
+	name        string
+	commandLine string
+}
+
+// processLifecycle diffs successive process enumerations into start and
+// exit events. A process that starts again with the same name and command
+// line after exiting counts as a restart; restartLoopStarts of them within
+// restartLoopWindow are reported as a restart loop.
+type processLifecycle struct {
+	mu           sync.Mutex
+	known        map[uint32]lifecycleEntry // by PID
+	lastExit     map[string]time.Time      // by name and command line
+	restarts     map[string][]time.Time    // recent restarts by name and command line
+	loopReported map[string]time.Time
+	events       []models.ProcessEvent // session log
+	pending      []models.ProcessEvent // not yet streamed
+}
+
+// newProcessLifecycle creates a lifecycle; the next update is the baseline
+func newProcessLifecycle() *processLifecycle {
+	return &processLifecycle{
+		lastExit:     make(map[string]time.Time),
+		restarts:     make(map[string][]time.Time),
+		loopReported: make(map[string]time.Time),
+	}
+}
+
+// Reset starts a new session: the next update is the baseline and the event
+// log is cleared
+func (l *processLifecycle) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = nil
+	l.lastExit = make(map[string]time.Time)
+	l.restarts = make(map[string][]time.Time)
+	l.loopReported = make(map[string]time.Time)
+	l.events = nil
+	l.pending = nil
+}
+
+// Update records the processes enumerated now. exits holds what the
+// platform knows about how processes that are gone ended, by PID; running
+// holds the PIDs that could not be read now but are known to be still
+// running, which are kept as they were.
+func (l *processLifecycle) Update(processes []models.ProcessInfo, exits map[uint32]processExit, running map[uint32]bool, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := make(map[uint32]lifecycleEntry, len(processes))
+	for _, p := range processes {
+		current[p.PID] = lifecycleEntry{startTime: p.StartTime, parentPID: p.ParentPID, name: p.Name, commandLine: p.CommandLine}
+	}
+	for pid := range running {
+		if prev, ok := l.known[pid]; ok {
+			if _, ok := current[pid]; !ok {
+				current[pid] = prev
+			}
+		}
+	}
+	if l.known == nil {
+		l.known = current
+		return
+	}
+
+	var exited, started []models.ProcessEvent
+	for pid, prev := range l.known {
+		if cur, ok := current[pid]; ok && cur.startTime.Equal(prev.startTime) {
+			continue
+		}
+		event := models.ProcessEvent{
+			Timestamp:   now,
+			Type:        ProcessEventExit,
+			PID:         pid,
+			ParentPID:   prev.parentPID,
+			Name:        prev.name,
+			CommandLine: prev.commandLine,
+		}
+		if !prev.startTime.IsZero() {
+			event.LifetimeSeconds = now.Sub(prev.startTime).Seconds()
+		}
+		if exit, ok := exits[pid]; ok {
+			code := exit.code
+			event.ExitCode = &code
+			event.Reason = exit.reason
+			if exit.crash {
+				event.Type = ProcessEventCrash
+			}
+		}
+		l.lastExit[prev.name+"\x00"+prev.commandLine] = now
+		exited = append(exited, event)
+	}
+
+	for pid, cur := range current {
+		if prev, ok := l.known[pid]; ok && prev.startTime.Equal(cur.startTime) {
+			continue
+		}
+		started = append(started, models.ProcessEvent{
+			Timestamp:   now,
+			Type:        ProcessEventStart,
+			PID:         pid,
+			ParentPID:   cur.parentPID,
+			Name:        cur.name,
+			CommandLine: cur.commandLine,
+		})
+
+		// A start is a restart when the same program exited recently
+		key := cur.name + "\x00" + cur.commandLine
+		if exitedAt, ok := l.lastExit[key]; !ok || now.Sub(exitedAt) > restartLoopWindow {
+			continue
+		}
+		restarts := append(l.restarts[key], now)
+		for len(restarts) > 0 && now.Sub(restarts[0]) > restartLoopWindow {
+			restarts = restarts[1:]
+		}
+		l.restarts[key] = restarts
+		if len(restarts) >= restartLoopStarts && now.Sub(l.loopReported[key]) > restartLoopWindow {
+			l.loopReported[key] = now
+			started = append(started, models.ProcessEvent{
+				Timestamp:   now,
+				Type:        ProcessEventRestartLoop,
+				PID:         pid,
+				ParentPID:   cur.parentPID,
+				Name:        cur.name,
+				CommandLine: cur.commandLine,
+				Restarts:    len(restarts),
+			})
+		}
+	}
+
+	// Forget restart history that left the window
+	for key, at := range l.lastExit {
+		if now.Sub(at) > restartLoopWindow {
+			delete(l.lastExit, key)
+		}
+	}
+	for key, restarts := range l.restarts {
+		if now.Sub(restarts[len(restarts)-1]) > restartLoopWindow {
+			delete(l.restarts, key)
+		}
+	}
+	for key, at := range l.loopReported {
+		if now.Sub(at) > restartLoopWindow {
+			delete(l.loopReported, key)
+		}
+	}
+
+	// Exits first, then starts, each by PID
+	byPID := func(events []models.ProcessEvent) {
+		sort.SliceStable(events, func(i, j int) bool { return events[i].PID < events[j].PID })
+	}
+	byPID(exited)
+	byPID(started)
+	events := append(exited, started...)
+
+	l.known = current
+	l.events = appendEvents(l.events, events)
+	l.pending = appendEvents(l.pending, events)
+}
+
+// Events returns the event log of the session
+func (l *processLifecycle) Events() []models.ProcessEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]models.ProcessEvent{}, l.events...)
+}
+
+// Pending returns the events since the previous call
+func (l *processLifecycle) Pending() []models.ProcessEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pending := l.pending
+	l.pending = nil
+	return pending
+}
+
+// appendEvents appends events keeping the newest maxProcessEvents
+func appendEvents(log, events []models.ProcessEvent) []models.ProcessEvent {
+	log = append(log, events...)
+	if len(log) > maxProcessEvents {
+		log = log[len(log)-maxProcessEvents:]
+	}
+	return log
+}
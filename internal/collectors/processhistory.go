@@ -0,0 +1,272 @@
+// Package collectors provides the history of pinned processes
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Pinned process history settings
+const (
+	processHistorySize = 3600 // samples per pinned process, 1 hour at 1s
+	maxPinnedSeries    = 50   // series kept; exited processes are dropped first
+)
+
+// processPin selects processes to record: a PID, or a case-insensitive
+// regular expression matched against the name and the command line
+type processPin struct {
+	text  string
+	pid   uint32
+	regex *regexp.Regexp
+}
+
+// matches reports whether a process is selected by the pin
+func (p processPin) matches(info *models.ProcessInfo) bool {
+	if p.regex == nil {
+		return info.PID == p.pid
+	}
+	return p.regex.MatchString(info.Name) || p.regex.MatchString(info.CommandLine)
+}
+
+// processSeries is the history of one pinned process across restarts
+type processSeries struct {
+	pin         string
+	name        string
+	commandLine string
+	pid         uint32
+	startTime   time.Time
+	pids        []uint32
+	running     bool
+	lastSeen    time.Time
+	samples     []models.ProcessSample
+}
+
+// processHistory records a dedicated series for every pinned process, so its
+// history survives dropping out of the top N and restarts under a new PID
+type processHistory struct {
+	mu     sync.Mutex
+	pins   []processPin
+	series []*processSeries
+}
+
+// newProcessHistory creates a history without pins
+func newProcessHistory() *processHistory {
+	return &processHistory{}
+}
+
+// SetPins replaces the pins. Series of removed pins are dropped.
+func (h *processHistory) SetPins(pins []string) error {
+	parsed := make([]processPin, 0, len(pins))
+	for _, text := range pins {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if pid, err := strconv.ParseUint(text, 10, 32); err == nil {
+			parsed = append(parsed, processPin{text: text, pid: uint32(pid)})
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + text)
+		if err != nil {
+			return fmt.Errorf("invalid pin %q: %v", text, err)
+		}
+		parsed = append(parsed, processPin{text: text, regex: re})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pins = parsed
+
+	kept := h.series[:0]
+	for _, s := range h.series {
+		for _, p := range parsed {
+			if p.text == s.pin {
+				kept = append(kept, s)
+				break
+			}
+		}
+	}
+	h.series = kept
+	return nil
+}
+
+// Pins returns the pins
+func (h *processHistory) Pins() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pins := make([]string, len(h.pins))
+	for i, p := range h.pins {
+		pins[i] = p.text
+	}
+	return pins
+}
+
+// Reset drops every series; the pins are kept
+func (h *processHistory) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.series = nil
+}
+
+// Record adds a sample to the series of every pinned process. A process not
+// seen before continues the series of an exited process with the same name
+// and command line, or starts a new one when a pin matches it.
+func (h *processHistory) Record(processes []models.ProcessInfo, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pins) == 0 {
+		return
+	}
+
+	// Processes still running under the PID their series knows keep it;
+	// the others are matched after every exit of this round is known
+	current := make(map[uint32]*processSeries)
+	for _, s := range h.series {
+		if s.running {
+			current[s.pid] = s
+		}
+	}
+	recorded := make(map[*processSeries]*models.ProcessInfo)
+	var fresh []*models.ProcessInfo
+	for i := range processes {
+		p := &processes[i]
+		if s := current[p.PID]; s != nil && s.startTime.Equal(p.StartTime) {
+			recorded[s] = p
+			continue
+		}
+		fresh = append(fresh, p)
+	}
+	for _, s := range h.series {
+		s.running = recorded[s] != nil
+	}
+	for _, p := range fresh {
+		if s := h.attach(p); s != nil {
+			s.running = true
+			recorded[s] = p
+		}
+	}
+	if len(recorded) == 0 {
+		return
+	}
+
+	for s, p := range recorded {
+		s.lastSeen = now
+		s.samples = append(s.samples, models.ProcessSample{
+			Timestamp:        now,
+			PID:              p.PID,
+			CPUPercent:       p.CPUPercent,
+			MemoryBytes:      p.MemoryBytes,
+			PrivateBytes:     p.PrivateBytes,
+			ReadBytesPerSec:  p.ReadBytesPerSec,
+			WriteBytesPerSec: p.WriteBytesPerSec,
+			ThreadCount:      p.ThreadCount,
+			HandleCount:      p.HandleCount,
+			Connections:      p.Connections,
+			ConnectionStates: p.ConnectionStates,
+		})
+		if len(s.samples) > processHistorySize {
+			s.samples = s.samples[len(s.samples)-processHistorySize:]
+		}
+	}
+}
+
+// attach returns the series a newly seen process belongs to: the series of
+// an exited process with the same name and command line, or a new series if
+// a pin matches. It returns nil for processes that are not pinned.
+func (h *processHistory) attach(p *models.ProcessInfo) *processSeries {
+	for _, s := range h.series {
+		if !s.running && s.name == p.Name && s.commandLine == p.CommandLine {
+			s.pid = p.PID
+			s.startTime = p.StartTime
+			s.pids = append(s.pids, p.PID)
+			return s
+		}
+	}
+
+	for _, pin := range h.pins {
+		if !pin.matches(p) {
+			continue
+		}
+		if len(h.series) >= maxPinnedSeries && !h.dropExited() {
+			return nil
+		}
+		s := &processSeries{
+			pin:         pin.text,
+			name:        p.Name,
+			commandLine: p.CommandLine,
+			pid:         p.PID,
+			startTime:   p.StartTime,
+			pids:        []uint32{p.PID},
+		}
+		h.series = append(h.series, s)
+		return s
+	}
+	return nil
+}
+
+// dropExited removes the series of the process that exited longest ago,
+// reporting false when every pinned process is still running
+func (h *processHistory) dropExited() bool {
+	oldest := -1
+	for i, s := range h.series {
+		if !s.running && (oldest < 0 || s.lastSeen.Before(h.series[oldest].lastSeen)) {
+			oldest = i
+		}
+	}
+	if oldest < 0 {
+		return false
+	}
+	h.series = append(h.series[:oldest], h.series[oldest+1:]...)
+	return true
+}
+
+// List returns every series without its samples
+func (h *processHistory) List() []models.ProcessHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]models.ProcessHistory, 0, len(h.series))
+	for _, s := range h.series {
+		list = append(list, s.summary())
+	}
+	return list
+}
+
+// Get returns the series of a process by any of its PIDs, preferring the
+// process running now
+func (h *processHistory) Get(pid uint32) (*models.ProcessHistory, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var found *processSeries
+	for _, s := range h.series {
+		for _, p := range s.pids {
+			if p == pid && (found == nil || s.running && !found.running || s.lastSeen.After(found.lastSeen)) {
+				found = s
+			}
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	history := found.summary()
+	history.Samples = append([]models.ProcessSample{}, found.samples...)
+	return &history, true
+}
+
+// summary describes a series without its samples
+func (s *processSeries) summary() models.ProcessHistory {
+	return models.ProcessHistory{
+		Pin:         s.pin,
+		Name:        s.name,
+		CommandLine: s.commandLine,
+		PIDs:        append([]uint32{}, s.pids...),
+		Running:     s.running,
+		SampleCount: len(s.samples),
+	}
+}
@@ -0,0 +1,279 @@
+// Package collectors provides process memory, handle and thread leak detection
+package collectors
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/analyzers"
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Leak detection settings
+const (
+	leakHistorySize     = 240              // samples kept per process; older history is thinned
+	leakMinSamples      = 48               // samples required before testing for growth
+	leakMinWindow       = 5 * time.Minute  // history required before testing for growth
+	leakSegments        = 16               // windows whose minimums form the post-GC floor
+	leakMinConfidence   = 0.99             // of the upward trend of the floor
+	leakMinGrowth       = 5.0              // % growth of the floor over the window
+	leakAnalyzeInterval = 30 * time.Second // leaks are re-analyzed at most this often
+	leakCriticalHorizon = time.Hour        // critical when the limit is reached sooner
+)
+
+// Tracked resources
+const (
+	leakPrivateBytes = iota
+	leakWorkingSet
+	leakHandles
+	leakThreads
+	leakResourceCount
+)
+
+// leakResourceNames are the resource names reported in ProcessLeak
+var leakResourceNames = [leakResourceCount]string{"privateBytes", "workingSet", "handles", "threads"}
+
+// leakMinIncrease is the smallest growth over the window worth reporting,
+// so a process going from 3 to 4 threads is not a leak
+var leakMinIncrease = [leakResourceCount]float64{1 << 20, 1 << 20, 20, 5}
+
+type leakSample struct {
+	at     time.Time
+	values [leakResourceCount]float64
+}
+
+// leakSeries is the history of one process. When full, every other sample
+// is dropped and the sampling stride doubles, so the history always spans
+// the whole monitoring run.
+type leakSeries struct {
+	name      string
+	startTime uint64 // tells a reused PID apart
+	samples   []leakSample
+	last      leakSample
+	stride    int
+	skipped   int
+}
+
+// leakDetector flags processes whose memory, handles or threads keep growing.
+// Managed runtimes make memory a sawtooth: it climbs until a collection and
+// drops back. The trend is therefore fitted to the floor of the sawtooth, the
+// minimum of each segment of the history, which only rises when memory
+// survives collections. A Mann-Kendall test on the floor decides whether the
+// rise is significant and a Theil-Sen fit gives its rate.
+type leakDetector struct {
+	mu          sync.Mutex
+	series      map[uint32]*leakSeries // by PID
+	totalMemory uint64
+	memoryLimit uint64 // bytes; total physical memory when 0
+	handleLimit uint64 // per process; the OS limit when 0
+	analyzedAt  time.Time
+	leaks       []models.ProcessLeak
+}
+
+// newLeakDetector creates a detector projecting memory growth against totalMemory
+func newLeakDetector(totalMemory uint64) *leakDetector {
+	return &leakDetector{
+		series:      make(map[uint32]*leakSeries),
+		totalMemory: totalMemory,
+	}
+}
+
+// Reset clears the history and sets the limits growth is projected against
+// (0 = default)
+func (d *leakDetector) Reset(memoryLimit, handleLimit uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.series = make(map[uint32]*leakSeries)
+	d.memoryLimit = memoryLimit
+	d.handleLimit = handleLimit
+	d.analyzedAt = time.Time{}
+	d.leaks = nil
+}
+
+// Record adds a sample of a process
+func (d *leakDetector) Record(info *models.ProcessInfo, startTime uint64, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.series[info.PID]
+	if !ok || s.startTime != startTime || s.name != info.Name {
+		s = &leakSeries{name: info.Name, startTime: startTime, stride: 1}
+		d.series[info.PID] = s
+	}
+
+	sample := leakSample{at: now}
+	sample.values[leakPrivateBytes] = float64(info.PrivateBytes)
+	sample.values[leakWorkingSet] = float64(info.MemoryBytes)
+	sample.values[leakHandles] = float64(info.HandleCount)
+	sample.values[leakThreads] = float64(info.ThreadCount)
+	s.last = sample
+
+	if s.skipped++; s.skipped < s.stride {
+		return
+	}
+	s.skipped = 0
+	s.samples = append(s.samples, sample)
+	if len(s.samples) >= leakHistorySize {
+		thinned := s.samples[:0]
+		for i := 0; i < len(s.samples); i += 2 {
+			thinned = append(thinned, s.samples[i])
+		}
+		s.samples = thinned
+		s.stride *= 2
+	}
+}
+
+// Prune forgets processes that were not seen in the last collection
+func (d *leakDetector) Prune(seen map[uint32]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for pid := range d.series {
+		if !seen[pid] {
+			delete(d.series, pid)
+		}
+	}
+}
+
+// Leaks returns the leaking resources, re-analyzing the history when the
+// last analysis is older than leakAnalyzeInterval
+func (d *leakDetector) Leaks(now time.Time) []models.ProcessLeak {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.analyze(now)
+	return append([]models.ProcessLeak(nil), d.leaks...)
+}
+
+// Report returns the leaking resources and how many processes are tracked
+func (d *leakDetector) Report(now time.Time) *models.ProcessLeakReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.analyze(now)
+	return &models.ProcessLeakReport{
+		AnalyzedAt: d.analyzedAt,
+		Tracked:    len(d.series),
+		Leaks:      append([]models.ProcessLeak{}, d.leaks...),
+	}
+}
+
+// analyze tests every tracked resource for sustained growth
+func (d *leakDetector) analyze(now time.Time) {
+	if !d.analyzedAt.IsZero() && now.Sub(d.analyzedAt) < leakAnalyzeInterval {
+		return
+	}
+	d.analyzedAt = now
+
+	memoryLimit := d.memoryLimit
+	if memoryLimit == 0 {
+		memoryLimit = d.totalMemory
+	}
+
+	var leaks []models.ProcessLeak
+	for pid, s := range d.series {
+		for r := 0; r < leakResourceCount; r++ {
+			leak, ok := s.leak(r)
+			if !ok {
+				continue
+			}
+			leak.PID = pid
+
+			var limit uint64
+			switch r {
+			case leakPrivateBytes, leakWorkingSet:
+				limit = memoryLimit
+			case leakHandles:
+				limit = d.handleLimit
+				if limit == 0 {
+					limit = processHandleLimit(pid)
+				}
+			}
+			leak.SecondsToLimit = -1
+			if limit > 0 {
+				leak.Limit = float64(limit)
+				leak.SecondsToLimit = math.Max(leak.Limit-leak.Current, 0) / leak.GrowthPerSec
+			}
+
+			leak.Severity = "warning"
+			if leak.SecondsToLimit >= 0 && leak.SecondsToLimit < leakCriticalHorizon.Seconds() {
+				leak.Severity = "critical"
+			}
+			leaks = append(leaks, leak)
+		}
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Severity != leaks[j].Severity {
+			return leaks[i].Severity == "critical"
+		}
+		if leaks[i].GrowthPercent != leaks[j].GrowthPercent {
+			return leaks[i].GrowthPercent > leaks[j].GrowthPercent
+		}
+		return leaks[i].PID < leaks[j].PID
+	})
+	d.leaks = leaks
+}
+
+// leak tests one resource of the series for sustained growth of its floor
+func (s *leakSeries) leak(r int) (models.ProcessLeak, bool) {
+	n := len(s.samples)
+	if n < leakMinSamples {
+		return models.ProcessLeak{}, false
+	}
+	first := s.samples[0].at
+	window := s.samples[n-1].at.Sub(first)
+	if window < leakMinWindow {
+		return models.ProcessLeak{}, false
+	}
+
+	// The floor is the minimum of each segment, placed at the time it was seen
+	xs := make([]float64, leakSegments)
+	ys := make([]float64, leakSegments)
+	for k := 0; k < leakSegments; k++ {
+		lo, hi := k*n/leakSegments, (k+1)*n/leakSegments
+		low := lo
+		for i := lo + 1; i < hi; i++ {
+			if s.samples[i].values[r] < s.samples[low].values[r] {
+				low = i
+			}
+		}
+		xs[k] = s.samples[low].at.Sub(first).Seconds()
+		ys[k] = s.samples[low].values[r]
+	}
+
+	slope, intercept, ok := analyzers.TheilSenFit(xs, ys)
+	if !ok || slope <= 0 {
+		return models.ProcessLeak{}, false
+	}
+	growth := slope * window.Seconds()
+	if growth < leakMinIncrease[r] {
+		return models.ProcessLeak{}, false
+	}
+	var percent float64
+	if intercept > 0 {
+		percent = growth / intercept * 100
+		if percent < leakMinGrowth {
+			return models.ProcessLeak{}, false
+		}
+	}
+
+	z, ok := analyzers.MannKendall(ys)
+	if !ok {
+		return models.ProcessLeak{}, false
+	}
+	confidence := 0.5 * math.Erfc(-z/math.Sqrt2)
+	if confidence < leakMinConfidence {
+		return models.ProcessLeak{}, false
+	}
+
+	return models.ProcessLeak{
+		Name:          s.name,
+		Resource:      leakResourceNames[r],
+		Current:       s.last.values[r],
+		GrowthPerSec:  slope,
+		GrowthPercent: percent,
+		WindowSeconds: window.Seconds(),
+		Samples:       n,
+		Confidence:    confidence,
+	}, true
+}
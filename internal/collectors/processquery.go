@@ -0,0 +1,138 @@
+// Package collectors provides process ranking and filtering
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Process sort keys
+const (
+	ProcessSortCPU         = "cpu"
+	ProcessSortMemory      = "memory"
+	ProcessSortIO          = "io"
+	ProcessSortHandles     = "handles"
+	ProcessSortThreads     = "threads"
+	ProcessSortConnections = "connections"
+	ProcessSortCloseWait   = "closeWait"
+)
+
+// DefaultProcessTop is how many processes are returned when no top-N is given
+const DefaultProcessTop = 50
+
+// processSortValues maps sort keys to the value processes are ranked by
+var processSortValues = map[string]func(p *models.ProcessInfo) float64{
+	ProcessSortCPU:         func(p *models.ProcessInfo) float64 { return p.CPUPercent },
+	ProcessSortMemory:      func(p *models.ProcessInfo) float64 { return float64(p.MemoryBytes) },
+	ProcessSortIO:          func(p *models.ProcessInfo) float64 { return p.ReadBytesPerSec + p.WriteBytesPerSec },
+	ProcessSortHandles:     func(p *models.ProcessInfo) float64 { return float64(p.HandleCount) },
+	ProcessSortThreads:     func(p *models.ProcessInfo) float64 { return float64(p.ThreadCount) },
+	ProcessSortConnections: func(p *models.ProcessInfo) float64 { return float64(p.Connections) },
+	ProcessSortCloseWait:   func(p *models.ProcessInfo) float64 { return float64(p.ConnectionStates["CLOSE_WAIT"]) },
+}
+
+// ProcessQuery selects and ranks processes
+type ProcessQuery struct {
+	Sort  string         // one of the ProcessSort keys; memory when empty
+	Top   int            // processes returned, DefaultProcessTop when 0
+	Name  string         // case-insensitive substring of the name
+	Regex *regexp.Regexp // matched against the name
+	User  string         // owner, case-insensitive; "user" also matches "DOMAIN\user"
+	Watch []string       // names or PIDs that are always included
+}
+
+// Apply filters, sorts and truncates processes. Watched processes are added
+// after the top N even when filtered out or ranked lower, and marked.
+func (q ProcessQuery) Apply(processes []models.ProcessInfo) ([]models.ProcessInfo, error) {
+	key := q.Sort
+	if key == "" {
+		key = ProcessSortMemory
+	}
+	value, ok := processSortValues[key]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q: use cpu, memory, io, handles, threads, connections or closeWait", q.Sort)
+	}
+	top := q.Top
+	if top <= 0 {
+		top = DefaultProcessTop
+	}
+
+	var selected []models.ProcessInfo
+	var watched []models.ProcessInfo
+	for _, p := range processes {
+		if q.watches(&p) {
+			p.Watched = true
+			watched = append(watched, p)
+			continue
+		}
+		if q.matches(&p) {
+			selected = append(selected, p)
+		}
+	}
+
+	rank := func(list []models.ProcessInfo) {
+		sort.Slice(list, func(i, j int) bool {
+			vi, vj := value(&list[i]), value(&list[j])
+			if vi != vj {
+				return vi > vj
+			}
+			return list[i].PID < list[j].PID
+		})
+	}
+	rank(selected)
+	rank(watched)
+
+	if len(selected) > top {
+		selected = selected[:top]
+	}
+	return append(selected, watched...), nil
+}
+
+// matches reports whether a process passes the name, regex and user filters
+func (q ProcessQuery) matches(p *models.ProcessInfo) bool {
+	if q.Name != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if q.Regex != nil && !q.Regex.MatchString(p.Name) {
+		return false
+	}
+	if q.User != "" && !matchUser(p.User, q.User) {
+		return false
+	}
+	return true
+}
+
+// watches reports whether a process is on the watch list, by PID or by name
+// (case-insensitive, ".exe" optional)
+func (q ProcessQuery) watches(p *models.ProcessInfo) bool {
+	name := strings.TrimSuffix(strings.ToLower(p.Name), ".exe")
+	for _, w := range q.Watch {
+		if pid, err := strconv.ParseUint(w, 10, 32); err == nil {
+			if uint32(pid) == p.PID {
+				return true
+			}
+			continue
+		}
+		if strings.TrimSuffix(strings.ToLower(w), ".exe") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchUser compares a process owner with a filter; a filter without a
+// domain matches the account name of a DOMAIN\user owner
+func matchUser(owner, filter string) bool {
+	if strings.EqualFold(owner, filter) {
+		return true
+	}
+	if i := strings.LastIndexByte(owner, '\\'); i >= 0 && !strings.Contains(filter, `\`) {
+		return strings.EqualFold(owner[i+1:], filter)
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Package collectors provides per-process rate tracking shared by the process collectors
+package collectors
+
+import (
+	"runtime"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// processCounters is one sample of a process's cumulative counters
+type processCounters struct {
+	startTime  uint64  // creation time in platform units, tells a reused PID apart
+	userTime   float64 // seconds
+	kernelTime float64 // seconds
+	readBytes  uint64
+	writeBytes uint64
+	pageFaults uint64
+	faultWidth uint // bit width of pageFaults: 32 on Windows
+}
+
+// processTracker keeps the previous sample of every process to compute rates
+type processTracker struct {
+	last  map[uint32]processCounters
+	cores int
+}
+
+// newProcessTracker creates a tracker normalising CPU to the usable cores
+func newProcessTracker() *processTracker {
+	return &processTracker{
+		last:  make(map[uint32]processCounters),
+		cores: runtime.NumCPU(),
+	}
+}
+
+// update fills the CPU percentages and rates of info from this sample. CPU is
+// a share of all cores, so a process saturating one of 8 cores shows 12.5%.
+func (t *processTracker) update(info *models.ProcessInfo, cur processCounters, elapsed float64) {
+	prev, ok := t.last[info.PID]
+	t.last[info.PID] = cur
+	if !ok || prev.startTime != cur.startTime || elapsed <= 0 {
+		return
+	}
+
+	capacity := elapsed * float64(t.cores)
+	if user := cur.userTime - prev.userTime; user > 0 {
+		info.UserPercent = user / capacity * 100
+	}
+	if kernel := cur.kernelTime - prev.kernelTime; kernel > 0 {
+		info.KernelPercent = kernel / capacity * 100
+	}
+	info.CPUPercent = info.UserPercent + info.KernelPercent
+
+	info.ReadBytesPerSec = counterRate(cur.readBytes, prev.readBytes, elapsed)
+	info.WriteBytesPerSec = counterRate(cur.writeBytes, prev.writeBytes, elapsed)
+	if faults, _, reset := counterDelta(cur.pageFaults, prev.pageFaults, cur.faultWidth); !reset {
+		info.PageFaultsPerSec = float64(faults) / elapsed
+	}
+}
+
+// prune forgets processes that were not seen in the last collection
+func (t *processTracker) prune(seen map[uint32]bool) {
+	for pid := range t.last {
+		if !seen[pid] {
+			delete(t.last, pid)
+		}
+	}
+}
+
+// processIdentity is what is read once per process: its executable, command
+// line and working directory as first seen
+type processIdentity struct {
+	startTime   uint64 // tells a reused PID apart
+	exePath     string
+	commandLine string
+	workingDir  string
+}
+
+// identityCache keeps the identity of running processes by PID
+type identityCache map[uint32]processIdentity
+
+// lookup returns the cached identity of a process, or reads it with read
+func (c identityCache) lookup(pid uint32, startTime uint64, read func() processIdentity) processIdentity {
+	if id, ok := c[pid]; ok && id.startTime == startTime {
+		return id
+	}
+	id := read()
+	id.startTime = startTime
+	c[pid] = id
+	return id
+}
+
+// prune forgets processes that were not seen in the last collection
+func (c identityCache) prune(seen map[uint32]bool) {
+	for pid := range c {
+		if !seen[pid] {
+			delete(c, pid)
+		}
+	}
+}
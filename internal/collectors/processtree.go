@@ -0,0 +1,262 @@
+// Package collectors provides the process tree and process grouping
+package collectors
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Process grouping keys
+const (
+	ProcessGroupName    = "name"    // by executable name
+	ProcessGroupApp     = "app"     // by name and application: IIS app pool, JVM main class, script
+	ProcessGroupPattern = "pattern" // by a regular expression matched against the command line
+)
+
+// BuildProcessTree arranges processes under their parents. A process whose
+// parent is gone, or whose parent PID was reused by a process started after
+// it, is a root. Roots and children are ordered by PID.
+func BuildProcessTree(processes []models.ProcessInfo) []models.ProcessNode {
+	byPID := make(map[uint32]*models.ProcessInfo, len(processes))
+	for i := range processes {
+		byPID[processes[i].PID] = &processes[i]
+	}
+
+	children := make(map[uint32][]uint32)
+	var roots []uint32
+	for _, p := range processes {
+		parent, ok := byPID[p.ParentPID]
+		if !ok || p.ParentPID == p.PID || parent.StartTime.After(p.StartTime) {
+			roots = append(roots, p.PID)
+			continue
+		}
+		children[p.ParentPID] = append(children[p.ParentPID], p.PID)
+	}
+
+	var build func(pid uint32) models.ProcessNode
+	build = func(pid uint32) models.ProcessNode {
+		node := models.ProcessNode{ProcessInfo: *byPID[pid]}
+		node.TreeCPUPercent = node.CPUPercent
+		node.TreeMemoryBytes = node.MemoryBytes
+		kids := children[pid]
+		sort.Slice(kids, func(i, j int) bool { return kids[i] < kids[j] })
+		for _, kid := range kids {
+			child := build(kid)
+			node.Descendants += child.Descendants + 1
+			node.TreeCPUPercent += child.TreeCPUPercent
+			node.TreeMemoryBytes += child.TreeMemoryBytes
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	tree := make([]models.ProcessNode, 0, len(roots))
+	for _, pid := range roots {
+		tree = append(tree, build(pid))
+	}
+	return tree
+}
+
+// GroupProcesses aggregates processes by name, by application or by the
+// first capture group (or the whole match) of pattern in the command line.
+// Processes the pattern does not match are left out. Groups are ranked by
+// sort, one of the ProcessSort keys.
+func GroupProcesses(processes []models.ProcessInfo, by string, pattern *regexp.Regexp, sortKey string) ([]models.ProcessGroup, error) {
+	if sortKey == "" {
+		sortKey = ProcessSortMemory
+	}
+	value, ok := processSortValues[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q: use cpu, memory, io, handles, threads, connections or closeWait", sortKey)
+	}
+
+	var key func(p *models.ProcessInfo) (string, bool)
+	switch by {
+	case "", ProcessGroupName:
+		key = func(p *models.ProcessInfo) (string, bool) { return p.Name, true }
+	case ProcessGroupApp:
+		key = func(p *models.ProcessInfo) (string, bool) {
+			if app := processApp(p); app != "" {
+				return p.Name + ": " + app, true
+			}
+			return p.Name, true
+		}
+	case ProcessGroupPattern:
+		if pattern == nil {
+			return nil, fmt.Errorf("grouping by pattern needs a pattern")
+		}
+		key = func(p *models.ProcessInfo) (string, bool) {
+			m := pattern.FindStringSubmatch(p.CommandLine)
+			if m == nil {
+				return "", false
+			}
+			if len(m) > 1 && m[1] != "" {
+				return m[1], true
+			}
+			return m[0], true
+		}
+	default:
+		return nil, fmt.Errorf("invalid grouping %q: use name, app or pattern", by)
+	}
+
+	groups := make(map[string]*models.ProcessGroup)
+	totals := make(map[string]*models.ProcessInfo)
+	for i := range processes {
+		p := &processes[i]
+		k, ok := key(p)
+		if !ok {
+			continue
+		}
+		g, ok := groups[k]
+		if !ok {
+			g = &models.ProcessGroup{Key: k}
+			groups[k] = g
+			totals[k] = &models.ProcessInfo{}
+		}
+		g.Processes++
+		g.PIDs = append(g.PIDs, p.PID)
+		g.CPUPercent += p.CPUPercent
+		g.MemoryBytes += p.MemoryBytes
+		g.PrivateBytes += p.PrivateBytes
+		g.ReadBytesPerSec += p.ReadBytesPerSec
+		g.WriteBytesPerSec += p.WriteBytesPerSec
+		g.ThreadCount += p.ThreadCount
+		g.HandleCount += p.HandleCount
+		g.Connections += p.Connections
+		for state, n := range p.ConnectionStates {
+			if g.ConnectionStates == nil {
+				g.ConnectionStates = make(map[string]int)
+			}
+			g.ConnectionStates[state] += n
+		}
+
+		// Ranking reuses the process sort keys on the group totals
+		t := totals[k]
+		t.CPUPercent, t.MemoryBytes = g.CPUPercent, g.MemoryBytes
+		t.ReadBytesPerSec, t.WriteBytesPerSec = g.ReadBytesPerSec, g.WriteBytesPerSec
+		t.ThreadCount, t.HandleCount, t.Connections = g.ThreadCount, g.HandleCount, g.Connections
+		t.ConnectionStates = g.ConnectionStates
+	}
+
+	list := make([]models.ProcessGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.PIDs, func(i, j int) bool { return g.PIDs[i] < g.PIDs[j] })
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		vi, vj := value(totals[list[i].Key]), value(totals[list[j].Key])
+		if vi != vj {
+			return vi > vj
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// Options of java, node and python that take the next argument as their value
+var argValueOptions = map[string]bool{
+	"-cp": true, "-classpath": true, "--class-path": true, "-p": true, "--module-path": true,
+	"--add-opens": true, "--add-exports": true, "--add-modules": true, "-r": true, "--require": true,
+	"-W": true, "-X": true,
+}
+
+// processApp returns what a generic host process runs, from its command line:
+// the IIS app pool of w3wp, the main class or jar of a JVM, the assembly of
+// dotnet, or the script of node, python and similar interpreters
+func processApp(p *models.ProcessInfo) string {
+	args := splitCommandLine(p.CommandLine)
+	if len(args) < 2 {
+		return ""
+	}
+	name := strings.TrimSuffix(strings.ToLower(p.Name), ".exe")
+
+	switch {
+	case name == "w3wp":
+		for i := 1; i+1 < len(args); i++ {
+			if args[i] == "-ap" {
+				return args[i+1]
+			}
+		}
+	case name == "java" || name == "javaw":
+		for i := 1; i < len(args); i++ {
+			switch arg := args[i]; {
+			case (arg == "-jar" || arg == "-m" || arg == "--module") && i+1 < len(args):
+				return baseName(args[i+1])
+			case argValueOptions[arg]:
+				i++
+			case !strings.HasPrefix(arg, "-"):
+				return arg
+			}
+		}
+	case name == "dotnet":
+		for _, arg := range args[1:] {
+			if strings.HasSuffix(strings.ToLower(arg), ".dll") {
+				return baseName(arg)
+			}
+		}
+	case name == "node" || strings.HasPrefix(name, "python") || name == "ruby" || name == "php" || name == "perl":
+		for i := 1; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "-m" && i+1 < len(args):
+				return args[i+1]
+			case argValueOptions[arg]:
+				i++
+			case !strings.HasPrefix(arg, "-"):
+				return baseName(arg)
+			}
+		}
+	}
+	return ""
+}
+
+// splitCommandLine splits a command line into arguments at spaces outside
+// double quotes, dropping the quotes
+func splitCommandLine(cmd string) []string {
+	var args []string
+	var arg strings.Builder
+	quoted, started := false, false
+	for _, r := range cmd {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// joinCommandLine joins arguments into a command line, quoting those with
+// spaces so splitCommandLine gives them back
+func joinCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") || arg == "" {
+			arg = `"` + arg + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// baseName returns the last element of a Windows or Unix path
+func baseName(p string) string {
+	return path.Base(strings.ReplaceAll(p, `\`, "/"))
+}
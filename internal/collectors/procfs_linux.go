@@ -0,0 +1,424 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides procfs helpers for Linux collectors
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// procRoot is the procfs mount point
+const procRoot = "/proc"
+
+// readNetStats parses /proc/net/snmp style files: pairs of header and value
+// lines sharing a "Prefix:" (e.g. "Tcp:" or "TcpExt:")
+func readNetStats(path string) (map[string]map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := make(map[string]map[string]uint64)
+	var header []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		prefix := strings.TrimSuffix(fields[0], ":")
+
+		if header == nil || header[0] != fields[0] {
+			header = fields
+			continue
+		}
+
+		values := make(map[string]uint64, len(fields)-1)
+		for i := 1; i < len(fields) && i < len(header); i++ {
+			// Some counters (e.g. Tcp MaxConn) are signed; keep them as zero
+			if v, err := strconv.ParseUint(fields[i], 10, 64); err == nil {
+				values[header[i]] = v
+			}
+		}
+		stats[prefix] = values
+		header = nil
+	}
+
+	return stats, scanner.Err()
+}
+
+// readProcKeyValues parses "key value" files such as /proc/vmstat and
+// "Key: value kB" files such as /proc/meminfo. kB values are returned in bytes.
+func readProcKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = v
+	}
+
+	return values, scanner.Err()
+}
+
+// mountInfo is one line of /proc/self/mountinfo
+type mountInfo struct {
+	device       string // major:minor
+	root         string // path within the filesystem mounted at mountPoint
+	mountPoint   string
+	options      string // per-mount options, e.g. "rw,relatime"
+	fsType       string
+	source       string
+	superOptions string
+}
+
+// readMountInfo lists the mounts of this mount namespace
+func readMountInfo() ([]mountInfo, error) {
+	f, err := os.Open(filepath.Join(procRoot, "self", "mountinfo"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+	defer f.Close()
+
+	var mounts []mountInfo
+
+	// "id parent major:minor root mountpoint options [optional...] - fstype source superoptions"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 6 || len(fields) < sep+4 {
+			continue
+		}
+
+		mounts = append(mounts, mountInfo{
+			device:       fields[2],
+			root:         unescapeMountPath(fields[3]),
+			mountPoint:   unescapeMountPath(fields[4]),
+			options:      fields[5],
+			fsType:       fields[sep+1],
+			source:       fields[sep+2],
+			superOptions: fields[sep+3],
+		})
+	}
+
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space etc.) the
+// kernel uses for whitespace in mountinfo paths
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// readPressure parses a PSI file such as /proc/pressure/memory or a cgroup's
+// memory.pressure:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressure(path string) (*models.PressureMetrics, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pressure := &models.PressureMetrics{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		avg10, avg60, avg300, total := &pressure.SomeAvg10, &pressure.SomeAvg60, &pressure.SomeAvg300, &pressure.SomeTotal
+		if fields[0] == "full" {
+			avg10, avg60, avg300, total = &pressure.FullAvg10, &pressure.FullAvg60, &pressure.FullAvg300, &pressure.FullTotal
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				*avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				*avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				*avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				*total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+
+	return pressure, scanner.Err()
+}
+
+// socketInodePIDs maps socket inodes to their owning PIDs by scanning /proc/<pid>/fd
+func socketInodePIDs() map[uint32]uint32 {
+	owners := make(map[uint32]uint32)
+
+	pids, err := listPIDs()
+	if err != nil {
+		return owners
+	}
+
+	for _, pid := range pids {
+		fdDir := filepath.Join(procRoot, strconv.Itoa(int(pid)), "fd")
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			link, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 32)
+			if err != nil {
+				continue
+			}
+			if _, seen := owners[uint32(inode)]; !seen {
+				owners[uint32(inode)] = pid
+			}
+		}
+	}
+
+	return owners
+}
+
+// listPIDs returns the numeric entries of /proc
+func listPIDs() ([]uint32, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procRoot, err)
+	}
+
+	pids := make([]uint32, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, uint32(pid))
+	}
+	return pids, nil
+}
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat. It is 100
+// on every architecture Go supports.
+const clockTicks = 100
+
+// pidStat is the part of /proc/<pid>/stat (or a task's stat) the collectors use
+type pidStat struct {
+	comm      string
+	state     string
+	ppid      uint32
+	minflt    uint64
+	majflt    uint64
+	utime     uint64 // clock ticks
+	stime     uint64 // clock ticks
+	nice      int
+	threads   uint32
+	processor int    // CPU last run on
+	startTime uint64 // clock ticks since boot
+	rssPages  uint64
+	exitCode  int64 // wait status of a zombie; -1 when unknown
+}
+
+// readPidStat parses a stat file such as /proc/<pid>/stat or
+// /proc/<pid>/task/<tid>/stat
+func readPidStat(path string) (*pidStat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// comm may contain spaces and parentheses, so split at the last ')'
+	line := string(data)
+	open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed %s", path)
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 37 {
+		return nil, fmt.Errorf("malformed %s", path)
+	}
+
+	// fields[0] is field 3 (state) in proc(5)
+	field := func(n int) uint64 {
+		v, _ := strconv.ParseUint(fields[n-3], 10, 64)
+		return v
+	}
+	nice, _ := strconv.Atoi(fields[19-3])
+	// exit_code (Linux 3.5+) reads as 0 without ptrace access to the
+	// process, which would pass for a clean exit
+	exitCode := int64(-1)
+	if len(fields) >= 50 && fields[0] == "Z" && ptraceReadable(filepath.Dir(path)) {
+		exitCode = int64(field(52))
+	}
+	return &pidStat{
+		comm:      line[open+1 : end],
+		state:     fields[0],
+		ppid:      uint32(field(4)),
+		minflt:    field(10),
+		majflt:    field(12),
+		utime:     field(14),
+		stime:     field(15),
+		nice:      int(nice),
+		threads:   uint32(field(20)),
+		startTime: field(22),
+		rssPages:  field(24),
+		processor: int(field(39)),
+		exitCode:  exitCode,
+	}, nil
+}
+
+// capSysPtrace is the CAP_SYS_PTRACE bit of the capability sets
+const capSysPtrace = 19
+
+// hasSysPtrace reports whether this process has CAP_SYS_PTRACE in its
+// effective set
+var hasSysPtrace = sync.OnceValue(func() bool {
+	ids, err := readStatusFields(filepath.Join(procRoot, "self", "status"), "CapEff")
+	if err != nil || len(ids["CapEff"]) == 0 {
+		return false
+	}
+	caps, err := strconv.ParseUint(ids["CapEff"][0], 16, 64)
+	return err == nil && caps&(1<<capSysPtrace) != 0
+})
+
+// ptraceReadable reports whether the kernel grants this process
+// PTRACE_MODE_READ access to the process of a /proc/<pid> directory, which
+// the ptrace-protected fields of its stat need: CAP_SYS_PTRACE, or the same
+// real, effective and saved user and group IDs as ours
+func ptraceReadable(dir string) bool {
+	if hasSysPtrace() {
+		return true
+	}
+	ids, err := readStatusFields(filepath.Join(dir, "status"), "Uid", "Gid")
+	if err != nil {
+		return false
+	}
+	same := func(values []string, id int) bool {
+		if len(values) < 3 {
+			return false
+		}
+		for _, v := range values[:3] {
+			if v != strconv.Itoa(id) {
+				return false
+			}
+		}
+		return true
+	}
+	return same(ids["Uid"], os.Geteuid()) && same(ids["Gid"], os.Getegid())
+}
+
+// readStatusFields returns the values of the given keys of a "Key: value ..."
+// file such as /proc/<pid>/status
+func readStatusFields(path string, keys ...string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string][]string, len(keys))
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		for _, k := range keys {
+			if k == key {
+				values[key] = strings.Fields(rest)
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readPidStatm reads the resident and shared (file-backed and shmem) page
+// counts of /proc/<pid>/statm; resident minus shared is the private
+// anonymous memory
+func readPidStatm(path string) (resident, shared uint64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return 0, 0, fmt.Errorf("malformed %s", path)
+	}
+	resident, _ = strconv.ParseUint(fields[1], 10, 64)
+	shared, _ = strconv.ParseUint(fields[2], 10, 64)
+	return resident, shared, nil
+}
+
+// readOpenFilesLimit returns the soft RLIMIT_NOFILE of a process from
+// /proc/<pid>/limits, 0 when unknown or unlimited
+func readOpenFilesLimit(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) > 0 {
+			v, _ := strconv.ParseUint(fields[0], 10, 64)
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+// Package collectors provides counter helpers shared by collectors
+package collectors
+
+// counterRate converts the delta of a cumulative counter into a per-second
+// rate. A counter that went backwards (reset or wrap) yields zero.
+func counterRate(cur, prev uint64, elapsed float64) float64 {
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / elapsed
+}
@@ -8,8 +8,12 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"loadrunner-diagnosis/internal/models"
@@ -84,24 +88,179 @@ type MIB_TCPTABLE2 struct {
 	Table      [1]MIB_TCPROW2
 }
 
+// TCP_ESTATS_TYPE values for per-connection extended statistics
+const (
+	TcpConnectionEstatsData    = 1
+	TcpConnectionEstatsSndCong = 2
+	TcpConnectionEstatsPath    = 3
+	TcpConnectionEstatsRec     = 5
+	TcpConnectionEstatsObsRec  = 6
+)
+
+// MIB_TCPROW structure used by the extended statistics API
+type MIB_TCPROW struct {
+	State      uint32
+	LocalAddr  uint32
+	LocalPort  uint32
+	RemoteAddr uint32
+	RemotePort uint32
+}
+
+// TCP_ESTATS_RW_v0 enables collection for one statistics type (same layout for every type)
+type TCP_ESTATS_RW_v0 struct {
+	EnableCollection byte
+}
+
+// TCP_ESTATS_DATA_ROD_v0 structure
+type TCP_ESTATS_DATA_ROD_v0 struct {
+	DataBytesOut      uint64
+	DataSegsOut       uint64
+	DataBytesIn       uint64
+	DataSegsIn        uint64
+	SegsOut           uint64
+	SegsIn            uint64
+	SoftErrors        uint32
+	SoftErrorReason   uint32
+	SndUna            uint32
+	SndNxt            uint32
+	SndMax            uint32
+	ThruBytesAcked    uint64
+	RcvNxt            uint32
+	ThruBytesReceived uint64
+}
+
+// TCP_ESTATS_SND_CONG_ROD_v0 structure
+type TCP_ESTATS_SND_CONG_ROD_v0 struct {
+	SndLimTransRwin uint32
+	SndLimTimeRwin  uint32
+	SndLimBytesRwin uintptr
+	SndLimTransCwnd uint32
+	SndLimTimeCwnd  uint32
+	SndLimBytesCwnd uintptr
+	SndLimTransSnd  uint32
+	SndLimTimeSnd   uint32
+	SndLimBytesSnd  uintptr
+	SlowStart       uint32
+	CongAvoid       uint32
+	OtherReductions uint32
+	CurCwnd         uint32
+	MaxSsCwnd       uint32
+	MaxCaCwnd       uint32
+	CurSsthresh     uint32
+	MaxSsthresh     uint32
+	MinSsthresh     uint32
+}
+
+// TCP_ESTATS_PATH_ROD_v0 structure
+type TCP_ESTATS_PATH_ROD_v0 struct {
+	FastRetran            uint32
+	Timeouts              uint32
+	SubsequentTimeouts    uint32
+	CurTimeoutCount       uint32
+	AbruptTimeouts        uint32
+	PktsRetrans           uint32
+	BytesRetrans          uint32
+	DupAcksIn             uint32
+	SacksRcvd             uint32
+	SackBlocksRcvd        uint32
+	CongSignals           uint32
+	PreCongSumCwnd        uint32
+	PreCongSumRtt         uint32
+	PostCongSumRtt        uint32
+	PostCongCountRtt      uint32
+	EcnSignals            uint32
+	EceRcvd               uint32
+	SendStall             uint32
+	QuenchRcvd            uint32
+	RetranThresh          uint32
+	SndDupAckEpisodes     uint32
+	SumBytesReordered     uint32
+	NonRecovDa            uint32
+	NonRecovDaEpisodes    uint32
+	AckAfterFr            uint32
+	DsackDups             uint32
+	SampleRtt             uint32
+	SmoothedRtt           uint32
+	RttVar                uint32
+	MaxRtt                uint32
+	MinRtt                uint32
+	SumRtt                uint32
+	CountRtt              uint32
+	CurRto                uint32
+	MaxRto                uint32
+	MinRto                uint32
+	CurMss                uint32
+	MaxMss                uint32
+	MinMss                uint32
+	SpuriousRtoDetections uint32
+}
+
+// TCP_ESTATS_REC_ROD_v0 structure (local receiver)
+type TCP_ESTATS_REC_ROD_v0 struct {
+	CurRwinSent    uint32
+	MaxRwinSent    uint32
+	MinRwinSent    uint32
+	LimRwin        uint32
+	DupAckEpisodes uint32
+	DupAcksOut     uint32
+	CeRcvd         uint32
+	EcnSent        uint32
+	EcnNoncesRcvd  uint32
+	CurReasmQueue  uint32
+	MaxReasmQueue  uint32
+	CurAppRQueue   uintptr
+	MaxAppRQueue   uintptr
+	WinScaleSent   byte
+}
+
+// TCP_ESTATS_OBS_REC_ROD_v0 structure (remote receiver as observed locally)
+type TCP_ESTATS_OBS_REC_ROD_v0 struct {
+	CurRwinRcvd  uint32
+	MaxRwinRcvd  uint32
+	MinRwinRcvd  uint32
+	WinScaleRcvd byte
+}
+
 var (
-	modiphlpapi           = windows.NewLazySystemDLL("iphlpapi.dll")
-	procGetTcpStatistics  = modiphlpapi.NewProc("GetTcpStatistics")
-	procGetTcpTable2      = modiphlpapi.NewProc("GetTcpTable2")
+	modiphlpapi                    = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetTcpStatistics           = modiphlpapi.NewProc("GetTcpStatistics")
+	procGetTcpTable2               = modiphlpapi.NewProc("GetTcpTable2")
+	procGetPerTcpConnectionEStats  = modiphlpapi.NewProc("GetPerTcpConnectionEStats")
+	procSetPerTcpConnectionEStats  = modiphlpapi.NewProc("SetPerTcpConnectionEStats")
 )
 
+// Statistics types enabled for extended per-connection stats
+var estatsTypes = []uint32{
+	TcpConnectionEstatsData,
+	TcpConnectionEstatsSndCong,
+	TcpConnectionEstatsPath,
+	TcpConnectionEstatsRec,
+	TcpConnectionEstatsObsRec,
+}
+
 // TCPCollector collects TCP connection metrics
 type TCPCollector struct {
 	mu            sync.RWMutex
 	lastStats     *MIB_TCPSTATS
 	lastCollect   int64
 	processNames  map[uint32]string
+	ports         *PortExhaustionCollector
+	tracker       *connectionTracker
+	endpoints     *endpointAggregator
+	listeners     *listenerTracker
+	extendedStats bool
+	estatsEnabled map[MIB_TCPROW]bool
 }
 
 // NewTCPCollector creates a new TCP collector
 func NewTCPCollector() (*TCPCollector, error) {
 	return &TCPCollector{
 		processNames: make(map[uint32]string),
+		ports:        NewPortExhaustionCollector(),
+		tracker:      newConnectionTracker(),
+		endpoints:    newEndpointAggregator(),
+		listeners:    newListenerTracker(),
+		estatsEnabled: make(map[MIB_TCPROW]bool),
 	}, nil
 }
 
@@ -134,8 +293,27 @@ func (c *TCPCollector) Collect(ctx context.Context) (*models.TCPMetrics, error)
 	}
 
 	// Get TCP connection table
-	connections, err := c.getTcpTable()
+	rows, err := c.getTcpRows()
 	if err == nil {
+		connections := c.rowsToConnections(rows)
+
+		// Optional per-connection RTT, cwnd, bytes and retransmits
+		c.mu.Lock()
+		if c.extendedStats {
+			c.fillExtendedStats(rows, connections)
+		}
+		c.mu.Unlock()
+
+		now := time.Now()
+
+		// Diff against the previous table for lifetime and churn (fills connection ages)
+		churn, opened, closed := c.tracker.Update(connections, now)
+		metrics.Churn = churn
+		metrics.TopRetransmitting = topRetransmitting(connections)
+
+		// Keep the table for the remote endpoint view
+		c.endpoints.Update(connections, opened, closed, metrics.Churn.IntervalSeconds, now)
+
 		metrics.Connections = connections
 		metrics.TotalConnections = len(connections)
 
@@ -151,11 +329,22 @@ func (c *TCPCollector) Collect(ctx context.Context) (*models.TCPMetrics, error)
 				metrics.TimeWaitCount++
 			}
 		}
+
+		// Ephemeral port usage and exhaustion forecast
+		metrics.PortExhaustion = c.ports.Analyze(connections)
+
+		// Listeners and SYN queues (Windows exposes no accept queue or overflow counters)
+		metrics.Listeners = c.listeners.Update(connections, nil, nil, now)
 	}
 
 	return metrics, nil
 }
 
+// SetCloseWaitThreshold sets how long a connection may stay in CLOSE_WAIT before it is reported
+func (c *TCPCollector) SetCloseWaitThreshold(d time.Duration) {
+	c.tracker.SetCloseWaitThreshold(d)
+}
+
 // getTcpStatistics retrieves TCP protocol statistics
 func (c *TCPCollector) getTcpStatistics() (*MIB_TCPSTATS, error) {
 	var stats MIB_TCPSTATS
@@ -168,6 +357,15 @@ func (c *TCPCollector) getTcpStatistics() (*MIB_TCPSTATS, error) {
 
 // getTcpTable retrieves the TCP connection table
 func (c *TCPCollector) getTcpTable() ([]models.TCPConnection, error) {
+	rows, err := c.getTcpRows()
+	if err != nil {
+		return nil, err
+	}
+	return c.rowsToConnections(rows), nil
+}
+
+// getTcpRows retrieves the raw TCP connection table rows
+func (c *TCPCollector) getTcpRows() ([]MIB_TCPROW2, error) {
 	// First call to get required buffer size
 	var size uint32
 	procGetTcpTable2.Call(0, uintptr(unsafe.Pointer(&size)), 1)
@@ -192,7 +390,7 @@ func (c *TCPCollector) getTcpTable() ([]models.TCPConnection, error) {
 	table := (*MIB_TCPTABLE2)(unsafe.Pointer(&buf[0]))
 	numEntries := int(table.NumEntries)
 	
-	connections := make([]models.TCPConnection, 0, numEntries)
+	rows := make([]MIB_TCPROW2, 0, numEntries)
 	
 	// Calculate pointer to first entry
 	entries := unsafe.Pointer(&table.Table[0])
@@ -200,7 +398,17 @@ func (c *TCPCollector) getTcpTable() ([]models.TCPConnection, error) {
 
 	for i := 0; i < numEntries; i++ {
 		row := (*MIB_TCPROW2)(unsafe.Pointer(uintptr(entries) + uintptr(i)*entrySize))
-		
+		rows = append(rows, *row)
+	}
+
+	return rows, nil
+}
+
+// rowsToConnections converts raw table rows into connections
+func (c *TCPCollector) rowsToConnections(rows []MIB_TCPROW2) []models.TCPConnection {
+	connections := make([]models.TCPConnection, 0, len(rows))
+
+	for _, row := range rows {
 		conn := models.TCPConnection{
 			LocalAddress:  c.ipToString(row.LocalAddr),
 			LocalPort:     c.portToHost(row.LocalPort),
@@ -213,7 +421,123 @@ func (c *TCPCollector) getTcpTable() ([]models.TCPConnection, error) {
 		connections = append(connections, conn)
 	}
 
-	return connections, nil
+	return connections
+}
+
+// SetExtendedStats enables or disables per-connection extended statistics
+func (c *TCPCollector) SetExtendedStats(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extendedStats = enabled
+	if !enabled {
+		c.estatsEnabled = make(map[MIB_TCPROW]bool)
+	}
+}
+
+// fillExtendedStats attaches extended statistics to established connections.
+// Collection is enabled per connection on first sight (requires Administrator),
+// so a new connection reports stats from the following sample on.
+func (c *TCPCollector) fillExtendedStats(rows []MIB_TCPROW2, connections []models.TCPConnection) {
+	current := make(map[MIB_TCPROW]bool, len(rows))
+
+	for i, row := range rows {
+		if row.State != MIB_TCP_STATE_ESTAB {
+			continue
+		}
+		key := MIB_TCPROW{
+			State:      row.State,
+			LocalAddr:  row.LocalAddr,
+			LocalPort:  row.LocalPort,
+			RemoteAddr: row.RemoteAddr,
+			RemotePort: row.RemotePort,
+		}
+
+		if !c.estatsEnabled[key] {
+			if err := c.enableEStats(&key); err != nil {
+				continue
+			}
+		}
+		current[key] = true
+
+		if stats, err := c.getEStats(&key); err == nil {
+			connections[i].Stats = stats
+		}
+	}
+
+	c.estatsEnabled = current
+}
+
+// enableEStats turns on extended statistics collection for a connection
+func (c *TCPCollector) enableEStats(row *MIB_TCPROW) error {
+	rw := TCP_ESTATS_RW_v0{EnableCollection: 1}
+	for _, estatsType := range estatsTypes {
+		ret, _, _ := procSetPerTcpConnectionEStats.Call(
+			uintptr(unsafe.Pointer(row)),
+			uintptr(estatsType),
+			uintptr(unsafe.Pointer(&rw)),
+			0,
+			unsafe.Sizeof(rw),
+			0,
+		)
+		if ret != 0 {
+			return fmt.Errorf("SetPerTcpConnectionEStats failed: %d", ret)
+		}
+	}
+	return nil
+}
+
+// getEStats reads extended statistics for a connection
+func (c *TCPCollector) getEStats(row *MIB_TCPROW) (*models.TCPConnectionStats, error) {
+	var data TCP_ESTATS_DATA_ROD_v0
+	var cong TCP_ESTATS_SND_CONG_ROD_v0
+	var path TCP_ESTATS_PATH_ROD_v0
+	var rec TCP_ESTATS_REC_ROD_v0
+	var obsRec TCP_ESTATS_OBS_REC_ROD_v0
+
+	if err := c.readEStats(row, TcpConnectionEstatsData, unsafe.Pointer(&data), unsafe.Sizeof(data)); err != nil {
+		return nil, err
+	}
+	if err := c.readEStats(row, TcpConnectionEstatsSndCong, unsafe.Pointer(&cong), unsafe.Sizeof(cong)); err != nil {
+		return nil, err
+	}
+	if err := c.readEStats(row, TcpConnectionEstatsPath, unsafe.Pointer(&path), unsafe.Sizeof(path)); err != nil {
+		return nil, err
+	}
+	if err := c.readEStats(row, TcpConnectionEstatsRec, unsafe.Pointer(&rec), unsafe.Sizeof(rec)); err != nil {
+		return nil, err
+	}
+	if err := c.readEStats(row, TcpConnectionEstatsObsRec, unsafe.Pointer(&obsRec), unsafe.Sizeof(obsRec)); err != nil {
+		return nil, err
+	}
+
+	return &models.TCPConnectionStats{
+		SmoothedRTT:           float64(path.SmoothedRtt),
+		RTTVariance:           float64(path.RttVar),
+		CongestionWindow:      uint64(cong.CurCwnd),
+		BytesIn:               data.DataBytesIn,
+		BytesOut:              data.DataBytesOut,
+		SegmentsRetransmitted: uint64(path.PktsRetrans),
+		BytesRetransmitted:    uint64(path.BytesRetrans),
+		ReceiveWindow:         uint64(rec.CurRwinSent),
+		SendWindow:            uint64(obsRec.CurRwinRcvd),
+	}, nil
+}
+
+// readEStats reads the read-only dynamic (ROD) block of one statistics type
+func (c *TCPCollector) readEStats(row *MIB_TCPROW, estatsType uint32, rod unsafe.Pointer, rodSize uintptr) error {
+	ret, _, _ := procGetPerTcpConnectionEStats.Call(
+		uintptr(unsafe.Pointer(row)),
+		uintptr(estatsType),
+		0, 0, 0, // Rw
+		0, 0, 0, // Ros
+		uintptr(rod),
+		0,
+		rodSize,
+	)
+	if ret != 0 {
+		return fmt.Errorf("GetPerTcpConnectionEStats(%d) failed: %d", estatsType, ret)
+	}
+	return nil
 }
 
 // ipToString converts a uint32 IP to dotted string
@@ -231,6 +555,48 @@ func (c *TCPCollector) portToHost(port uint32) uint16 {
 	return uint16((port&0xFF)<<8 | (port&0xFF00)>>8)
 }
 
+// dynamicPortPattern matches the numeric values in netsh dynamicport output
+var dynamicPortPattern = regexp.MustCompile(`:\s*(\d+)`)
+
+// getDynamicPortRange reads the configured TCP dynamic port range via netsh
+func getDynamicPortRange() (uint16, int, error) {
+	out, err := exec.Command("netsh", "int", "ipv4", "show", "dynamicport", "tcp").Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("netsh dynamicport failed: %w", err)
+	}
+
+	// Output lists "Start Port : N" then "Number of Ports : N" (labels are localized)
+	matches := dynamicPortPattern.FindAllStringSubmatch(string(out), 2)
+	if len(matches) < 2 {
+		return 0, 0, fmt.Errorf("unexpected netsh output: %q", out)
+	}
+	start, err := strconv.ParseUint(matches[0][1], 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := strconv.Atoi(matches[1][1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint16(start), count, nil
+}
+
+// GetEndpoints aggregates the latest connection table by remote endpoint. A
+// new table is collected when there is none or it is older than maxAge; a
+// maxAge of zero always collects. An invalid grouping returns
+// ErrInvalidEndpointGroup before anything is collected.
+func (c *TCPCollector) GetEndpoints(ctx context.Context, groupBy string, cidrs []*net.IPNet, maxAge time.Duration) (*models.TCPEndpointMetrics, error) {
+	if _, err := validateEndpointGroup(groupBy, cidrs); err != nil {
+		return nil, err
+	}
+	if at := c.endpoints.SampledAt(); at.IsZero() || time.Since(at) > maxAge {
+		if _, err := c.Collect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.endpoints.Aggregate(ctx, groupBy, cidrs)
+}
+
 // GetConnectionsByState returns connections filtered by state
 func (c *TCPCollector) GetConnectionsByState(ctx context.Context, state string) ([]models.TCPConnection, error) {
 	all, err := c.getTcpTable()
@@ -0,0 +1,290 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides TCP connection metrics collection for Linux
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+
+	"golang.org/x/sys/unix"
+)
+
+// TCPCollector collects TCP connection metrics
+type TCPCollector struct {
+	mu            sync.RWMutex
+	ports         *PortExhaustionCollector
+	tracker       *connectionTracker
+	endpoints     *endpointAggregator
+	listeners     *listenerTracker
+	extendedStats bool
+}
+
+// NewTCPCollector creates a new TCP collector
+func NewTCPCollector() (*TCPCollector, error) {
+	return &TCPCollector{
+		ports:     NewPortExhaustionCollector(),
+		tracker:   newConnectionTracker(),
+		endpoints: newEndpointAggregator(),
+		listeners: newListenerTracker(),
+	}, nil
+}
+
+// Name returns the collector name
+func (c *TCPCollector) Name() string {
+	return "tcp"
+}
+
+// Collect gathers TCP metrics
+func (c *TCPCollector) Collect(ctx context.Context) (*models.TCPMetrics, error) {
+	metrics := &models.TCPMetrics{
+		ConnectionStates: make(map[string]int),
+	}
+
+	// Get TCP statistics
+	if snmp, err := readNetStats(filepath.Join(procRoot, "net", "snmp")); err == nil {
+		stats := snmp["Tcp"]
+		metrics.SegmentsSent = stats["OutSegs"]
+		metrics.SegmentsReceived = stats["InSegs"]
+		metrics.SegmentsRetransmitted = stats["RetransSegs"]
+		metrics.ActiveOpens = stats["ActiveOpens"]
+		metrics.PassiveOpens = stats["PassiveOpens"]
+		metrics.ConnectionFailures = stats["AttemptFails"]
+		metrics.ConnectionsReset = stats["EstabResets"]
+
+		// Calculate retransmission rate
+		if stats["OutSegs"] > 0 {
+			metrics.RetransmissionRate = float64(stats["RetransSegs"]) / float64(stats["OutSegs"]) * 100
+		}
+	}
+
+	c.mu.RLock()
+	extended := c.extendedStats
+	c.mu.RUnlock()
+
+	// Get TCP connection table
+	sockets, err := c.getSockets(extended)
+	if err == nil {
+		connections := c.socketsToConnections(sockets)
+		now := time.Now()
+
+		// Diff against the previous table for lifetime and churn (fills connection ages)
+		churn, opened, closed := c.tracker.Update(connections, now)
+		metrics.Churn = churn
+		metrics.TopRetransmitting = topRetransmitting(connections)
+
+		// Keep the table for the remote endpoint view
+		c.endpoints.Update(connections, opened, closed, metrics.Churn.IntervalSeconds, now)
+
+		metrics.Connections = connections
+		metrics.TotalConnections = len(connections)
+
+		// Count connection states
+		for _, conn := range connections {
+			metrics.ConnectionStates[conn.State]++
+
+			// Count specific problematic states
+			switch conn.State {
+			case "CLOSE_WAIT":
+				metrics.CloseWaitCount++
+			case "TIME_WAIT":
+				metrics.TimeWaitCount++
+			}
+		}
+
+		// Ephemeral port usage and exhaustion forecast
+		metrics.PortExhaustion = c.ports.Analyze(connections)
+
+		// Accept queues, SYN queues and listen overflows
+		metrics.Listeners = c.listeners.Update(connections, listenQueues(sockets, connections), readListenStats(), now)
+	}
+
+	return metrics, nil
+}
+
+// SetCloseWaitThreshold sets how long a connection may stay in CLOSE_WAIT before it is reported
+func (c *TCPCollector) SetCloseWaitThreshold(d time.Duration) {
+	c.tracker.SetCloseWaitThreshold(d)
+}
+
+// SetExtendedStats enables or disables per-connection extended statistics
+func (c *TCPCollector) SetExtendedStats(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extendedStats = enabled
+}
+
+// getTcpTable retrieves the TCP connection table
+func (c *TCPCollector) getTcpTable() ([]models.TCPConnection, error) {
+	sockets, err := c.getSockets(false)
+	if err != nil {
+		return nil, err
+	}
+	return c.socketsToConnections(sockets), nil
+}
+
+// getSockets dumps IPv4 and IPv6 sockets via inet_diag, optionally with tcp_info
+func (c *TCPCollector) getSockets(withInfo bool) ([]inetDiagSocket, error) {
+	sockets, err := dumpTCPSockets(unix.AF_INET, withInfo)
+	if err != nil {
+		return nil, err
+	}
+	// IPv6 may be disabled; IPv4 results are still usable
+	if v6, err := dumpTCPSockets(unix.AF_INET6, withInfo); err == nil {
+		sockets = append(sockets, v6...)
+	}
+	return sockets, nil
+}
+
+// socketsToConnections converts inet_diag sockets into connections
+func (c *TCPCollector) socketsToConnections(sockets []inetDiagSocket) []models.TCPConnection {
+	owners := socketInodePIDs()
+	connections := make([]models.TCPConnection, 0, len(sockets))
+
+	for _, sock := range sockets {
+		conn := models.TCPConnection{
+			LocalAddress:  sock.LocalAddress,
+			LocalPort:     sock.LocalPort,
+			RemoteAddress: sock.RemoteAddress,
+			RemotePort:    sock.RemotePort,
+			State:         linuxTCPStateNames[sock.State],
+			PID:           owners[sock.Inode],
+		}
+		if sock.Info != nil && sock.State == TCP_ESTABLISHED {
+			conn.Stats = tcpInfoToStats(sock.Info)
+		}
+
+		connections = append(connections, conn)
+	}
+
+	return connections
+}
+
+// listenQueues returns the accept queue and backlog of listening sockets.
+// connections is the table built from sockets, in the same order, and gives
+// the owning PIDs. SO_REUSEPORT sockets of one process are added up.
+func listenQueues(sockets []inetDiagSocket, connections []models.TCPConnection) map[listenerKey]listenQueue {
+	queues := make(map[listenerKey]listenQueue)
+	for i, sock := range sockets {
+		if sock.State == TCP_LISTEN {
+			key := listenerKey{sock.LocalAddress, sock.LocalPort, connections[i].PID}
+			q := queues[key]
+			q.accept += sock.RQueue
+			q.backlog += sock.WQueue
+			queues[key] = q
+		}
+	}
+	return queues
+}
+
+// readListenStats reads listen overflow counters and the SYN backlog limit
+func readListenStats() *listenStats {
+	netstat, err := readNetStats(filepath.Join(procRoot, "net", "netstat"))
+	if err != nil {
+		return nil
+	}
+
+	stats := &listenStats{
+		overflows: netstat["TcpExt"]["ListenOverflows"],
+		drops:     netstat["TcpExt"]["ListenDrops"],
+	}
+	if data, err := os.ReadFile(filepath.Join(procRoot, "sys", "net", "ipv4", "tcp_max_syn_backlog")); err == nil {
+		if limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32); err == nil {
+			stats.maxSynBacklog = uint32(limit)
+		}
+	}
+	return stats
+}
+
+// tcpInfoToStats maps kernel tcp_info onto extended connection statistics
+func tcpInfoToStats(info *unix.TCPInfo) *models.TCPConnectionStats {
+	stats := &models.TCPConnectionStats{
+		SmoothedRTT:           float64(info.Rtt) / 1000,
+		RTTVariance:           float64(info.Rttvar) / 1000,
+		CongestionWindow:      uint64(info.Snd_cwnd) * uint64(info.Snd_mss),
+		BytesIn:               info.Bytes_received,
+		BytesOut:              info.Bytes_sent,
+		SegmentsRetransmitted: uint64(info.Total_retrans),
+		BytesRetransmitted:    info.Bytes_retrans,
+		ReceiveWindow:         uint64(info.Rcv_wnd),
+		SendWindow:            uint64(info.Snd_wnd),
+	}
+
+	// Older kernels lack bytes_sent and rcv_wnd
+	if stats.BytesOut == 0 {
+		stats.BytesOut = info.Bytes_acked
+	}
+	if stats.ReceiveWindow == 0 {
+		stats.ReceiveWindow = uint64(info.Rcv_space)
+	}
+	return stats
+}
+
+// getDynamicPortRange reads the ephemeral port range from ip_local_port_range
+func getDynamicPortRange() (uint16, int, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys", "net", "ipv4", "ip_local_port_range"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("unexpected ip_local_port_range: %q", data)
+	}
+	start, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint16(start), int(end-start) + 1, nil
+}
+
+// GetEndpoints aggregates the latest connection table by remote endpoint. A
+// new table is collected when there is none or it is older than maxAge; a
+// maxAge of zero always collects. An invalid grouping returns
+// ErrInvalidEndpointGroup before anything is collected.
+func (c *TCPCollector) GetEndpoints(ctx context.Context, groupBy string, cidrs []*net.IPNet, maxAge time.Duration) (*models.TCPEndpointMetrics, error) {
+	if _, err := validateEndpointGroup(groupBy, cidrs); err != nil {
+		return nil, err
+	}
+	if at := c.endpoints.SampledAt(); at.IsZero() || time.Since(at) > maxAge {
+		if _, err := c.Collect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.endpoints.Aggregate(ctx, groupBy, cidrs)
+}
+
+// GetConnectionsByState returns connections filtered by state
+func (c *TCPCollector) GetConnectionsByState(ctx context.Context, state string) ([]models.TCPConnection, error) {
+	all, err := c.getTcpTable()
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []models.TCPConnection
+	for _, conn := range all {
+		if conn.State == state {
+			filtered = append(filtered, conn)
+		}
+	}
+	return filtered, nil
+}
+
+// GetCloseWaitConnections returns connections stuck in CLOSE_WAIT (unclosed)
+func (c *TCPCollector) GetCloseWaitConnections(ctx context.Context) ([]models.TCPConnection, error) {
+	return c.GetConnectionsByState(ctx, "CLOSE_WAIT")
+}
@@ -0,0 +1,247 @@
+// Package collectors provides TCP connection lifetime and churn tracking
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Default time a connection may sit in CLOSE_WAIT before it is reported
+const defaultCloseWaitThreshold = 60 * time.Second
+
+// Connection age histogram bucket upper bounds (last bucket is unbounded)
+var connectionAgeBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"<10s", 10 * time.Second},
+	{"10s-1m", time.Minute},
+	{"1m-5m", 5 * time.Minute},
+	{"5m-30m", 30 * time.Minute},
+	{">30m", 0},
+}
+
+const maxEndpointChurn = 10
+
+// connectionTracker diffs successive connection tables keyed by 4-tuple and PID
+type connectionTracker struct {
+	mu                 sync.Mutex
+	closeWaitThreshold time.Duration
+	seen               map[connKey]*connState
+	timeWait           map[tupleKey]bool // TIME_WAIT rows of the previous table
+	lastUpdate         time.Time
+}
+
+type connKey struct {
+	localAddress  string
+	localPort     uint16
+	remoteAddress string
+	remotePort    uint16
+	pid           uint32
+}
+
+// tupleKey is a connection 4-tuple without the owner, which TIME_WAIT rows lack
+type tupleKey struct {
+	localAddress  string
+	localPort     uint16
+	remoteAddress string
+	remotePort    uint16
+}
+
+type connState struct {
+	firstSeen   time.Time
+	state       string
+	stateSince  time.Time
+	preexisting bool // already open when tracking started, age is a lower bound
+}
+
+type endpointKey struct {
+	address string
+	port    uint16
+}
+
+// newConnectionTracker creates a new connection tracker
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		closeWaitThreshold: defaultCloseWaitThreshold,
+		seen:               make(map[connKey]*connState),
+		timeWait:           make(map[tupleKey]bool),
+	}
+}
+
+// SetCloseWaitThreshold sets how long a connection may stay in CLOSE_WAIT before it is reported
+func (t *connectionTracker) SetCloseWaitThreshold(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d > 0 {
+		t.closeWaitThreshold = d
+	}
+}
+
+// Update diffs the table against the previous one, fills each connection's
+// age and returns churn metrics for the interval with the connections opened
+// and closed in it, all from one critical section. A connection opened and
+// closed between two samples only shows up as a new TIME_WAIT row, which is
+// counted as both an open and a close.
+func (t *connectionTracker) Update(connections []models.TCPConnection, now time.Time) (metrics *models.TCPChurnMetrics, opened, closed []connKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics = &models.TCPChurnMetrics{
+		CloseWaitThreshold: t.closeWaitThreshold.Seconds(),
+		AgeHistogram:       make([]models.AgeBucket, len(connectionAgeBuckets)),
+	}
+	for i, b := range connectionAgeBuckets {
+		metrics.AgeHistogram[i] = models.AgeBucket{Label: b.label, MaxSeconds: b.max.Seconds()}
+	}
+
+	baseline := t.lastUpdate.IsZero()
+	if !baseline {
+		metrics.IntervalSeconds = now.Sub(t.lastUpdate).Seconds()
+	}
+
+	churn := make(map[endpointKey]*models.EndpointChurn)
+	current := make(map[connKey]*connState, len(connections))
+	timeWait := make(map[tupleKey]bool)
+	var newTimeWait []tupleKey
+
+	for i := range connections {
+		conn := &connections[i]
+		if conn.State == "TIME_WAIT" {
+			tuple := tupleKey{conn.LocalAddress, conn.LocalPort, conn.RemoteAddress, conn.RemotePort}
+			timeWait[tuple] = true
+			if !baseline && !t.timeWait[tuple] {
+				newTimeWait = append(newTimeWait, tuple)
+			}
+			continue
+		}
+		if !isLiveConnection(conn.State) {
+			continue
+		}
+
+		key := connKey{conn.LocalAddress, conn.LocalPort, conn.RemoteAddress, conn.RemotePort, conn.PID}
+		st, ok := t.seen[key]
+		if !ok {
+			st = &connState{firstSeen: now, state: conn.State, stateSince: now, preexisting: baseline}
+			if !baseline {
+				metrics.Opened++
+				opened = append(opened, key)
+				endpointChurn(churn, conn.RemoteAddress, conn.RemotePort).Opened++
+			}
+		} else if st.state != conn.State {
+			st.state = conn.State
+			st.stateSince = now
+		}
+		current[key] = st
+
+		age := now.Sub(st.firstSeen)
+		conn.AgeSeconds = age.Seconds()
+		conn.StateSeconds = now.Sub(st.stateSince).Seconds()
+		if st.preexisting {
+			metrics.PreexistingConnections++
+		}
+		metrics.AgeHistogram[ageBucket(age)].Count++
+
+		if conn.State == "CLOSE_WAIT" && now.Sub(st.stateSince) >= t.closeWaitThreshold {
+			metrics.LongCloseWait = append(metrics.LongCloseWait, *conn)
+		}
+	}
+
+	// Anything live last time and gone now (or moved to TIME_WAIT) was closed
+	wasLive := make(map[tupleKey]bool, len(t.seen))
+	for key := range t.seen {
+		wasLive[tupleKey{key.localAddress, key.localPort, key.remoteAddress, key.remotePort}] = true
+		if _, ok := current[key]; !ok {
+			metrics.Closed++
+			closed = append(closed, key)
+			endpointChurn(churn, key.remoteAddress, key.remotePort).Closed++
+		}
+	}
+
+	// A new TIME_WAIT row that was not live last time opened and closed within
+	// the interval. It has no PID, so it is only attributed to its endpoint.
+	for _, tuple := range newTimeWait {
+		if wasLive[tuple] {
+			continue
+		}
+		key := connKey{tuple.localAddress, tuple.localPort, tuple.remoteAddress, tuple.remotePort, 0}
+		metrics.Opened++
+		metrics.Closed++
+		metrics.ShortLived++
+		opened = append(opened, key)
+		closed = append(closed, key)
+		ec := endpointChurn(churn, tuple.remoteAddress, tuple.remotePort)
+		ec.Opened++
+		ec.Closed++
+		ec.ShortLived++
+	}
+
+	if metrics.IntervalSeconds > 0 {
+		metrics.OpenedPerSec = float64(metrics.Opened) / metrics.IntervalSeconds
+		metrics.ClosedPerSec = float64(metrics.Closed) / metrics.IntervalSeconds
+	}
+
+	for _, ec := range churn {
+		if metrics.IntervalSeconds > 0 {
+			ec.OpenedPerSec = float64(ec.Opened) / metrics.IntervalSeconds
+			ec.ClosedPerSec = float64(ec.Closed) / metrics.IntervalSeconds
+		}
+		metrics.EndpointChurn = append(metrics.EndpointChurn, *ec)
+	}
+	sort.Slice(metrics.EndpointChurn, func(i, j int) bool {
+		return metrics.EndpointChurn[i].Opened+metrics.EndpointChurn[i].Closed >
+			metrics.EndpointChurn[j].Opened+metrics.EndpointChurn[j].Closed
+	})
+	if len(metrics.EndpointChurn) > maxEndpointChurn {
+		metrics.EndpointChurn = metrics.EndpointChurn[:maxEndpointChurn]
+	}
+
+	// Oldest CLOSE_WAIT first
+	sort.Slice(metrics.LongCloseWait, func(i, j int) bool {
+		return metrics.LongCloseWait[i].StateSeconds > metrics.LongCloseWait[j].StateSeconds
+	})
+
+	t.seen = current
+	t.timeWait = timeWait
+	t.lastUpdate = now
+	return metrics, opened, closed
+}
+
+// isLiveConnection reports whether a state represents an open connection.
+// TIME_WAIT rows lose their owning PID, so they are tracked as closed ones.
+func isLiveConnection(state string) bool {
+	switch state {
+	case "", "LISTEN", "TIME_WAIT", "CLOSED", "DELETE_TCB":
+		return false
+	}
+	return true
+}
+
+// ageBucket returns the histogram bucket index for a connection age
+func ageBucket(age time.Duration) int {
+	for i, b := range connectionAgeBuckets {
+		if b.max == 0 || age < b.max {
+			return i
+		}
+	}
+	return len(connectionAgeBuckets) - 1
+}
+
+// endpointChurn returns the churn entry for a remote endpoint, creating it if needed
+func endpointChurn(churn map[endpointKey]*models.EndpointChurn, address string, port uint16) *models.EndpointChurn {
+	key := endpointKey{address, port}
+	ec, ok := churn[key]
+	if !ok {
+		ec = &models.EndpointChurn{
+			Endpoint:      fmt.Sprintf("%s:%d", address, port),
+			RemoteAddress: address,
+			RemotePort:    port,
+		}
+		churn[key] = ec
+	}
+	return ec
+}
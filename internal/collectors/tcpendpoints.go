@@ -0,0 +1,279 @@
+// Package collectors provides remote endpoint aggregation of the TCP connection table
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Endpoint grouping modes
+const (
+	EndpointGroupEndpoint = "endpoint" // remote address:port
+	EndpointGroupAddress  = "address"  // remote address
+	EndpointGroupHost     = "host"     // reverse-resolved hostname
+	EndpointGroupCIDR     = "cidr"     // first matching configured CIDR
+)
+
+const (
+	maxHostLookups    = 32 // new reverse lookups per request, the rest resolve on later requests
+	hostLookupTimeout = 2 * time.Second
+	otherCIDRGroup    = "other"
+)
+
+// ErrInvalidEndpointGroup is returned for an unknown grouping or a CIDR
+// grouping without CIDRs
+var ErrInvalidEndpointGroup = errors.New("invalid endpoint grouping")
+
+// endpointAggregator keeps the latest connection table and churn for endpoint views
+type endpointAggregator struct {
+	mu          sync.Mutex
+	connections []models.TCPConnection
+	opened      []connKey
+	closed      []connKey
+	interval    float64
+	sampledAt   time.Time         // when the table was collected, zero before the first one
+	hostnames   map[string]string // reverse lookup cache, unresolved addresses map to themselves
+}
+
+// newEndpointAggregator creates a new endpoint aggregator
+func newEndpointAggregator() *endpointAggregator {
+	return &endpointAggregator{
+		hostnames: make(map[string]string),
+	}
+}
+
+// Update stores the latest connection table and the connections opened and closed since the previous one
+func (a *endpointAggregator) Update(connections []models.TCPConnection, opened, closed []connKey, interval float64, at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.connections = connections
+	a.opened = opened
+	a.closed = closed
+	a.interval = interval
+	a.sampledAt = at
+}
+
+// SampledAt returns when the stored connection table was collected, zero when
+// there is none
+func (a *endpointAggregator) SampledAt() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sampledAt
+}
+
+// validateEndpointGroup checks a grouping and returns it with the default filled in
+func validateEndpointGroup(groupBy string, cidrs []*net.IPNet) (string, error) {
+	if groupBy == "" {
+		groupBy = EndpointGroupEndpoint
+	}
+	switch groupBy {
+	case EndpointGroupEndpoint, EndpointGroupAddress, EndpointGroupHost:
+	case EndpointGroupCIDR:
+		if len(cidrs) == 0 {
+			return "", fmt.Errorf("%w: groupBy %q requires at least one CIDR", ErrInvalidEndpointGroup, groupBy)
+		}
+	default:
+		return "", fmt.Errorf("%w: unknown groupBy %q", ErrInvalidEndpointGroup, groupBy)
+	}
+	return groupBy, nil
+}
+
+// Aggregate groups the latest connection table by remote endpoint
+func (a *endpointAggregator) Aggregate(ctx context.Context, groupBy string, cidrs []*net.IPNet) (*models.TCPEndpointMetrics, error) {
+	groupBy, err := validateEndpointGroup(groupBy, cidrs)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	connections, opened, closed, interval, sampledAt := a.connections, a.opened, a.closed, a.interval, a.sampledAt
+	a.mu.Unlock()
+
+	var hosts map[string]string
+	if groupBy == EndpointGroupHost {
+		hosts = a.resolve(ctx, connections, closed)
+	}
+
+	groupKey := func(address string, port uint16) string {
+		switch groupBy {
+		case EndpointGroupAddress:
+			return address
+		case EndpointGroupHost:
+			return hosts[address]
+		case EndpointGroupCIDR:
+			if ip := net.ParseIP(address); ip != nil {
+				for _, cidr := range cidrs {
+					if cidr.Contains(ip) {
+						return cidr.String()
+					}
+				}
+			}
+			return otherCIDRGroup
+		}
+		return net.JoinHostPort(address, strconv.Itoa(int(port)))
+	}
+
+	groups := make(map[string]*endpointGroup)
+	group := func(key string) *endpointGroup {
+		g, ok := groups[key]
+		if !ok {
+			g = &endpointGroup{
+				endpoint:  models.TCPEndpoint{Endpoint: key, States: make(map[string]int)},
+				addresses: make(map[string]bool),
+				pids:      make(map[uint32]int),
+			}
+			groups[key] = g
+		}
+		return g
+	}
+
+	for _, conn := range connections {
+		if !hasRemoteEndpoint(conn.RemoteAddress, conn.RemotePort) {
+			continue
+		}
+		g := group(groupKey(conn.RemoteAddress, conn.RemotePort))
+		g.endpoint.Connections++
+		g.endpoint.States[conn.State]++
+		g.addresses[conn.RemoteAddress] = true
+		if conn.PID != 0 {
+			g.pids[conn.PID]++
+		}
+	}
+	for _, key := range opened {
+		if hasRemoteEndpoint(key.remoteAddress, key.remotePort) {
+			group(groupKey(key.remoteAddress, key.remotePort)).endpoint.Opened++
+		}
+	}
+	for _, key := range closed {
+		if hasRemoteEndpoint(key.remoteAddress, key.remotePort) {
+			group(groupKey(key.remoteAddress, key.remotePort)).endpoint.Closed++
+		}
+	}
+
+	metrics := &models.TCPEndpointMetrics{
+		GroupBy:         groupBy,
+		SampledAt:       sampledAt,
+		IntervalSeconds: interval,
+		Endpoints:       make([]models.TCPEndpoint, 0, len(groups)),
+	}
+
+	for _, g := range groups {
+		ep := g.endpoint
+		if interval > 0 {
+			ep.OpenedPerSec = float64(ep.Opened) / interval
+			ep.ClosedPerSec = float64(ep.Closed) / interval
+		}
+		if groupBy == EndpointGroupHost || groupBy == EndpointGroupCIDR {
+			for address := range g.addresses {
+				ep.RemoteAddresses = append(ep.RemoteAddresses, address)
+			}
+			sort.Strings(ep.RemoteAddresses)
+		}
+		for pid, count := range g.pids {
+			ep.Processes = append(ep.Processes, models.EndpointProcess{PID: pid, Connections: count})
+		}
+		sort.Slice(ep.Processes, func(i, j int) bool {
+			return ep.Processes[i].Connections > ep.Processes[j].Connections
+		})
+		metrics.Endpoints = append(metrics.Endpoints, ep)
+	}
+
+	// Busiest endpoints first
+	sort.Slice(metrics.Endpoints, func(i, j int) bool {
+		x, y := metrics.Endpoints[i], metrics.Endpoints[j]
+		if x.Connections != y.Connections {
+			return x.Connections > y.Connections
+		}
+		return x.Opened+x.Closed > y.Opened+y.Closed
+	})
+
+	return metrics, nil
+}
+
+// endpointGroup accumulates one aggregated endpoint
+type endpointGroup struct {
+	endpoint  models.TCPEndpoint
+	addresses map[string]bool
+	pids      map[uint32]int
+}
+
+// resolve reverse-resolves remote addresses, using and filling the hostname cache
+func (a *endpointAggregator) resolve(ctx context.Context, connections []models.TCPConnection, closed []connKey) map[string]string {
+	addresses := make(map[string]bool)
+	for _, conn := range connections {
+		if hasRemoteEndpoint(conn.RemoteAddress, conn.RemotePort) {
+			addresses[conn.RemoteAddress] = true
+		}
+	}
+	for _, key := range closed {
+		if hasRemoteEndpoint(key.remoteAddress, key.remotePort) {
+			addresses[key.remoteAddress] = true
+		}
+	}
+
+	hosts := make(map[string]string, len(addresses))
+	var pending []string
+
+	a.mu.Lock()
+	for address := range addresses {
+		if name, ok := a.hostnames[address]; ok {
+			hosts[address] = name
+		} else if len(pending) < maxHostLookups {
+			pending = append(pending, address)
+		} else {
+			hosts[address] = address
+		}
+	}
+	a.mu.Unlock()
+
+	if len(pending) == 0 {
+		return hosts
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hostLookupTimeout)
+	defer cancel()
+
+	names := make([]string, len(pending))
+	var wg sync.WaitGroup
+	for i, address := range pending {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			names[i] = address
+			if found, err := net.DefaultResolver.LookupAddr(ctx, address); err == nil && len(found) > 0 {
+				names[i] = strings.TrimSuffix(found[0], ".")
+			}
+		}(i, address)
+	}
+	wg.Wait()
+
+	a.mu.Lock()
+	for i, address := range pending {
+		hosts[address] = names[i]
+		// Cancelled lookups are retried on the next request
+		if ctx.Err() == nil || names[i] != address {
+			a.hostnames[address] = names[i]
+		}
+	}
+	a.mu.Unlock()
+
+	return hosts
+}
+
+// hasRemoteEndpoint reports whether a connection has a real remote endpoint (not a listener)
+func hasRemoteEndpoint(address string, port uint16) bool {
+	if port == 0 {
+		return false
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && !ip.IsUnspecified()
+}
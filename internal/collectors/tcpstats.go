@@ -0,0 +1,28 @@
+// Package collectors provides helpers for extended TCP connection statistics
+package collectors
+
+import (
+	"sort"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+const maxTopRetransmitting = 10
+
+// topRetransmitting returns the connections with the most retransmitted segments
+func topRetransmitting(connections []models.TCPConnection) []models.TCPConnection {
+	var top []models.TCPConnection
+	for _, conn := range connections {
+		if conn.Stats != nil && conn.Stats.SegmentsRetransmitted > 0 {
+			top = append(top, conn)
+		}
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].Stats.SegmentsRetransmitted > top[j].Stats.SegmentsRetransmitted
+	})
+	if len(top) > maxTopRetransmitting {
+		top = top[:maxTopRetransmitting]
+	}
+	return top
+}
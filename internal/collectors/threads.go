@@ -0,0 +1,160 @@
+//go:build windows
+// +build windows
+
+// Package collectors provides per-thread CPU sampling of a process
+package collectors
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// STATUS_INFO_LENGTH_MISMATCH is returned when the buffer is too small
+const STATUS_INFO_LENGTH_MISMATCH = 0xC0000004
+
+// SYSTEM_THREAD_INFORMATION structure (times in 100ns units). An array of
+// them follows each SYSTEM_PROCESS_INFORMATION.
+type SYSTEM_THREAD_INFORMATION struct {
+	KernelTime      int64
+	UserTime        int64
+	CreateTime      int64
+	WaitTime        uint32
+	StartAddress    uintptr
+	UniqueProcess   uintptr // CLIENT_ID
+	UniqueThread    uintptr
+	Priority        int32
+	BasePriority    int32
+	ContextSwitches uint32
+	ThreadState     uint32
+	WaitReason      uint32
+}
+
+var procGetThreadDescription = processKernel32.NewProc("GetThreadDescription")
+
+// threadStateWaiting is the KTHREAD_STATE of a thread that waits
+const threadStateWaiting = 5
+
+// windowsThreadStates names KTHREAD_STATE values
+var windowsThreadStates = []string{
+	"initialized", "ready", "running", "standby", "terminated", "waiting",
+	"transition", "deferred ready", "gate waiting", "waiting for process swap",
+}
+
+// windowsWaitReasons names KWAIT_REASON values
+var windowsWaitReasons = []string{
+	"Executive", "FreePage", "PageIn", "PoolAllocation", "DelayExecution",
+	"Suspended", "UserRequest", "WrExecutive", "WrFreePage", "WrPageIn",
+	"WrPoolAllocation", "WrDelayExecution", "WrSuspended", "WrUserRequest",
+	"WrEventPair", "WrQueue", "WrLpcReceive", "WrLpcReply", "WrVirtualMemory",
+	"WrPageOut", "WrRendezvous", "WrKeyedEvent", "WrTerminated",
+	"WrProcessInSwap", "WrCpuRateControl", "WrCalloutStack", "WrKernel",
+	"WrResource", "WrPushLock", "WrMutex", "WrQuantumEnd", "WrDispatchInt",
+	"WrPreempted", "WrYieldExecution", "WrFastMutex", "WrGuardedMutex",
+	"WrRundown", "WrAlertByThreadId", "WrDeferredPreempt",
+}
+
+// readThreads reads every thread of a process from the system process
+// information, which holds the times, state and context switches of all
+// threads without opening them
+func readThreads(pid uint32) (*threadSnapshot, error) {
+	// The buffer is []uint64 so the structures in it are aligned
+	buf := make([]uint64, 1<<17)
+	var returned uint32
+	for {
+		ret, _, _ := procNtQuerySystemInformation.Call(
+			SystemProcessInformation,
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)*8),
+			uintptr(unsafe.Pointer(&returned)),
+		)
+		if ret == STATUS_INFO_LENGTH_MISMATCH {
+			// Processes may start before the next call, so leave some room
+			buf = make([]uint64, max(int(returned+1<<16)/8, 2*len(buf)))
+			continue
+		}
+		if ret != 0 {
+			return nil, fmt.Errorf("NtQuerySystemInformation failed: 0x%x", ret)
+		}
+		break
+	}
+	at := time.Now()
+
+	base := unsafe.Pointer(&buf[0])
+	for offset := uintptr(0); ; {
+		proc := (*windows.SYSTEM_PROCESS_INFORMATION)(unsafe.Add(base, offset))
+		if uint32(proc.UniqueProcessID) == pid {
+			snapshot := &threadSnapshot{
+				name:    proc.ImageName.String(),
+				at:      at,
+				threads: make(map[uint32]threadCounters, proc.NumberOfThreads),
+			}
+			first := unsafe.Add(unsafe.Pointer(proc), unsafe.Sizeof(*proc))
+			threads := unsafe.Slice((*SYSTEM_THREAD_INFORMATION)(first), proc.NumberOfThreads)
+			for i := range threads {
+				t := &threads[i]
+				tid := uint32(t.UniqueThread)
+				snapshot.threads[tid] = threadCounters{
+					startTime:   uint64(t.CreateTime),
+					name:        threadDescription(tid),
+					state:       threadStateName(t.ThreadState),
+					waitReason:  threadWaitReason(t),
+					priority:    int(t.Priority),
+					processor:   -1,
+					userTime:    float64(t.UserTime) / 1e7,
+					kernelTime:  float64(t.KernelTime) / 1e7,
+					switches:    uint64(t.ContextSwitches),
+					switchWidth: 32,
+				}
+			}
+			return snapshot, nil
+		}
+		if proc.NextEntryOffset == 0 {
+			return nil, ErrNoSuchProcess
+		}
+		offset += uintptr(proc.NextEntryOffset)
+	}
+}
+
+// threadDescription returns the name a thread was given with
+// SetThreadDescription (Windows 10 1607 and later), empty when it has none
+func threadDescription(tid uint32) string {
+	if procGetThreadDescription.Find() != nil {
+		return ""
+	}
+	handle, err := windows.OpenThread(windows.THREAD_QUERY_LIMITED_INFORMATION, false, tid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(handle)
+
+	var description *uint16
+	ret, _, _ := procGetThreadDescription.Call(uintptr(handle), uintptr(unsafe.Pointer(&description)))
+	if int32(ret) < 0 || description == nil {
+		return ""
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(description)))
+	return windows.UTF16PtrToString(description)
+}
+
+// threadStateName names a KTHREAD_STATE value
+func threadStateName(state uint32) string {
+	if int(state) < len(windowsThreadStates) {
+		return windowsThreadStates[state]
+	}
+	return fmt.Sprintf("state %d", state)
+}
+
+// threadWaitReason names what a waiting thread waits for; empty when it is
+// not waiting
+func threadWaitReason(t *SYSTEM_THREAD_INFORMATION) string {
+	if t.ThreadState != threadStateWaiting {
+		return ""
+	}
+	if int(t.WaitReason) < len(windowsWaitReasons) {
+		return windowsWaitReasons[t.WaitReason]
+	}
+	return fmt.Sprintf("reason %d", t.WaitReason)
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides per-thread CPU sampling of a process
+package collectors
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// linuxThreadStates names the task states of /proc/<pid>/task/<tid>/stat
+var linuxThreadStates = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "disk sleep",
+	"T": "stopped",
+	"t": "tracing stop",
+	"Z": "zombie",
+	"X": "dead",
+	"I": "idle",
+}
+
+// readThreads reads every thread of a process from /proc/<pid>/task. Threads
+// that exit while being read are skipped.
+func readThreads(pid uint32) (*threadSnapshot, error) {
+	dir := filepath.Join(procRoot, strconv.FormatUint(uint64(pid), 10))
+	stat, err := readPidStat(filepath.Join(dir, "stat"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoSuchProcess
+		}
+		return nil, err
+	}
+	tasks, err := os.ReadDir(filepath.Join(dir, "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &threadSnapshot{
+		name:    stat.comm,
+		at:      time.Now(),
+		threads: make(map[uint32]threadCounters, len(tasks)),
+	}
+	for _, task := range tasks {
+		tid, err := strconv.ParseUint(task.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		taskDir := filepath.Join(dir, "task", task.Name())
+		ts, err := readPidStat(filepath.Join(taskDir, "stat"))
+		if err != nil {
+			continue
+		}
+
+		// comm of a thread is its name: pthread_setname_np, the Java thread
+		// name (truncated to 15 characters) or the executable name
+		state := linuxThreadStates[ts.state]
+		if state == "" {
+			state = ts.state
+		}
+		counters := threadCounters{
+			startTime:   ts.startTime,
+			name:        ts.comm,
+			state:       state,
+			priority:    ts.nice,
+			processor:   ts.processor,
+			userTime:    float64(ts.utime) / clockTicks,
+			kernelTime:  float64(ts.stime) / clockTicks,
+			switchWidth: 64,
+		}
+		if status, err := readProcKeyValues(filepath.Join(taskDir, "status")); err == nil {
+			counters.voluntary = status["voluntary_ctxt_switches"]
+			counters.involuntary = status["nonvoluntary_ctxt_switches"]
+			counters.switches = counters.voluntary + counters.involuntary
+		}
+		snapshot.threads[uint32(tid)] = counters
+	}
+	return snapshot, nil
+}
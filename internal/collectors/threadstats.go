@@ -0,0 +1,141 @@
+// Package collectors provides per-thread CPU sampling of a process
+package collectors
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"time"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// Thread sampling settings
+const (
+	DefaultThreadWindow = time.Second      // sampling window when none is given
+	MaxThreadWindow     = 10 * time.Second // longest sampling window
+	hotThreadCount      = 5                // busiest threads that can be hot
+	hotThreadMinPercent = 10.0             // % of one core a hot thread uses at least
+)
+
+// ErrNoSuchProcess is returned when the process to sample does not exist or
+// cannot be opened
+var ErrNoSuchProcess = errors.New("no such process")
+
+// threadCounters is one sample of a thread's cumulative counters and state
+type threadCounters struct {
+	startTime   uint64 // creation time in platform units, tells a reused TID apart
+	name        string
+	state       string
+	waitReason  string
+	priority    int
+	processor   int
+	userTime    float64 // seconds
+	kernelTime  float64 // seconds
+	switches    uint64
+	voluntary   uint64 // Linux only
+	involuntary uint64 // Linux only
+	switchWidth uint   // bit width of switches: 32 on Windows
+}
+
+// threadSnapshot is every thread of a process at one moment
+type threadSnapshot struct {
+	name    string
+	at      time.Time
+	threads map[uint32]threadCounters // by TID
+}
+
+// SampleThreads measures the CPU use of every thread of a process over
+// window. It returns ErrNoSuchProcess when the process is gone.
+func SampleThreads(ctx context.Context, pid uint32, window time.Duration) (*models.ProcessThreads, error) {
+	if window <= 0 {
+		window = DefaultThreadWindow
+	}
+	window = min(window, MaxThreadWindow)
+
+	first, err := readThreads(pid)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(window):
+	}
+	last, err := readThreads(pid)
+	if err != nil {
+		return nil, err
+	}
+	return compareThreads(pid, first, last, runtime.NumCPU()), nil
+}
+
+// compareThreads computes thread rates between two snapshots, orders the
+// threads busiest first and marks the hot ones
+func compareThreads(pid uint32, first, last *threadSnapshot, cores int) *models.ProcessThreads {
+	elapsed := last.at.Sub(first.at).Seconds()
+	result := &models.ProcessThreads{
+		PID:           pid,
+		Name:          last.name,
+		SampledAt:     last.at,
+		WindowSeconds: elapsed,
+		ThreadCount:   len(last.threads),
+		Threads:       make([]models.ThreadInfo, 0, len(last.threads)),
+	}
+
+	var total float64
+	for tid, cur := range last.threads {
+		// A thread started during the window did all its work in it
+		prev, ok := first.threads[tid]
+		if !ok || prev.startTime != cur.startTime {
+			result.ThreadsStarted++
+			prev = threadCounters{}
+		}
+
+		info := models.ThreadInfo{
+			TID:             tid,
+			Name:            cur.name,
+			State:           cur.state,
+			WaitReason:      cur.waitReason,
+			Priority:        cur.priority,
+			Processor:       cur.processor,
+			CPUSeconds:      cur.userTime + cur.kernelTime,
+			ContextSwitches: cur.switches,
+		}
+		if elapsed > 0 {
+			info.UserPercent = max(cur.userTime-prev.userTime, 0) / elapsed * 100
+			info.KernelPercent = max(cur.kernelTime-prev.kernelTime, 0) / elapsed * 100
+			info.CPUPercent = info.UserPercent + info.KernelPercent
+			if switches, _, reset := counterDelta(cur.switches, prev.switches, cur.switchWidth); !reset {
+				info.ContextSwitchesPerSec = float64(switches) / elapsed
+			}
+			info.VoluntarySwitchesPerSec = counterRate(cur.voluntary, prev.voluntary, elapsed)
+			info.InvoluntarySwitchesPerSec = counterRate(cur.involuntary, prev.involuntary, elapsed)
+		}
+		total += info.CPUPercent
+		result.Threads = append(result.Threads, info)
+	}
+	for tid, prev := range first.threads {
+		if cur, ok := last.threads[tid]; !ok || cur.startTime != prev.startTime {
+			result.ThreadsExited++
+		}
+	}
+	if cores > 0 {
+		result.CPUPercent = total / float64(cores)
+	}
+
+	sort.Slice(result.Threads, func(i, j int) bool {
+		ti, tj := &result.Threads[i], &result.Threads[j]
+		if ti.CPUPercent != tj.CPUPercent {
+			return ti.CPUPercent > tj.CPUPercent
+		}
+		return ti.TID < tj.TID
+	})
+	for i := 0; i < len(result.Threads) && i < hotThreadCount; i++ {
+		if result.Threads[i].CPUPercent >= hotThreadMinPercent {
+			result.Threads[i].Hot = true
+			result.HotThreads++
+		}
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+// Package collectors provides traceroute functionality
+package collectors
+
+import (
+	"context"
+
+	"loadrunner-diagnosis/internal/models"
+)
+
+// TraceRouteCollector performs traceroute operations. Traceroute is not
+// ported to Linux yet; every trace returns ErrNotAvailable.
+type TraceRouteCollector struct{}
+
+// NewTraceRouteCollector creates a new traceroute collector
+func NewTraceRouteCollector() *TraceRouteCollector {
+	return &TraceRouteCollector{}
+}
+
+// Trace returns ErrNotAvailable
+func (t *TraceRouteCollector) Trace(ctx context.Context, target string) (*models.TraceRouteResult, error) {
+	return nil, ErrNotAvailable
+}
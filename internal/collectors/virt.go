@@ -0,0 +1,38 @@
+// Package collectors provides hypervisor and container environment detection
+package collectors
+
+import "strings"
+
+// hypervisorSignatures maps system vendor/product substrings to hypervisor names
+var hypervisorSignatures = []struct {
+	match string
+	name  string
+}{
+	{"vmware", "VMware"},
+	{"virtualbox", "VirtualBox"},
+	{"innotek", "VirtualBox"},
+	{"qemu", "KVM/QEMU"},
+	{"kvm", "KVM/QEMU"},
+	{"xen", "Xen"},
+	{"amazon ec2", "AWS EC2"},
+	{"google compute engine", "Google Compute Engine"},
+	{"parallels", "Parallels"},
+	{"bochs", "Bochs"},
+	{"openstack", "OpenStack"},
+}
+
+// matchHypervisor identifies a hypervisor from the firmware system vendor and product name
+func matchHypervisor(vendor, product string) string {
+	combined := strings.ToLower(vendor + " " + product)
+
+	// Hyper-V and Azure report Microsoft as vendor with a "Virtual Machine" product
+	if strings.Contains(combined, "microsoft") && strings.Contains(combined, "virtual machine") {
+		return "Hyper-V"
+	}
+	for _, sig := range hypervisorSignatures {
+		if strings.Contains(combined, sig.match) {
+			return sig.name
+		}
+	}
+	return ""
+}
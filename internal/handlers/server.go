@@ -4,9 +4,14 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -66,11 +71,21 @@ func (s *Server) SetupRoutes(mux *http.ServeMux) {
 	
 	mux.HandleFunc("/api/metrics/all", s.handleMetricsAll)
 	mux.HandleFunc("/api/metrics/tcp", s.handleMetricsTCP)
+	mux.HandleFunc("/api/metrics/tcp/endpoints", s.handleMetricsTCPEndpoints)
 	mux.HandleFunc("/api/metrics/memory", s.handleMetricsMemory)
 	mux.HandleFunc("/api/metrics/cpu", s.handleMetricsCPU)
 	mux.HandleFunc("/api/metrics/disk", s.handleMetricsDisk)
 	mux.HandleFunc("/api/metrics/network", s.handleMetricsNetwork)
+	mux.HandleFunc("/api/metrics/network/adapters", s.handleMetricsNetworkAdapters)
 	mux.HandleFunc("/api/metrics/processes", s.handleMetricsProcesses)
+	mux.HandleFunc("/api/metrics/processes/leaks", s.handleMetricsProcessLeaks)
+	mux.HandleFunc("/api/metrics/processes/tree", s.handleMetricsProcessTree)
+	mux.HandleFunc("/api/metrics/processes/groups", s.handleMetricsProcessGroups)
+	mux.HandleFunc("/api/processes/pins", s.handleProcessPins)
+	mux.HandleFunc("/api/processes/{pid}/history", s.handleProcessHistory)
+	mux.HandleFunc("/api/processes/events", s.handleProcessEvents)
+	mux.HandleFunc("/api/processes/{pid}/threads", s.handleProcessThreads)
+	mux.HandleFunc("/api/metrics/pressure", s.handleMetricsPressure)
 	mux.HandleFunc("/api/metrics/history", s.handleMetricsHistory)
 	
 	// Traceroute endpoint
@@ -106,12 +121,48 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 
 	// Parse optional interval from request
 	var req struct {
-		Interval int `json:"interval"` // seconds
+		Interval           int      `json:"interval"`           // seconds
+		CloseWaitThreshold int      `json:"closeWaitThreshold"` // seconds
+		ExtendedTCPStats   bool     `json:"extendedTcpStats"`   // per-connection RTT, cwnd, retransmits
+		CgroupPath         string   `json:"cgroupPath"`         // cgroup to report memory for
+		CgroupPID          int      `json:"cgroupPid"`          // or the cgroup of this process
+		DiskLatencySLA     int      `json:"diskLatencySla"`     // ms
+		TestDuration       int      `json:"testDuration"`       // seconds, expected load test length
+		ProcessSort        string   `json:"processSort"`        // ranking of the streamed process list
+		ProcessTop         int      `json:"processTop"`         // processes streamed
+		ProcessWatch       []string `json:"processWatch"`       // names or PIDs always streamed
+		ProcessMemoryLimit int      `json:"processMemoryLimit"` // MB, limit leaking memory is projected against
+		ProcessHandleLimit int      `json:"processHandleLimit"` // limit leaking handles are projected against
 	}
 	json.NewDecoder(r.Body).Decode(&req)
+	if err := s.collector.SetCgroupTarget(req.CgroupPath, req.CgroupPID); err != nil {
+		s.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.collector.SetProcessQuery(collectors.ProcessQuery{
+		Sort:  req.ProcessSort,
+		Top:   req.ProcessTop,
+		Watch: req.ProcessWatch,
+	}); err != nil {
+		s.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if req.Interval > 0 {
 		s.interval = time.Duration(req.Interval) * time.Second
 	}
+	if req.CloseWaitThreshold > 0 {
+		s.collector.SetCloseWaitThreshold(time.Duration(req.CloseWaitThreshold) * time.Second)
+	}
+	s.collector.SetExtendedStats(req.ExtendedTCPStats)
+	s.collector.ResetCommitPeak()
+	s.collector.ResetDiskLatency(time.Duration(req.DiskLatencySLA) * time.Millisecond)
+	s.collector.SetTestDuration(time.Duration(req.TestDuration) * time.Second)
+	s.collector.ResetNetworkInventory()
+	s.collector.ResetProcessHistory()
+	s.collector.ResetProcessEvents()
+	s.collector.ResetProcessLeaks(uint64(max(req.ProcessMemoryLimit, 0))<<20, uint64(max(req.ProcessHandleLimit, 0)))
 
 	s.isRunning = true
 	s.startedAt = time.Now()
@@ -218,6 +269,7 @@ func (s *Server) collectionLoop() {
 				}
 
 				metrics.Timestamp = time.Now()
+				metrics.ProcessEvents = s.collector.PendingProcessEvents()
 
 				s.mu.Lock()
 				s.samplesCount++
@@ -263,10 +315,27 @@ func (s *Server) broadcastLoop() {
 	}
 }
 
-// handleMetricsAll returns all current metrics
+// lastTick returns the metrics of the latest monitoring tick, nil when
+// monitoring is stopped or has not collected yet
+func (s *Server) lastTick() *models.SystemMetrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.isRunning || len(s.metricsHistory) == 0 {
+		return nil
+	}
+	return s.metricsHistory[len(s.metricsHistory)-1]
+}
+
+// handleMetricsAll returns all current metrics: those of the latest tick
+// while monitoring runs, so requests do not add samples between ticks
 func (s *Server) handleMetricsAll(w http.ResponseWriter, r *http.Request) {
+	if metrics := s.lastTick(); metrics != nil {
+		s.respondJSON(w, http.StatusOK, metrics)
+		return
+	}
+
 	ctx := r.Context()
-	metrics, err := s.collector.CollectAll(ctx)
+	metrics, err := s.collector.CollectCurrent(ctx)
 	if err != nil {
 		s.respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -286,6 +355,52 @@ func (s *Server) handleMetricsTCP(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, metrics)
 }
 
+// handleMetricsTCPEndpoints returns TCP connections aggregated by remote endpoint.
+// Query: groupBy=endpoint|address|host|cidr, cidr=10.0.0.0/8,192.168.0.0/16
+func (s *Server) handleMetricsTCPEndpoints(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var cidrs []*net.IPNet
+	for _, value := range strings.Split(query.Get("cidr"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid cidr %q", value))
+			return
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	groupBy := query.Get("groupBy")
+	if groupBy == "" && len(cidrs) > 0 {
+		groupBy = collectors.EndpointGroupCIDR
+	}
+
+	// While monitoring runs, the table of the last tick is recent enough;
+	// otherwise collect a new one so a stopped session is not served
+	s.mu.RLock()
+	var maxAge time.Duration
+	if s.isRunning {
+		maxAge = 2 * s.interval
+	}
+	s.mu.RUnlock()
+
+	ctx := r.Context()
+	metrics, err := s.collector.GetTCPEndpoints(ctx, groupBy, cidrs, maxAge)
+	if err != nil {
+		if errors.Is(err, collectors.ErrInvalidEndpointGroup) {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, metrics)
+}
+
 // handleMetricsMemory returns memory metrics
 func (s *Server) handleMetricsMemory(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -297,6 +412,21 @@ func (s *Server) handleMetricsMemory(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, metrics)
 }
 
+// handleMetricsPressure returns pressure stall information (Linux only)
+func (s *Server) handleMetricsPressure(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	metrics, err := s.collector.GetPressure(ctx)
+	if errors.Is(err, collectors.ErrNotAvailable) {
+		s.respondError(w, http.StatusNotImplemented, "pressure stall information is not available on this system")
+		return
+	}
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, metrics)
+}
+
 // handleMetricsCPU returns CPU metrics
 func (s *Server) handleMetricsCPU(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -308,8 +438,14 @@ func (s *Server) handleMetricsCPU(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, metrics)
 }
 
-// handleMetricsDisk returns disk metrics
+// handleMetricsDisk returns disk metrics, those of the latest tick while
+// monitoring runs
 func (s *Server) handleMetricsDisk(w http.ResponseWriter, r *http.Request) {
+	if metrics := s.lastTick(); metrics != nil && metrics.Disk != nil {
+		s.respondJSON(w, http.StatusOK, metrics.Disk)
+		return
+	}
+
 	ctx := r.Context()
 	metrics, err := s.collector.GetDisk(ctx)
 	if err != nil {
@@ -330,10 +466,57 @@ func (s *Server) handleMetricsNetwork(w http.ResponseWriter, r *http.Request) {
 	s.respondJSON(w, http.StatusOK, metrics)
 }
 
-// handleMetricsProcesses returns process metrics
+// handleMetricsNetworkAdapters returns the adapter inventory and its changes
+func (s *Server) handleMetricsNetworkAdapters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	inventory, err := s.collector.GetNetworkInventory(ctx)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, inventory)
+}
+
+// handleMetricsProcesses returns process metrics. Query parameters: sort (cpu,
+// memory, io, handles, threads, connections, closeWait), top, name, regex,
+// user and watch (comma-separated names or PIDs).
 func (s *Server) handleMetricsProcesses(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := collectors.ProcessQuery{
+		Sort: params.Get("sort"),
+		Name: params.Get("name"),
+		User: params.Get("user"),
+	}
+	if top := params.Get("top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid top %q", top))
+			return
+		}
+		query.Top = n
+	}
+	if expr := params.Get("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid regex: %v", err))
+			return
+		}
+		query.Regex = re
+	}
+	for _, value := range strings.Split(params.Get("watch"), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			query.Watch = append(query.Watch, value)
+		}
+	}
+	// Reject an invalid sort before collecting
+	if _, err := query.Apply(nil); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	ctx := r.Context()
-	metrics, err := s.collector.GetProcesses(ctx)
+	metrics, err := s.collector.GetProcesses(ctx, query)
 	if err != nil {
 		s.respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -341,6 +524,155 @@ func (s *Server) handleMetricsProcesses(w http.ResponseWriter, r *http.Request)
 	s.respondJSON(w, http.StatusOK, metrics)
 }
 
+// handleMetricsProcessLeaks returns the processes whose memory, handles or
+// threads grew steadily during monitoring
+func (s *Server) handleMetricsProcessLeaks(w http.ResponseWriter, r *http.Request) {
+	s.respondJSON(w, http.StatusOK, s.collector.GetProcessLeaks())
+}
+
+// handleMetricsProcessTree returns every process arranged under its parent
+func (s *Server) handleMetricsProcessTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tree, err := s.collector.GetProcessTree(ctx)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, tree)
+}
+
+// handleMetricsProcessGroups returns process metrics aggregated by group.
+// Query parameters: by (name, app or pattern), pattern (regex matched against
+// the command line, first capture group as the key) and sort.
+func (s *Server) handleMetricsProcessGroups(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var pattern *regexp.Regexp
+	if expr := params.Get("pattern"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid pattern: %v", err))
+			return
+		}
+		pattern = re
+	}
+	by := params.Get("by")
+	if by == "" && pattern != nil {
+		by = collectors.ProcessGroupPattern
+	}
+
+	// Reject an invalid grouping or sort before collecting
+	if _, err := collectors.GroupProcesses(nil, by, pattern, params.Get("sort")); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	groups, err := s.collector.GetProcessGroups(ctx, by, pattern, params.Get("sort"))
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, groups)
+}
+
+// handleProcessPins returns the pinned processes (GET) or replaces them
+// (POST {"pins": [...]}, PIDs or name/command-line regexes)
+func (s *Server) handleProcessPins(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Pins []string `json:"pins"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if err := s.collector.SetProcessPins(req.Pins); err != nil {
+			s.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pins":      s.collector.ProcessPins(),
+		"processes": s.collector.GetPinnedProcesses(),
+	})
+}
+
+// handleProcessHistory returns the recorded series of a pinned process, by
+// its current PID or any PID it had before a restart
+func (s *Server) handleProcessHistory(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.ParseUint(r.PathValue("pid"), 10, 32)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid pid %q", r.PathValue("pid")))
+		return
+	}
+	history, ok := s.collector.GetProcessHistory(uint32(pid))
+	if !ok {
+		s.respondError(w, http.StatusNotFound, fmt.Sprintf("process %d is not pinned", pid))
+		return
+	}
+	s.respondJSON(w, http.StatusOK, history)
+}
+
+// handleProcessEvents returns the process starts, exits, crashes and restart
+// loops of the session, oldest first. Query parameter: type (comma-separated)
+func (s *Server) handleProcessEvents(w http.ResponseWriter, r *http.Request) {
+	events := s.collector.GetProcessEvents()
+	if types := r.URL.Query().Get("type"); types != "" {
+		wanted := make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+		filtered := events[:0]
+		for _, e := range events {
+			if wanted[e.Type] {
+				filtered = append(filtered, e)
+			}
+		}
+		events = filtered
+	}
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"count":  len(events),
+		"events": events,
+	})
+}
+
+// handleProcessThreads samples the threads of a process and returns their CPU
+// use, busiest first. Query parameter: window (ms, default 1000, max 10000)
+func (s *Server) handleProcessThreads(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.ParseUint(r.PathValue("pid"), 10, 32)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid pid %q", r.PathValue("pid")))
+		return
+	}
+	window := collectors.DefaultThreadWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 || time.Duration(ms)*time.Millisecond > collectors.MaxThreadWindow {
+			s.respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid window %q: use 1 to %d ms", v, collectors.MaxThreadWindow.Milliseconds()))
+			return
+		}
+		window = time.Duration(ms) * time.Millisecond
+	}
+
+	threads, err := s.collector.GetProcessThreads(r.Context(), uint32(pid), window)
+	if errors.Is(err, collectors.ErrNoSuchProcess) {
+		s.respondError(w, http.StatusNotFound, fmt.Sprintf("process %d not found", pid))
+		return
+	}
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.respondJSON(w, http.StatusOK, threads)
+}
+
 // handleMetricsHistory returns historical metrics
 func (s *Server) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
@@ -383,6 +715,10 @@ func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	result, err := s.traceroute.Trace(ctx, target)
+	if errors.Is(err, collectors.ErrNotAvailable) {
+		s.respondError(w, http.StatusNotImplemented, "traceroute is not available on this system")
+		return
+	}
 	if err != nil {
 		// Still return partial results if available
 		if result != nil {
@@ -421,6 +757,10 @@ func (s *Server) handleNetPathProbe(w http.ResponseWriter, r *http.Request) {
 				ProbesPerHop: 3,
 			}
 			result, err := s.netpath.ProbeOnce(target, config)
+			if errors.Is(err, collectors.ErrNotAvailable) {
+				s.respondError(w, http.StatusNotImplemented, "NetPath is not available on this system")
+				return
+			}
 			if err != nil {
 				log.Printf("NetPath API: Probe failed for %s: %v", target, err)
 				s.respondError(w, http.StatusInternalServerError, err.Error())
@@ -496,6 +836,10 @@ func (s *Server) handleNetPathStart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	probe, err := s.netpath.StartProbe(req.Target, config)
+	if errors.Is(err, collectors.ErrNotAvailable) {
+		s.respondError(w, http.StatusNotImplemented, "NetPath is not available on this system")
+		return
+	}
 	if err != nil {
 		s.respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -12,6 +12,9 @@ type SystemMetrics struct {
 	Disk      *DiskMetrics   `json:"disk,omitempty"`
 	Network   *NetworkMetrics `json:"network,omitempty"`
 	Processes []ProcessInfo  `json:"processes,omitempty"`
+	Pressure  *PressureStallMetrics `json:"pressure,omitempty"` // Linux only
+	ProcessLeaks []ProcessLeak `json:"processLeaks,omitempty"` // resources growing steadily
+	ProcessEvents []ProcessEvent `json:"processEvents,omitempty"` // starts, exits and crashes since the previous sample
 }
 
 // TCPMetrics contains TCP connection statistics
@@ -40,6 +43,18 @@ type TCPMetrics struct {
 	ConnectionFailures  uint64 `json:"connectionFailures"`
 	ConnectionsReset    uint64 `json:"connectionsReset"`
 	
+	// Ephemeral Port Usage
+	PortExhaustion      *PortExhaustionMetrics `json:"portExhaustion,omitempty"`
+	
+	// Connection Lifetime & Churn
+	Churn               *TCPChurnMetrics `json:"churn,omitempty"`
+	
+	// Listen Sockets (accept queue, SYN queue, overflows)
+	Listeners           *ListenerMetrics `json:"listeners,omitempty"`
+	
+	// Connections with the most retransmitted segments (extended stats only)
+	TopRetransmitting   []TCPConnection `json:"topRetransmitting,omitempty"`
+	
 	// Active Connections Table
 	Connections         []TCPConnection `json:"connections,omitempty"`
 }
@@ -53,6 +68,148 @@ type TCPConnection struct {
 	State         string `json:"state"`
 	PID           uint32 `json:"pid"`
 	ProcessName   string `json:"processName,omitempty"`
+	AgeSeconds    float64 `json:"ageSeconds,omitempty"`   // time since first seen
+	StateSeconds  float64 `json:"stateSeconds,omitempty"` // time in current state
+	Stats         *TCPConnectionStats `json:"stats,omitempty"` // extended stats, when enabled
+}
+
+// TCPConnectionStats holds extended per-connection statistics
+type TCPConnectionStats struct {
+	SmoothedRTT           float64 `json:"smoothedRtt"`      // ms
+	RTTVariance           float64 `json:"rttVariance"`      // ms
+	CongestionWindow      uint64  `json:"congestionWindow"` // bytes
+	BytesIn               uint64  `json:"bytesIn"`
+	BytesOut              uint64  `json:"bytesOut"`
+	SegmentsRetransmitted uint64  `json:"segmentsRetransmitted"`
+	BytesRetransmitted    uint64  `json:"bytesRetransmitted"`
+	ReceiveWindow         uint64  `json:"receiveWindow"` // bytes advertised by us
+	SendWindow            uint64  `json:"sendWindow"`    // bytes advertised by the peer
+}
+
+// ListenerMetrics describes listening sockets, their queues and overflow counters
+type ListenerMetrics struct {
+	Listeners           []ListenerInfo `json:"listeners"`
+	QueueStatsAvailable bool           `json:"queueStatsAvailable"` // accept queue and backlog reported by the OS
+	MaxSynBacklog       uint32         `json:"maxSynBacklog,omitempty"` // system-wide SYN queue limit
+	ListenOverflows     uint64         `json:"listenOverflows"` // accept queue full, cumulative
+	ListenDrops         uint64         `json:"listenDrops"`     // SYNs dropped on listeners, cumulative
+	OverflowsPerSec     float64        `json:"overflowsPerSec"`
+	DropsPerSec         float64        `json:"dropsPerSec"`
+	Status              string         `json:"status"` // ok, warning, critical
+	Message             string         `json:"message,omitempty"`
+}
+
+// ListenerInfo describes one listening socket
+type ListenerInfo struct {
+	LocalAddress string  `json:"localAddress"`
+	LocalPort    uint16  `json:"localPort"`
+	PID          uint32  `json:"pid"`
+	AcceptQueue  uint32  `json:"acceptQueue"`  // connections waiting for accept()
+	Backlog      uint32  `json:"backlog"`      // accept queue limit
+	QueuePercent float64 `json:"queuePercent"` // accept queue vs backlog
+	SynReceived  int     `json:"synReceived"`  // half-open connections (SYN queue)
+}
+
+// TCPEndpointMetrics holds connections aggregated by remote endpoint
+type TCPEndpointMetrics struct {
+	GroupBy         string        `json:"groupBy"` // endpoint, address, host or cidr
+	SampledAt       time.Time     `json:"sampledAt"` // when the connection table was collected
+	IntervalSeconds float64       `json:"intervalSeconds"`
+	Endpoints       []TCPEndpoint `json:"endpoints"`
+}
+
+// TCPEndpoint describes the connections to one remote endpoint group
+type TCPEndpoint struct {
+	Endpoint        string            `json:"endpoint"`
+	RemoteAddresses []string          `json:"remoteAddresses,omitempty"` // when grouped by host or cidr
+	Connections     int               `json:"connections"`
+	States          map[string]int    `json:"states"`
+	Opened          int               `json:"opened"`
+	Closed          int               `json:"closed"`
+	OpenedPerSec    float64           `json:"openedPerSec"`
+	ClosedPerSec    float64           `json:"closedPerSec"`
+	Processes       []EndpointProcess `json:"processes,omitempty"`
+}
+
+// EndpointProcess is a process owning connections to an endpoint
+type EndpointProcess struct {
+	PID         uint32 `json:"pid"`
+	Connections int    `json:"connections"`
+}
+
+// TCPChurnMetrics describes connections opened and closed between samples
+type TCPChurnMetrics struct {
+	IntervalSeconds float64 `json:"intervalSeconds"`
+	Opened          int     `json:"opened"`
+	Closed          int     `json:"closed"`
+	OpenedPerSec    float64 `json:"openedPerSec"`
+	ClosedPerSec    float64 `json:"closedPerSec"`
+	
+	// Connections already open when tracking started (their ages are lower bounds)
+	PreexistingConnections int `json:"preexistingConnections"`
+
+	// Connections opened and closed between two samples, seen only in TIME_WAIT
+	// (included in Opened and Closed)
+	ShortLived int `json:"shortLived"`
+	
+	AgeHistogram  []AgeBucket     `json:"ageHistogram"`
+	EndpointChurn []EndpointChurn `json:"endpointChurn,omitempty"`
+	
+	// Connections stuck in CLOSE_WAIT longer than the threshold, oldest first
+	CloseWaitThreshold float64         `json:"closeWaitThreshold"` // seconds
+	LongCloseWait      []TCPConnection `json:"longCloseWait,omitempty"`
+}
+
+// AgeBucket is one bucket of the connection age histogram
+type AgeBucket struct {
+	Label      string  `json:"label"`
+	MaxSeconds float64 `json:"maxSeconds"` // 0 = unbounded
+	Count      int     `json:"count"`
+}
+
+// EndpointChurn reports connection churn for one remote endpoint
+type EndpointChurn struct {
+	Endpoint      string  `json:"endpoint"`
+	RemoteAddress string  `json:"remoteAddress"`
+	RemotePort    uint16  `json:"remotePort"`
+	Opened        int     `json:"opened"`
+	Closed        int     `json:"closed"`
+	ShortLived    int     `json:"shortLived"` // opened and closed between two samples
+	OpenedPerSec  float64 `json:"openedPerSec"`
+	ClosedPerSec  float64 `json:"closedPerSec"`
+}
+
+// PortExhaustionMetrics tracks ephemeral port consumption against the
+// configured dynamic port range
+type PortExhaustionMetrics struct {
+	// Configured dynamic (ephemeral) port range
+	DynamicPortStart uint16 `json:"dynamicPortStart"`
+	DynamicPortCount int    `json:"dynamicPortCount"`
+	
+	// Usage
+	PortsInUse      int     `json:"portsInUse"`  // distinct ephemeral ports across all local IPs
+	UsedPercent     float64 `json:"usedPercent"` // worst case of per-local-IP and per-endpoint usage
+	Bottleneck      string  `json:"bottleneck,omitempty"` // the local IP or 4-tuple closest to the limit
+	PerLocalAddress []PortUsage `json:"perLocalAddress,omitempty"`
+	TopEndpoints    []PortUsage `json:"topEndpoints,omitempty"` // local IP -> remote IP:port, the limit that bites load tests
+	
+	// Forecast
+	GrowthRate          float64 `json:"growthRate"`          // ports per second
+	SecondsToExhaustion float64 `json:"secondsToExhaustion"` // -1 when usage is flat or shrinking
+	
+	// Status
+	Status  string `json:"status"` // ok, warning, critical
+	Message string `json:"message,omitempty"`
+}
+
+// PortUsage reports ephemeral ports held by one local IP or one remote endpoint
+type PortUsage struct {
+	LocalAddress  string  `json:"localAddress"`
+	RemoteAddress string  `json:"remoteAddress,omitempty"`
+	RemotePort    uint16  `json:"remotePort,omitempty"`
+	PortsInUse    int     `json:"portsInUse"`
+	TimeWait      int     `json:"timeWait"`
+	UsedPercent   float64 `json:"usedPercent"`
 }
 
 // MemoryMetrics contains memory usage statistics
@@ -73,6 +230,7 @@ type MemoryMetrics struct {
 	
 	// Paging
 	PageFaultsPerSec  uint64 `json:"pageFaultsPerSec"`
+	MajorFaultsPerSec uint64 `json:"majorFaultsPerSec"` // faults that read from disk
 	PagesInputPerSec  uint64 `json:"pagesInputPerSec"`  // Hard page faults
 	PagesOutputPerSec uint64 `json:"pagesOutputPerSec"`
 	
@@ -80,6 +238,57 @@ type MemoryMetrics struct {
 	CommittedBytes    uint64  `json:"committedBytes"`
 	CommitLimit       uint64  `json:"commitLimit"`
 	CommitPercent     float64 `json:"commitPercent"`
+	CommitPeak        uint64  `json:"commitPeak"` // since boot on Windows, since monitoring started on Linux
+	
+	// Kernel Memory
+	KernelPagedBytes       uint64 `json:"kernelPagedBytes"`       // paged pool (Windows only)
+	KernelNonpagedBytes    uint64 `json:"kernelNonpagedBytes"`    // nonpaged pool (Windows only)
+	SlabReclaimableBytes   uint64 `json:"slabReclaimableBytes"`   // Linux only
+	SlabUnreclaimableBytes uint64 `json:"slabUnreclaimableBytes"` // Linux only
+	
+	// Container
+	Cgroup *CgroupMemoryMetrics `json:"cgroup,omitempty"` // configured or own cgroup (Linux only)
+}
+
+// CgroupMemoryMetrics describes the memory limit and usage of a cgroup
+type CgroupMemoryMetrics struct {
+	Path                string           `json:"path"`
+	Version             int              `json:"version"`             // cgroup v1 or v2
+	PID                 int              `json:"pid,omitempty"`       // process whose cgroup is reported
+	LimitBytes          uint64           `json:"limitBytes"`          // 0 = unlimited
+	UsageBytes          uint64           `json:"usageBytes"`          // includes page cache
+	WorkingSetBytes     uint64           `json:"workingSetBytes"`     // usage minus inactive file cache
+	UsagePercentOfLimit float64          `json:"usagePercentOfLimit"` // working set vs limit, 0 when unlimited
+	CacheBytes          uint64           `json:"cacheBytes"`          // page cache charged to the cgroup
+	SwapBytes           uint64           `json:"swapBytes"`
+	OOMKills            uint64           `json:"oomKills"` // since the cgroup was created
+	OOMKillsInInterval  uint64           `json:"oomKillsInInterval"`
+	Pressure            *PressureMetrics `json:"pressure,omitempty"` // memory.pressure, when PSI is enabled
+}
+
+// PressureMetrics contains pressure stall information (PSI) for one resource
+type PressureMetrics struct {
+	SomeAvg10  float64 `json:"someAvg10"` // % of time at least one task stalled
+	SomeAvg60  float64 `json:"someAvg60"`
+	SomeAvg300 float64 `json:"someAvg300"`
+	FullAvg10  float64 `json:"fullAvg10"` // % of time all non-idle tasks stalled
+	FullAvg60  float64 `json:"fullAvg60"`
+	FullAvg300 float64 `json:"fullAvg300"`
+	SomeTotal  uint64  `json:"someTotalUs"` // cumulative stall time
+	FullTotal  uint64  `json:"fullTotalUs"`
+
+	// Stall time between the previous and this sample
+	SomeStallSeconds    float64 `json:"someStallSeconds"`
+	FullStallSeconds    float64 `json:"fullStallSeconds"`
+	SomeIntervalPercent float64 `json:"someIntervalPercent"`
+	FullIntervalPercent float64 `json:"fullIntervalPercent"`
+}
+
+// PressureStallMetrics contains system-wide pressure stall information
+type PressureStallMetrics struct {
+	CPU    *PressureMetrics `json:"cpu,omitempty"`
+	Memory *PressureMetrics `json:"memory,omitempty"`
+	IO     *PressureMetrics `json:"io,omitempty"`
 }
 
 // CPUMetrics contains CPU usage statistics
@@ -89,25 +298,87 @@ type CPUMetrics struct {
 	UserPercent    float64   `json:"userPercent"`
 	KernelPercent  float64   `json:"kernelPercent"`
 	IdlePercent    float64   `json:"idlePercent"`
+	StealPercent   float64   `json:"stealPercent"` // taken by the hypervisor (Linux only)
+	GuestPercent   float64   `json:"guestPercent"` // running guest VMs, included in user (Linux only)
+	
+	// Environment
+	Environment    *EnvironmentInfo  `json:"environment,omitempty"`
+	Cgroup         *CgroupCPUMetrics `json:"cgroup,omitempty"` // CPU limit of our cgroup (Linux only)
 	
 	// Per-Core
 	CoreCount      int       `json:"coreCount"`
 	PerCorePercent []float64 `json:"perCorePercent"`
+	Cores          []CoreUsage     `json:"cores,omitempty"`     // per logical processor breakdown
+	NUMANodes      []NUMANodeUsage `json:"numaNodes,omitempty"` // only on multi-node systems
+	HotCores       []int           `json:"hotCores,omitempty"`  // saturated cores while the average looks fine
 	
 	// Additional Stats
 	ContextSwitchesPerSec uint64 `json:"contextSwitchesPerSec"`
 	InterruptsPerSec      uint64 `json:"interruptsPerSec"`
-	ProcessorQueueLength  uint64 `json:"processorQueueLength"`
+	ProcessorQueueLength  uint64 `json:"processorQueueLength"` // threads ready but waiting for a CPU
+	RunnableTasks         uint64 `json:"runnableTasks,omitempty"`    // Linux: running + ready (procs_running)
+	BlockedTasks          uint64 `json:"blockedTasks,omitempty"`     // Linux: waiting on IO (procs_blocked)
+	SoftIRQPerSec         map[string]float64 `json:"softirqPerSec,omitempty"` // Linux softirqs by type, Windows DPCs
+	LoadAverage           *LoadAverage `json:"loadAverage,omitempty"`       // Linux only
+}
+
+// EnvironmentInfo describes the virtualization and container environment
+type EnvironmentInfo struct {
+	Virtualized bool   `json:"virtualized"`
+	Hypervisor  string `json:"hypervisor,omitempty"` // e.g. VMware, Hyper-V, KVM/QEMU, or "unknown"
+	Container   string `json:"container,omitempty"`  // e.g. docker, podman, kubernetes, lxc
+}
+
+// CgroupCPUMetrics describes the CPU quota and throttling of a cgroup
+type CgroupCPUMetrics struct {
+	Path                string  `json:"path"`
+	Version             int     `json:"version"`             // cgroup v1 or v2
+	QuotaCores          float64 `json:"quotaCores"`          // CPU limit in cores, 0 = unlimited
+	UsageCores          float64 `json:"usageCores"`          // cores used over the interval
+	UsagePercentOfQuota float64 `json:"usagePercentOfQuota"` // 0 when unlimited
+	Periods             uint64  `json:"periods"`             // enforcement periods in the interval
+	ThrottledPeriods    uint64  `json:"throttledPeriods"`    // periods in the interval that hit the quota
+	ThrottledPercent    float64 `json:"throttledPercent"`    // throttled vs all periods
+	ThrottledSeconds    float64 `json:"throttledSeconds"`    // time spent throttled in the interval
+}
+
+// LoadAverage holds the 1, 5 and 15 minute run-queue load averages
+type LoadAverage struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// CoreUsage is the utilization breakdown of one logical processor
+type CoreUsage struct {
+	ID            int     `json:"id"`
+	NUMANode      int     `json:"numaNode"`
+	TotalPercent  float64 `json:"totalPercent"`
+	UserPercent   float64 `json:"userPercent"`
+	KernelPercent float64 `json:"kernelPercent"` // includes interrupt and softirq/DPC time
+	IdlePercent   float64 `json:"idlePercent"`
+	IOWaitPercent float64 `json:"iowaitPercent"` // Linux only
+	StealPercent  float64 `json:"stealPercent"`  // Linux only
+}
+
+// NUMANodeUsage is the average utilization of the cores in one NUMA node
+type NUMANodeUsage struct {
+	Node         int     `json:"node"`
+	Cores        []int   `json:"cores"`
+	TotalPercent float64 `json:"totalPercent"`
+	MaxPercent   float64 `json:"maxPercent"` // busiest core in the node
 }
 
 // DiskMetrics contains disk I/O statistics
 type DiskMetrics struct {
-	Disks []DiskInfo `json:"disks"`
+	Disks   []DiskInfo `json:"disks"`   // per volume / mount point
+	Devices []DiskInfo `json:"devices"` // per physical device
 }
 
 // DiskInfo contains per-disk statistics
 type DiskInfo struct {
 	Name            string  `json:"name"`
+	Device          string  `json:"device,omitempty"` // physical device backing a volume
 	
 	// Throughput
 	ReadBytesPerSec  uint64  `json:"readBytesPerSec"`
@@ -118,7 +389,8 @@ type DiskInfo struct {
 	WritesPerSec    float64 `json:"writesPerSec"`
 	
 	// Queue & Latency
-	QueueLength     uint64  `json:"queueLength"`
+	QueueLength     uint64  `json:"queueLength"`    // IOs in flight at sample time
+	AvgQueueLength  float64 `json:"avgQueueLength"` // average IOs in flight over the interval
 	AvgReadLatency  float64 `json:"avgReadLatency"`  // ms
 	AvgWriteLatency float64 `json:"avgWriteLatency"` // ms
 	
@@ -130,11 +402,80 @@ type DiskInfo struct {
 	TotalBytes      uint64  `json:"totalBytes"`
 	FreeBytes       uint64  `json:"freeBytes"`
 	UsedPercent     float64 `json:"usedPercent"`
+	
+	// Filesystem (volumes only)
+	FileSystem        string  `json:"fileSystem,omitempty"`
+	ReadOnly          bool    `json:"readOnly"`
+	TotalInodes       uint64  `json:"totalInodes"` // Linux only, 0 when the filesystem has no fixed inode count
+	FreeInodes        uint64  `json:"freeInodes"`
+	InodesUsedPercent float64 `json:"inodesUsedPercent"`
+	
+	// Capacity forecast (volumes only)
+	GrowthBytesPerSec  float64 `json:"growthBytesPerSec"`
+	SecondsToFull      float64 `json:"secondsToFull"`      // -1 when usage is flat or shrinking
+	FillsBeforeTestEnd bool    `json:"fillsBeforeTestEnd"` // full before the configured test end (or within an hour)
+	
+	// Latency distribution since monitoring started
+	Latency *DiskLatencyStats `json:"latency,omitempty"`
+}
+
+// DiskLatencyStats summarizes the latency distribution of a disk over a
+// monitoring run. Each interval's average latency is weighted by the number
+// of operations completed in it.
+type DiskLatencyStats struct {
+	ReadP50          float64 `json:"readP50"` // ms
+	ReadP95          float64 `json:"readP95"`
+	ReadP99          float64 `json:"readP99"`
+	ReadMax          float64 `json:"readMax"`
+	WriteP50         float64 `json:"writeP50"`
+	WriteP95         float64 `json:"writeP95"`
+	WriteP99         float64 `json:"writeP99"`
+	WriteMax         float64 `json:"writeMax"`
+	SLAMs            float64 `json:"slaMs"`
+	Intervals        uint64  `json:"intervals"`        // intervals with completed IO
+	IntervalsOverSLA uint64  `json:"intervalsOverSla"` // intervals whose read or write average exceeded the SLA
 }
 
 // NetworkMetrics contains network interface statistics
 type NetworkMetrics struct {
-	Interfaces []NetworkInterface `json:"interfaces"`
+	Interfaces     []NetworkInterface `json:"interfaces"`
+	AdapterChanges []AdapterChange    `json:"adapterChanges,omitempty"` // detected since the previous sample
+}
+
+// NetworkAdapter is the configuration of a network adapter
+type NetworkAdapter struct {
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Index         uint32          `json:"index"`
+	MAC           string          `json:"mac"`
+	MTU           uint32          `json:"mtu"`
+	Addresses     []string        `json:"addresses"` // IP/prefix
+	IsUp          bool            `json:"isUp"`
+	Speed         uint64          `json:"speed"`  // bits per second
+	Duplex        string          `json:"duplex"` // full, half, auto or unknown
+	Driver        string          `json:"driver"`
+	DriverVersion string          `json:"driverVersion,omitempty"`
+	Offloads      map[string]bool `json:"offloads,omitempty"` // rx-checksum, tx-checksum, tso, gso, gro, lro, rss
+	RxQueues      int             `json:"rxQueues"`
+	TxQueues      int             `json:"txQueues"`
+}
+
+// AdapterChange is a change of an adapter's link state or configuration
+type AdapterChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Adapter   string    `json:"adapter"`
+	Field     string    `json:"field"` // added, removed, link, speed, duplex, mtu, mac, addresses, driver, queues or offload:<name>
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+}
+
+// NetworkInventory is the adapter configuration at session start, now, and
+// every change in between
+type NetworkInventory struct {
+	BaselineAt time.Time        `json:"baselineAt"`
+	Baseline   []NetworkAdapter `json:"baseline"`
+	Current    []NetworkAdapter `json:"current"`
+	Changes    []AdapterChange  `json:"changes"`
 }
 
 // NetworkInterface contains per-interface statistics
@@ -163,21 +504,212 @@ type NetworkInterface struct {
 	OutErrors       uint64 `json:"outErrors"`
 	InDiscards      uint64 `json:"inDiscards"`  // Buffer full!
 	OutDiscards     uint64 `json:"outDiscards"` // Buffer full!
+
+	// Error and discard rates
+	InErrorsPerSec    float64 `json:"inErrorsPerSec"`
+	OutErrorsPerSec   float64 `json:"outErrorsPerSec"`
+	InDiscardsPerSec  float64 `json:"inDiscardsPerSec"`
+	OutDiscardsPerSec float64 `json:"outDiscardsPerSec"`
+
+	// Error and discard analysis for the interval
+	InErrorPercent    float64             `json:"inErrorPercent"`    // share of received packets with errors
+	OutErrorPercent   float64             `json:"outErrorPercent"`   // share of sent packets with errors
+	InDiscardPercent  float64             `json:"inDiscardPercent"`  // share of received packets discarded
+	OutDiscardPercent float64             `json:"outDiscardPercent"` // share of sent packets discarded
+	DropReasons       []NetworkDropReason `json:"dropReasons,omitempty"`
+	BufferExhaustion  bool                `json:"bufferExhaustion"` // packets lost for lack of NIC or kernel buffers
+	LinkErrors        bool                `json:"linkErrors"`       // damaged frames or physical link problems
+
+	// Counter health
+	CounterBits   int    `json:"counterBits"`   // 64, or 32 when only legacy counters are available
+	CounterWraps  uint64 `json:"counterWraps"`  // 32-bit wraps corrected since start
+	CounterResets uint64 `json:"counterResets"` // counter resets (driver reload) since start
 	
 	// Buffer/Queue (capacity visualization)
 	OutputQueueLength uint64  `json:"outputQueueLength"`
 	Utilization       float64 `json:"utilization"` // percentage of speed
 }
 
+// NetworkDropReason is a detailed drop or error counter of an interface
+type NetworkDropReason struct {
+	Reason    string  `json:"reason"`    // counter name, e.g. rx_missed_errors
+	Direction string  `json:"direction"` // in or out
+	Category  string  `json:"category"`  // buffer, link or protocol
+	Total     uint64  `json:"total"`
+	PerSec    float64 `json:"perSec"`
+}
+
 // ProcessInfo contains process resource usage
 type ProcessInfo struct {
 	PID           uint32  `json:"pid"`
 	Name          string  `json:"name"`
-	CPUPercent    float64 `json:"cpuPercent"`
-	MemoryBytes   uint64  `json:"memoryBytes"`
+	CPUPercent    float64 `json:"cpuPercent"`  // share of all cores
+	MemoryBytes   uint64  `json:"memoryBytes"` // working set
 	MemoryPercent float64 `json:"memoryPercent"`
+	PrivateBytes  uint64  `json:"privateBytes"` // committed private memory; resident anonymous memory on Linux
 	ThreadCount   uint32  `json:"threadCount"`
-	HandleCount   uint32  `json:"handleCount"`
+	HandleCount   uint32  `json:"handleCount"`       // open file descriptors on Linux
+	User          string  `json:"user,omitempty"`    // DOMAIN\user on Windows
+	Connections   int     `json:"connections"`       // TCP connections owned
+	Watched       bool    `json:"watched,omitempty"` // on the watch list
+
+	// Identity
+	ParentPID   uint32    `json:"parentPid"`
+	StartTime   time.Time `json:"startTime"`
+	ExePath     string    `json:"exePath,omitempty"`
+	CommandLine string    `json:"commandLine,omitempty"`
+	WorkingDir  string    `json:"workingDir,omitempty"`
+
+	// TCP connections owned, from the same collection as the connection table
+	ConnectionStates   map[string]int          `json:"connectionStates,omitempty"`   // by state
+	ListeningPorts     []uint16                `json:"listeningPorts,omitempty"`     // local ports in LISTEN
+	RemoteEndpoints    int                     `json:"remoteEndpoints,omitempty"`    // distinct remote address and port
+	RemoteAddresses    int                     `json:"remoteAddresses,omitempty"`    // distinct remote hosts
+	TopRemoteEndpoints []ProcessRemoteEndpoint `json:"topRemoteEndpoints,omitempty"` // most connections first
+
+	// CPU split
+	UserPercent   float64 `json:"userPercent"`
+	KernelPercent float64 `json:"kernelPercent"`
+
+	// I/O and paging rates
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+	PageFaultsPerSec float64 `json:"pageFaultsPerSec"`
+}
+
+// ProcessRemoteEndpoint is a remote endpoint a process is connected to.
+// Clients of a listening port are counted by address, without a port.
+type ProcessRemoteEndpoint struct {
+	Address     string `json:"address"`
+	Port        uint16 `json:"port,omitempty"`
+	Inbound     bool   `json:"inbound,omitempty"` // client of a listening port
+	Connections int    `json:"connections"`
+}
+
+// ProcessNode is a process with its child processes. Tree totals include
+// the process itself and all of its descendants.
+type ProcessNode struct {
+	ProcessInfo
+	Children        []ProcessNode `json:"children,omitempty"`
+	Descendants     int           `json:"descendants"`
+	TreeCPUPercent  float64       `json:"treeCpuPercent"`
+	TreeMemoryBytes uint64        `json:"treeMemoryBytes"`
+}
+
+// ProcessGroup aggregates the processes sharing a name or command-line key,
+// e.g. the w3wp.exe of one IIS app pool or the JVMs running one main class
+type ProcessGroup struct {
+	Key              string         `json:"key"`
+	Processes        int            `json:"processes"`
+	PIDs             []uint32       `json:"pids"`
+	CPUPercent       float64        `json:"cpuPercent"`
+	MemoryBytes      uint64         `json:"memoryBytes"`
+	PrivateBytes     uint64         `json:"privateBytes"`
+	ReadBytesPerSec  float64        `json:"readBytesPerSec"`
+	WriteBytesPerSec float64        `json:"writeBytesPerSec"`
+	ThreadCount      uint32         `json:"threadCount"`
+	HandleCount      uint32         `json:"handleCount"`
+	Connections      int            `json:"connections"`
+	ConnectionStates map[string]int `json:"connectionStates,omitempty"`
+}
+
+// ProcessSample is one sample of a pinned process
+type ProcessSample struct {
+	Timestamp        time.Time      `json:"timestamp"`
+	PID              uint32         `json:"pid"`
+	CPUPercent       float64        `json:"cpuPercent"`
+	MemoryBytes      uint64         `json:"memoryBytes"`
+	PrivateBytes     uint64         `json:"privateBytes"`
+	ReadBytesPerSec  float64        `json:"readBytesPerSec"`
+	WriteBytesPerSec float64        `json:"writeBytesPerSec"`
+	ThreadCount      uint32         `json:"threadCount"`
+	HandleCount      uint32         `json:"handleCount"`
+	Connections      int            `json:"connections"`
+	ConnectionStates map[string]int `json:"connectionStates,omitempty"` // TCP connections by state
+}
+
+// ProcessHistory is the series of a pinned process. A process restarted with
+// the same name and command line continues the series under its new PID.
+type ProcessHistory struct {
+	Pin         string          `json:"pin"` // the pin that matched the process
+	Name        string          `json:"name"`
+	CommandLine string          `json:"commandLine,omitempty"`
+	PIDs        []uint32        `json:"pids"` // every PID of the process, latest last
+	Running     bool            `json:"running"`
+	SampleCount int             `json:"sampleCount"`
+	Samples     []ProcessSample `json:"samples,omitempty"`
+}
+
+// ProcessEvent is a process start, exit or crash, or a restart loop
+type ProcessEvent struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Type            string    `json:"type"` // start, exit, crash or restartLoop
+	PID             uint32    `json:"pid"`
+	ParentPID       uint32    `json:"parentPid"`
+	Name            string    `json:"name"`
+	CommandLine     string    `json:"commandLine,omitempty"`
+	ExitCode        *int64    `json:"exitCode,omitempty"`        // when the OS reported it
+	Reason          string    `json:"reason,omitempty"`          // e.g. access violation, SIGSEGV
+	LifetimeSeconds float64   `json:"lifetimeSeconds,omitempty"` // of exited processes
+	Restarts        int       `json:"restarts,omitempty"`        // starts within the window, for restartLoop
+}
+
+// ProcessThreads is the CPU use of each thread of a process over a short window
+type ProcessThreads struct {
+	PID            uint32       `json:"pid"`
+	Name           string       `json:"name"`
+	SampledAt      time.Time    `json:"sampledAt"`
+	WindowSeconds  float64      `json:"windowSeconds"`
+	CPUPercent     float64      `json:"cpuPercent"` // whole process, share of all cores as in ProcessInfo
+	ThreadCount    int          `json:"threadCount"`
+	ThreadsStarted int          `json:"threadsStarted"` // during the window
+	ThreadsExited  int          `json:"threadsExited"`  // during the window, not listed
+	HotThreads     int          `json:"hotThreads"`
+	Threads        []ThreadInfo `json:"threads"` // busiest first
+}
+
+// ThreadInfo is one thread of a process. CPU is a share of one core, so a
+// thread spinning on a core shows 100%.
+type ThreadInfo struct {
+	TID                       uint32  `json:"tid"` // OS thread ID; jstack prints it in hex as nid
+	Name                      string  `json:"name,omitempty"`
+	State                     string  `json:"state"`
+	WaitReason                string  `json:"waitReason,omitempty"` // Windows
+	Priority                  int     `json:"priority"`
+	Processor                 int     `json:"processor"` // CPU last run on; -1 when unknown
+	CPUPercent                float64 `json:"cpuPercent"`
+	UserPercent               float64 `json:"userPercent"`
+	KernelPercent             float64 `json:"kernelPercent"`
+	CPUSeconds                float64 `json:"cpuSeconds"` // since the thread started
+	ContextSwitches           uint64  `json:"contextSwitches"`
+	ContextSwitchesPerSec     float64 `json:"contextSwitchesPerSec"`
+	VoluntarySwitchesPerSec   float64 `json:"voluntarySwitchesPerSec,omitempty"`   // Linux: blocking
+	InvoluntarySwitchesPerSec float64 `json:"involuntarySwitchesPerSec,omitempty"` // Linux: preempted
+	Hot                       bool    `json:"hot,omitempty"`
+}
+
+// ProcessLeak reports a process resource that grew steadily over the
+// monitoring run
+type ProcessLeak struct {
+	PID            uint32  `json:"pid"`
+	Name           string  `json:"name"`
+	Resource       string  `json:"resource"` // privateBytes, workingSet, handles or threads
+	Current        float64 `json:"current"`
+	GrowthPerSec   float64 `json:"growthPerSec"`  // trend of the post-GC floor, units per second
+	GrowthPercent  float64 `json:"growthPercent"` // growth over the window, % of the starting floor
+	WindowSeconds  float64 `json:"windowSeconds"` // time covered by the samples
+	Samples        int     `json:"samples"`
+	Confidence     float64 `json:"confidence"` // that the growth is not noise, 0-1
+	Limit          float64 `json:"limit,omitempty"`
+	SecondsToLimit float64 `json:"secondsToLimit"` // -1 when no limit applies
+	Severity       string  `json:"severity"`       // warning, critical
+}
+
+// ProcessLeakReport lists the processes with leaking resources
+type ProcessLeakReport struct {
+	AnalyzedAt time.Time     `json:"analyzedAt"`
+	Tracked    int           `json:"tracked"` // processes with history
+	Leaks      []ProcessLeak `json:"leaks"`
 }
 
 // MonitoringStatus represents the current monitoring state